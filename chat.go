@@ -3,24 +3,37 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
+	"go-groq/internal/guard"
+	"go-groq/internal/ingest"
 	"go-groq/internal/llm"
+	"go-groq/internal/redact"
+	"go-groq/internal/retrieve"
+	"go-groq/internal/table"
+	"go-groq/internal/vectorstore"
 
 	"github.com/fatih/color"
 )
 
 // ConversationMessage stores a single message in the conversation
 type ConversationMessage struct {
-	Role      string
-	Content   string
-	Timestamp time.Time
-	Provider  string
+	Role        string
+	Content     string
+	Timestamp   time.Time
+	Provider    string
+	Interrupted bool              // the answer was cut off by Ctrl+C; Content holds what was shown
+	Sources     []retrieve.Result // chunks the answer was given as context, cited as [1], [2], ...
 }
 
 // ChatBot handles RAG-based chat interactions with conversation memory
@@ -28,20 +41,137 @@ type ChatBot struct {
 	config              *Config
 	conversationHistory []ConversationMessage
 	llmClient           llm.LLMClient
+	embedder            llm.EmbeddingClient // nil when no embedding provider is configured
+	noCache             bool                // bypass the response cache for the whole session
+	middleware          *llm.Builder
+	metrics             *llm.Metrics
+	redact              *redact.Redactor // created on first use, see redactor()
+	guard               *guard.Guard
+	store               vectorstore.VectorStore // ingested knowledge
+	keywords            *retrieve.BM25          // keyword index over store, see keywordIndex()
+	keywordsOnce        sync.Once
+	rewriteClient       llm.LLMClient         // rewrites and expands search queries; nil uses llmClient
+	expansion           string                // /retrieval override for the session; "" uses the configured modes
+	reranker            *retrieve.RerankStage // nil when RERANK is none
+	docs                *ingest.Manifest      // content hashes of ingested files, see manifest()
+	manifestOnce        sync.Once
+	repoCommits         map[string]string // last indexed commit by collection and repository, see repo.go
+	watches             map[string]watch  // background watches by absolute path
+	watchers            sync.WaitGroup
+	watchEvents         []ingest.Progress // background updates waiting to be printed
+	watchNotify         chan struct{}
+	watchMu             sync.Mutex
+	tables              map[string]cachedTable // parsed table files by path, see loadTable()
+	tablesMu            sync.Mutex
+	log                 *log.Logger
+	logOnce             sync.Once
 	mu                  sync.RWMutex
 }
 
 // NewChatBot creates a new ChatBot instance
 func NewChatBot(config *Config) *ChatBot {
-	client, err := llm.NewClient(config.Provider, config.APIKey, config.ChatModel)
+	cb := &ChatBot{
+		config:              config,
+		conversationHistory: make([]ConversationMessage, 0),
+		metrics:             llm.NewMetrics(),
+		watchNotify:         make(chan struct{}, 1),
+	}
+	if config.EmbeddingProvider != "" {
+		embedder, err := llm.NewEmbeddingClient(config.EmbeddingProvider, config.EmbeddingAPIKey, config.EmbeddingModel)
+		if err != nil {
+			panic(fmt.Sprintf("failed to create embedding client: %v", err))
+		}
+		// Local embeddings never leave the machine, so only remote ones are redacted
+		if config.RedactEnabled && config.EmbeddingProvider != "local" {
+			redactor, err := cb.redactor()
+			if err != nil {
+				panic(fmt.Sprintf("failed to configure redaction: %v", err))
+			}
+			embedder = redactingEmbedder{EmbeddingClient: embedder, r: redactor}
+		}
+		cb.embedder = embedder
+	}
+	metric, err := vectorstore.ParseMetric(config.VectorMetric)
+	if err != nil {
+		panic(fmt.Sprintf("invalid VECTOR_METRIC: %v", err))
+	}
+	if cb.store, err = cb.openStore(metric); err != nil {
+		panic(fmt.Sprintf("failed to open vector index: %v", err))
+	}
+	cb.middleware = cb.newMiddlewareBuilder()
+	client, err := cb.newClient(config.Provider, config.APIKey, config.ChatModel)
 	if err != nil {
 		panic(fmt.Sprintf("failed to create LLM client: %v", err))
 	}
-	return &ChatBot{
-		config:              config,
-		conversationHistory: make([]ConversationMessage, 0),
-		llmClient:           client,
+	cb.llmClient = client
+	if cb.guard, err = cb.newGuard(); err != nil {
+		panic(fmt.Sprintf("failed to configure guardrails: %v", err))
+	}
+	if cb.rewriteClient, err = cb.newRewriteClient(); err != nil {
+		panic(fmt.Sprintf("failed to create query rewrite client: %v", err))
+	}
+	if cb.reranker, err = cb.newReranker(); err != nil {
+		panic(fmt.Sprintf("failed to configure reranking: %v", err))
+	}
+	if config.TableRowsPerChunk > 1 {
+		registerTableLoaders(config.TableRowsPerChunk)
+	}
+	return cb
+}
+
+// newIndex creates the in-memory search structure selected by INDEX_TYPE
+func (cb *ChatBot) newIndex(metric vectorstore.Metric) (vectorstore.Index, error) {
+	switch strings.ToLower(cb.config.IndexType) {
+	case "", "exact":
+		return vectorstore.NewMemory(metric), nil
+	case "hnsw":
+		return vectorstore.NewHNSW(metric, cb.hnswConfig()), nil
+	default:
+		return nil, fmt.Errorf("unknown INDEX_TYPE %q (supported: exact, hnsw)", cb.config.IndexType)
+	}
+}
+
+// hnswConfig returns the HNSW settings from config
+func (cb *ChatBot) hnswConfig() vectorstore.HNSWConfig {
+	return vectorstore.HNSWConfig{
+		M:              cb.config.HNSWM,
+		EfConstruction: cb.config.HNSWEfConstruction,
+		EfSearch:       cb.config.HNSWEfSearch,
+	}
+}
+
+// openStore opens the on-disk index, or an in-memory one when persistence is disabled
+func (cb *ChatBot) openStore(metric vectorstore.Metric) (vectorstore.VectorStore, error) {
+	index, err := cb.newIndex(metric)
+	if err != nil {
+		return nil, err
+	}
+	if !cb.config.IndexPersist {
+		return index, nil
+	}
+	var model string
+	if cb.embedder != nil {
+		model = cb.config.EmbeddingProvider + "/" + cb.embedder.Model()
+	}
+	return vectorstore.OpenDisk(cb.config.IndexDir, model, metric, index)
+}
+
+// Close stops background watches and releases the vector index
+func (cb *ChatBot) Close() error {
+	cb.stopWatches()
+	if closer, ok := cb.store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// newClient creates the provider client and wraps it in the middleware chain from config
+func (cb *ChatBot) newClient(provider, apiKey, model string) (llm.LLMClient, error) {
+	client, err := llm.NewClient(provider, apiKey, model)
+	if err != nil {
+		return nil, err
 	}
+	return cb.middleware.Build(client, provider, model, cb.config.Middleware)
 }
 
 // SwitchModel switches to a different provider and/or model at runtime.
@@ -50,7 +180,7 @@ func (cb *ChatBot) SwitchModel(provider, model, apiKey string) error {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
-	client, err := llm.NewClient(provider, apiKey, model)
+	client, err := cb.newClient(provider, apiKey, model)
 	if err != nil {
 		return err
 	}
@@ -72,12 +202,116 @@ func (cb *ChatBot) AddToHistory(role, content, provider string) {
 	})
 }
 
-// Query performs a RAG query with conversation context
+// dropLastQuestion removes the latest message when it is the user question given, still
+// without an answer
+func (cb *ChatBot) dropLastQuestion(question string) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if n := len(cb.conversationHistory); n > 0 {
+		if last := cb.conversationHistory[n-1]; last.Role == "user" && last.Content == question {
+			cb.conversationHistory = cb.conversationHistory[:n-1]
+		}
+	}
+}
+
+// MarkLastInterrupted replaces the latest assistant answer with the part that was
+// actually shown before the user interrupted it
+func (cb *ChatBot) MarkLastInterrupted(partial string) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	for i := len(cb.conversationHistory) - 1; i >= 0; i-- {
+		if cb.conversationHistory[i].Role == "assistant" {
+			cb.conversationHistory[i].Content = partial
+			cb.conversationHistory[i].Interrupted = true
+			return
+		}
+	}
+}
+
+// QueryInfo collects details about how a query was answered, for display.
+// Attach one to the context with WithQueryInfo before calling Query.
+type QueryInfo struct {
+	Warnings     []string          // guardrail warnings and rewrites
+	Sources      []retrieve.Result // chunks packed into the prompt, labelled [1], [2], ...
+	SearchQuery  string            // query used for retrieval, rewritten from the question
+	Expansions   []string          // paraphrases or hypothetical answers generated for retrieval
+	Rerank       string            // how the candidates were reranked, for debug output
+	Filter       string            // inline metadata filters applied to retrieval
+	TableQueries []string          // exact table queries run for the model in table mode
+}
+
+type queryInfoKey struct{}
+
+// WithQueryInfo returns a context that makes Query record details into info
+func WithQueryInfo(ctx context.Context, info *QueryInfo) context.Context {
+	return context.WithValue(ctx, queryInfoKey{}, info)
+}
+
+// queryInfo returns the QueryInfo attached to ctx, or a throwaway one
+func queryInfo(ctx context.Context) *QueryInfo {
+	if info, ok := ctx.Value(queryInfoKey{}).(*QueryInfo); ok {
+		return info
+	}
+	return &QueryInfo{}
+}
+
+// Query performs a RAG query with conversation context. Inline filters such as
+// @path:docs/ or @type:go restrict retrieval and are removed from the question
 func (cb *ChatBot) Query(ctx context.Context, question string) (string, error) {
-	// Add user message to history (user has no provider, or "user")
+	info := queryInfo(ctx)
+
+	question, filter, err := retrieve.ExtractFilters(question, cb.keywordIndex().HasMetadataKey)
+	if err != nil {
+		return "", err
+	}
+	if question == "" {
+		return "", fmt.Errorf("no question given after the filters")
+	}
+	info.Filter = filter.String()
+
+	// Input guardrails run before anything is recorded or sent
+	checked, err := cb.guard.Run(ctx, guard.StageInput, question)
+	if err != nil {
+		return "", err
+	}
+	info.Warnings = append(info.Warnings, checked.Warnings...)
+	question = checked.Text
+
+	// Add user message to history (user has no provider, or "user"). Unless an answer, or
+	// an interrupted one, is recorded for it, it is taken out again, so that a failed or
+	// blocked question is not sent with the next one as two user messages in a row
 	cb.AddToHistory("user", question, "user")
+	answered := false
+	defer func() {
+		if !answered {
+			cb.dropLastQuestion(question)
+		}
+	}()
+
+	// 1. Retrieve context from the knowledge base; answering without it beats failing
+	cb.mu.RLock()
+	ragEnabled, topK, budget := cb.config.RAGEnabled, cb.config.RAGTopK, cb.config.RAGContextTokens
+	tableMode := cb.config.TableMode
+	cb.mu.RUnlock()
+	var contextSection string
+	if ragEnabled && cb.store.Count() > 0 {
+		contextSection, info.Sources, err = cb.retrieveContext(ctx, question, topK, budget, filter)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			var blocked *guard.BlockedError
+			if errors.As(err, &blocked) {
+				return "", err
+			}
+			cb.logger().Printf("retrieval: %v", err)
+			info.Warnings = append(info.Warnings, fmt.Sprintf("retrieval failed, answering without context: %v", err))
+		}
+	} else if len(filter) > 0 && !ragEnabled {
+		info.Warnings = append(info.Warnings, "filters ignored: retrieval is off (/rag on)")
+	}
 
-	// 1. Snapshot state protected by RLock
+	// 2. Snapshot state protected by RLock
 	cb.mu.RLock()
 	client := cb.llmClient
 	// Build messages for LLM including conversation history
@@ -92,6 +326,9 @@ func (cb *ChatBot) Query(ctx context.Context, question string) (string, error) {
 	historySlice := cb.conversationHistory[historyStart:]
 	// Copy history while locked
 	for _, msg := range historySlice {
+		if msg.Interrupted && msg.Content == "" {
+			continue // cancelled before any answer arrived
+		}
 		messages = append(messages, llm.Message{
 			Role:    msg.Role,
 			Content: msg.Content,
@@ -101,18 +338,109 @@ func (cb *ChatBot) Query(ctx context.Context, question string) (string, error) {
 	currentProvider := cb.config.Provider
 	cb.mu.RUnlock()
 
-	// 2. Call LLM (long running operation) - no lock held
-	answer, err := client.Generate(ctx, messages)
+	// Retrieved context joins the system prompt: Anthropic and Gemini take a single
+	// system instruction, so a second system message would replace the prompt
+	if contextSection != "" {
+		messages[0].Content += "\n\n" + contextSection
+	}
+	// In table mode the model can ask for exact figures from the tables it was shown rows of
+	var tables map[string]*table.Table
+	if tableMode {
+		tables = cb.sourceTables(info.Sources)
+	}
+	if len(tables) > 0 {
+		messages[0].Content += "\n\n" + tableSection(tables)
+	}
+
+	// 3. Call LLM (long running operation) - no lock held
+	var answer string
+	if len(tables) > 0 {
+		answer, err = cb.generateWithTables(ctx, client, messages, tables)
+	} else {
+		answer, err = client.Generate(ctx, messages)
+	}
 	if err != nil {
+		if ctx.Err() != nil {
+			// Cancelled by the user: keep an empty interrupted answer so the turn is visible in /history
+			cb.mu.Lock()
+			cb.conversationHistory = append(cb.conversationHistory, ConversationMessage{
+				Role:        "assistant",
+				Timestamp:   time.Now(),
+				Provider:    currentProvider,
+				Interrupted: true,
+			})
+			cb.mu.Unlock()
+			answered = true
+		}
 		return "", err
 	}
 
-	// 3. Add assistant response to history
-	cb.AddToHistory("assistant", answer, currentProvider)
+	// 4. Output guardrails; a blocked answer is never shown or remembered
+	checked, err = cb.guard.Run(ctx, guard.StageOutput, answer)
+	if err != nil {
+		return "", err
+	}
+	info.Warnings = append(info.Warnings, checked.Warnings...)
+	answer = checked.Text
+
+	// 5. Add assistant response to history, with the sources it can cite
+	cb.mu.Lock()
+	cb.conversationHistory = append(cb.conversationHistory, ConversationMessage{
+		Role:      "assistant",
+		Content:   answer,
+		Timestamp: time.Now(),
+		Provider:  currentProvider,
+		Sources:   info.Sources,
+	})
+	cb.mu.Unlock()
+	answered = true
 
 	return answer, nil
 }
 
+// runCancellable runs fn with a context that the first Ctrl+C cancels, and reports whether it did
+func runCancellable(ctx context.Context, sigChan <-chan os.Signal, fn func(ctx context.Context)) bool {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		fn(runCtx)
+		close(done)
+	}()
+	select {
+	case <-done:
+		return false
+	case <-sigChan:
+		cancel()
+		<-done
+		return true
+	}
+}
+
+// printStats prints usage statistics collected by the metrics middleware
+func (cb *ChatBot) printStats() {
+	cyan := color.New(color.FgCyan, color.Bold)
+	gray := color.New(color.FgHiBlack)
+
+	entries := cb.metrics.Snapshot()
+	fmt.Println()
+	cyan.Println("  📊 LLM Usage")
+	if len(entries) == 0 {
+		gray.Println("    No requests yet.")
+	}
+	for _, e := range entries {
+		avg := time.Duration(0)
+		if e.Stats.Calls > 0 {
+			avg = e.Stats.TotalLatency / time.Duration(e.Stats.Calls)
+		}
+		fmt.Printf("    %s / %s [%s]: ", e.Key.Provider, e.Key.Model, e.Key.Label)
+		gray.Printf("%d calls, %d errors, %d cache hits, avg %s, %d→%d chars\n",
+			e.Stats.Calls, e.Stats.Errors, e.Stats.CacheHits, avg.Round(time.Millisecond),
+			e.Stats.PromptChars, e.Stats.ResponseChars)
+	}
+	fmt.Println()
+}
+
 // StreamText prints text with a typing effect
 func StreamText(text string, textColor *color.Color) {
 	for _, char := range text {
@@ -127,8 +455,10 @@ func GetTimeString() string {
 	return time.Now().Format("15:04:05")
 }
 
-// StreamResponseWithCodeHighlight streams response with simple code highlighting
-func StreamResponseWithCodeHighlight(text string) {
+// StreamResponseWithCodeHighlight streams response with simple code highlighting.
+// Citations like [1] that refer to one of sources are printed as links to the source.
+// It stops early when ctx is cancelled and returns the number of bytes printed.
+func StreamResponseWithCodeHighlight(ctx context.Context, text string, sources []retrieve.Result) int {
 	white := color.New(color.FgWhite)
 	codeBlockColor := color.New(color.FgBlue)
 	inlineCodeColor := color.New(color.FgYellow)
@@ -138,6 +468,11 @@ func StreamResponseWithCodeHighlight(text string) {
 	i := 0
 
 	for i < len(text) {
+		if ctx.Err() != nil {
+			fmt.Println()
+			return i
+		}
+
 		// Check for code block start/end (```)
 		if i+2 < len(text) && text[i:i+3] == "```" {
 			if !inCodeBlock {
@@ -176,6 +511,16 @@ func StreamResponseWithCodeHighlight(text string) {
 			continue
 		}
 
+		// Citations outside code become links
+		if !inCodeBlock && !inInlineCode && text[i] == '[' {
+			if n, width := parseCitation(text[i:]); n > 0 && n <= len(sources) {
+				printCitation(n, sources[n-1])
+				time.Sleep(5 * time.Millisecond)
+				i += width
+				continue
+			}
+		}
+
 		// Print character with appropriate color
 		char := string(text[i])
 		if inCodeBlock {
@@ -190,6 +535,7 @@ func StreamResponseWithCodeHighlight(text string) {
 		i++
 	}
 	fmt.Println()
+	return len(text)
 }
 
 // RunInteractive starts an interactive chat session
@@ -225,6 +571,11 @@ func (cb *ChatBot) RunInteractive(ctx context.Context) error {
 	cyan.Printf("%s", cb.config.Provider)
 	gray.Print("  •  Model: ")
 	cyan.Println(cb.config.ChatModel)
+	if cb.config.IndexPersist {
+		gray.Print("  Knowledge: ")
+		cyan.Printf("%d chunks", cb.store.Count())
+		gray.Printf("  •  Index: %s\n", cb.config.IndexDir)
+	}
 	fmt.Println()
 
 	// Commands section
@@ -239,10 +590,52 @@ func (cb *ChatBot) RunInteractive(ctx context.Context) error {
 	printOrange("/clear")
 	gray.Println("  Clear screen")
 	fmt.Print("    ")
+	printOrange("/ingest [-c name] [-f] [-w] <path>")
+	gray.Println(" Add or update files; -f re-embeds all, -w watches")
+	fmt.Print("    ")
+	printOrange("/ingest-repo [--changed] <path>")
+	gray.Println(" Index a git working tree; --changed since last run")
+	fmt.Print("    ")
+	printOrange("/ingest-url [--depth N] [--same-host] <url>")
+	gray.Println(" Crawl a web site")
+	fmt.Print("    ")
+	printOrange("/watch")
+	gray.Print("            List watched paths")
+	fmt.Print("  ")
+	printOrange("/unwatch [path]")
+	gray.Println("  Stop watching")
+	fmt.Print("    ")
+	printOrange("/rag on|off")
+	gray.Print("       Answer from the knowledge base")
+	fmt.Print("  ")
+	printOrange("/k <n>")
+	gray.Println("  Chunks per question")
+	fmt.Print("    ")
+	printOrange("/sources [n]")
+	gray.Println("      Show the passages cited by the last (or nth) answer")
+	fmt.Print("    ")
+	printOrange("/tables on|off")
+	gray.Println("    Exact counts and sums over retrieved CSV and JSON files")
+	fmt.Print("    ")
+	printOrange("/retrieval <mode>")
+	gray.Println(" Query expansion: none, multi-query, hyde or auto")
+	fmt.Print("    ")
+	printOrange("/search <query>")
+	gray.Println("   Show matching chunks; @path:docs/ @type:go etc. filter questions too")
+	fmt.Print("    ")
+	printOrange("/stats")
+	gray.Print("            Show LLM usage statistics")
+	fmt.Print("  ")
+	printOrange("/debug")
+	gray.Println("  Toggle debug output")
+	fmt.Print("    ")
+	printOrange("/nocache [question]")
+	gray.Println(" Bypass the response cache (toggle, or for one question)")
+	fmt.Print("    ")
 	printOrange("/exit")
 	gray.Print("             Exit chatbot    ")
 	fmt.Print("  ")
-	gray.Println("Ctrl+C  Quick exit")
+	gray.Println("Ctrl+C  Cancel answer / exit")
 	fmt.Println()
 
 	gray.Println("  ─────────────────────────────────────────────────────────────")
@@ -286,6 +679,12 @@ func (cb *ChatBot) RunInteractive(ctx context.Context) error {
 				return nil // Channel closed
 			}
 			input = strings.TrimSpace(text)
+		case <-cb.watchNotify:
+			fmt.Print("\r\033[K") // Replace the prompt; it is printed again below
+			for _, e := range cb.takeWatchEvents() {
+				printWatchEvent(e)
+			}
+			continue
 		}
 
 		// Handle empty input
@@ -317,6 +716,7 @@ func (cb *ChatBot) RunInteractive(ctx context.Context) error {
 			if len(cb.conversationHistory) == 0 {
 				gray.Println("    No messages yet.")
 			}
+			answerNum := 0
 			for _, msg := range cb.conversationHistory {
 				if msg.Role == "user" {
 					fmt.Print("    ")
@@ -324,8 +724,15 @@ func (cb *ChatBot) RunInteractive(ctx context.Context) error {
 					fmt.Println(msg.Content)
 				} else {
 					fmt.Print("    ")
-					magenta.Printf("%s (%s): ", msg.Provider, msg.Timestamp.Format("15:04:05"))
+					answerNum++
+					magenta.Printf("#%d %s (%s): ", answerNum, msg.Provider, msg.Timestamp.Format("15:04:05"))
 					fmt.Println(msg.Content)
+					if msg.Interrupted {
+						yellow.Println("    (interrupted)")
+					}
+					if len(msg.Sources) > 0 {
+						gray.Printf("    %d sources, see /sources %d\n", len(msg.Sources), answerNum)
+					}
 					fmt.Println()
 				}
 
@@ -361,18 +768,7 @@ func (cb *ChatBot) RunInteractive(ctx context.Context) error {
 			if len(parts) >= 3 {
 				newModel = strings.Join(parts[2:], " ") // Allow model names with spaces/slashes
 			} else {
-				switch newProvider {
-				case "groq":
-					newModel = "llama-3.3-70b-versatile"
-				case "openai":
-					newModel = "gpt-4o-mini"
-				case "anthropic":
-					newModel = "claude-3-5-sonnet-20241022"
-				case "gemini":
-					newModel = "gemini-1.5-flash"
-				case "openrouter":
-					newModel = "meta-llama/llama-3.1-8b-instruct:free"
-				}
+				newModel = defaultModel(newProvider)
 			}
 
 			// Determine API key for the new provider
@@ -395,21 +791,356 @@ func (cb *ChatBot) RunInteractive(ctx context.Context) error {
 			continue
 		}
 
+		// Handle /ingest command: /ingest <path>
+		if strings.HasPrefix(strings.ToLower(input), "/ingest ") || strings.ToLower(input) == "/ingest" {
+			parsed, err := parseIngestArgs(strings.Fields(input)[1:])
+			if err != nil {
+				red.Printf("%v\nUsage: /ingest [--collection <name>] [--force] [--watch] <path>...\n\n", err)
+				continue
+			}
+			var summary ingest.Summary
+			cancelled := runCancellable(ctx, sigChan, func(ctx context.Context) {
+				for _, path := range parsed.paths {
+					var s ingest.Summary
+					s, err = cb.Ingest(ctx, path, parsed.collection, parsed.force)
+					summary.Add(s)
+					if err != nil {
+						return
+					}
+				}
+			})
+			if cancelled {
+				yellow.Printf("⏹  Ingestion cancelled after %d files\n\n", summary.Files)
+				continue
+			}
+			if err != nil {
+				red.Printf("❌ Ingest failed: %v\n\n", err)
+				continue
+			}
+			cb.printIngestSummary(summary)
+			if parsed.watch {
+				for _, path := range parsed.paths {
+					if err := cb.Watch(path, parsed.collection); err != nil {
+						red.Printf("❌ %v\n", err)
+						continue
+					}
+					cyan.Printf("👀 Watching %s for changes\n", path)
+				}
+				fmt.Println()
+			}
+			continue
+		}
+
+		// Handle /ingest-repo command: /ingest-repo [--changed] <path>
+		if strings.HasPrefix(strings.ToLower(input), "/ingest-repo ") || strings.ToLower(input) == "/ingest-repo" {
+			parsed, err := parseRepoArgs(strings.Fields(input)[1:])
+			if err != nil {
+				red.Printf("%v\nUsage: /ingest-repo [--collection <name>] [--changed] [--force] <path>\n\n", err)
+				continue
+			}
+			var result repoIngest
+			cancelled := runCancellable(ctx, sigChan, func(ctx context.Context) {
+				result, err = cb.IngestRepo(ctx, parsed.path, parsed.collection, parsed.changed, parsed.force)
+			})
+			if cancelled {
+				yellow.Printf("⏹  Ingestion cancelled after %d files\n\n", result.summary.Files)
+				continue
+			}
+			if err != nil {
+				red.Printf("❌ Ingest failed: %v\n\n", err)
+				continue
+			}
+			cb.printRepoIngest(result)
+			continue
+		}
+
+		// Handle /ingest-url command: /ingest-url [--depth N] [--same-host] <url>
+		if strings.HasPrefix(strings.ToLower(input), "/ingest-url ") || strings.ToLower(input) == "/ingest-url" {
+			parsed, err := parseURLArgs(strings.Fields(input)[1:])
+			if err != nil {
+				red.Printf("%v\nUsage: /ingest-url %s\n\n", err, urlUsage)
+				continue
+			}
+			var summary ingest.CrawlSummary
+			cancelled := runCancellable(ctx, sigChan, func(ctx context.Context) {
+				summary, err = cb.IngestURL(ctx, parsed)
+			})
+			if cancelled {
+				yellow.Printf("⏹  Crawl cancelled after %d pages\n\n", summary.Pages)
+				continue
+			}
+			if err != nil {
+				red.Printf("❌ Ingest failed: %v\n\n", err)
+				continue
+			}
+			cb.printCrawl(summary)
+			continue
+		}
+
+		// Handle /watch command: list background watches
+		if strings.ToLower(input) == "/watch" {
+			cb.printWatches()
+			continue
+		}
+
+		// Handle /unwatch command: /unwatch [path]
+		if strings.HasPrefix(strings.ToLower(input), "/unwatch ") || strings.ToLower(input) == "/unwatch" {
+			stopped, err := cb.Unwatch(strings.TrimSpace(input[len("/unwatch"):]))
+			if err != nil {
+				red.Printf("❌ %v\n\n", err)
+				continue
+			}
+			if len(stopped) == 0 {
+				gray.Println("Not watching anything")
+			}
+			for _, p := range stopped {
+				yellow.Printf("Stopped watching %s\n", p)
+			}
+			fmt.Println()
+			continue
+		}
+
+		// Handle /search command: /search <query>
+		if strings.HasPrefix(strings.ToLower(input), "/search ") || strings.ToLower(input) == "/search" {
+			query, filter, err := retrieve.ExtractFilters(strings.TrimSpace(input[len("/search"):]), cb.keywordIndex().HasMetadataKey)
+			if err != nil {
+				red.Printf("❌ %v\n\n", err)
+				continue
+			}
+			if query == "" {
+				red.Println("Usage: /search [@key:value ...] <query>")
+				continue
+			}
+			var results []retrieve.Result
+			cancelled := runCancellable(ctx, sigChan, func(ctx context.Context) {
+				results, err = cb.Search(ctx, query, 8, filter)
+			})
+			if cancelled {
+				yellow.Println("⏹  Search cancelled")
+				continue
+			}
+			if err != nil {
+				red.Printf("❌ Search failed: %v\n\n", err)
+				continue
+			}
+			printSearchResults(results)
+			continue
+		}
+
+		// Handle /sources command: /sources [n]
+		if strings.HasPrefix(strings.ToLower(input), "/sources ") || strings.ToLower(input) == "/sources" {
+			n := 0
+			if arg := strings.TrimSpace(input[len("/sources"):]); arg != "" {
+				var err error
+				if n, err = strconv.Atoi(strings.TrimPrefix(arg, "#")); err != nil || n < 1 {
+					red.Println("Usage: /sources [answer number]")
+					continue
+				}
+			}
+			sources, num, err := cb.answerSources(n)
+			if err != nil {
+				yellow.Printf("%v\n\n", err)
+				continue
+			}
+			printSources(num, sources)
+			continue
+		}
+
+		// Handle /rag command: /rag [on|off]
+		if strings.HasPrefix(strings.ToLower(input), "/rag ") || strings.ToLower(input) == "/rag" {
+			switch arg := strings.ToLower(strings.TrimSpace(input[len("/rag"):])); arg {
+			case "on", "off":
+				cb.SetRAG(arg == "on")
+			case "":
+			default:
+				red.Println("Usage: /rag [on|off]")
+				continue
+			}
+			cb.mu.RLock()
+			enabled, k := cb.config.RAGEnabled, cb.config.RAGTopK
+			cb.mu.RUnlock()
+			if enabled {
+				green.Printf("📚 Retrieval on: answers use the top %d chunks of the knowledge base (%d chunks)\n\n", k, cb.store.Count())
+			} else {
+				yellow.Println("Retrieval off: answers use the conversation only")
+				fmt.Println()
+			}
+			continue
+		}
+
+		// Handle /tables command: /tables [on|off]
+		if strings.HasPrefix(strings.ToLower(input), "/tables ") || strings.ToLower(input) == "/tables" {
+			switch arg := strings.ToLower(strings.TrimSpace(input[len("/tables"):])); arg {
+			case "on", "off":
+				cb.SetTableMode(arg == "on")
+			case "":
+			default:
+				red.Println("Usage: /tables [on|off]")
+				continue
+			}
+			cb.mu.RLock()
+			enabled := cb.config.TableMode
+			cb.mu.RUnlock()
+			if enabled {
+				green.Println("🧮 Table mode on: counts and sums over retrieved CSV and JSON files are computed exactly")
+			} else {
+				yellow.Println("Table mode off: answers use the retrieved rows only")
+			}
+			fmt.Println()
+			continue
+		}
+
+		// Handle /k command: /k <n>
+		if strings.HasPrefix(strings.ToLower(input), "/k ") || strings.ToLower(input) == "/k" {
+			k, err := strconv.Atoi(strings.TrimSpace(input[len("/k"):]))
+			if err != nil {
+				red.Println("Usage: /k <number of chunks to retrieve>")
+				continue
+			}
+			if err := cb.SetTopK(k); err != nil {
+				red.Printf("%v\n", err)
+				continue
+			}
+			green.Printf("✅ Retrieving %d chunks per question\n\n", k)
+			continue
+		}
+
+		// Handle /retrieval command: /retrieval [none|multi-query|hyde|auto]
+		if strings.HasPrefix(strings.ToLower(input), "/retrieval ") || strings.ToLower(input) == "/retrieval" {
+			if arg := strings.TrimSpace(input[len("/retrieval"):]); arg != "" {
+				if err := cb.SetExpansion(arg); err != nil {
+					red.Printf("%v\n", err)
+					red.Println("Usage: /retrieval [none|multi-query|hyde|auto]")
+					continue
+				}
+			}
+			cb.mu.RLock()
+			mode := cb.expansion
+			cb.mu.RUnlock()
+			if mode == "" {
+				green.Printf("🔎 Query expansion: %s, or as set per collection in COLLECTIONS_FILE\n\n", cb.config.RetrievalExpansion)
+			} else {
+				green.Printf("🔎 Query expansion: %s for all collections (/retrieval auto to reset)\n\n", mode)
+			}
+			continue
+		}
+
+		// Handle /debug command: toggle retrieval details
+		if strings.ToLower(input) == "/debug" {
+			cb.config.Debug = !cb.config.Debug
+			if cb.config.Debug {
+				yellow.Println("Debug output on: showing search queries")
+			} else {
+				yellow.Println("Debug output off")
+			}
+			fmt.Println()
+			continue
+		}
+
+		// Handle /stats command
+		if strings.ToLower(input) == "/stats" {
+			cb.printStats()
+			continue
+		}
+
+		// Handle /nocache command: toggle for the session, or bypass for one question
+		bypassCache := cb.noCache
+		if strings.ToLower(input) == "/nocache" {
+			cb.noCache = !cb.noCache
+			if cb.noCache {
+				yellow.Println("Response cache bypassed for this session")
+			} else {
+				yellow.Println("Response cache re-enabled")
+			}
+			fmt.Println()
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(input), "/nocache ") {
+			input = strings.TrimSpace(input[len("/nocache "):])
+			bypassCache = true
+		}
+
 		// Set streaming flag
 		streaming = true
 
-		// Show "<provider> is thinking..." indicator
+		// Each query gets its own context so Ctrl+C can cancel just this request
+		var cacheStatus llm.CacheStatus
+		var info QueryInfo
+		queryCtx, cancelQuery := context.WithCancel(WithQueryInfo(llm.WithCacheStatus(ctx, &cacheStatus), &info))
+		if bypassCache {
+			queryCtx = llm.WithoutCache(queryCtx)
+		}
+
+		// Run the query in the background while showing "<provider> is thinking..."
+		type queryResult struct {
+			answer string
+			err    error
+		}
+		done := make(chan queryResult, 1)
+		go func() {
+			answer, err := cb.Query(queryCtx, input)
+			done <- queryResult{answer, err}
+		}()
+
 		fmt.Println()
 		gray := color.New(color.FgHiBlack)
 		gray.Printf("%s is thinking", cb.config.Provider)
-		for i := 0; i < 3; i++ {
-			time.Sleep(200 * time.Millisecond)
-			gray.Print(".")
+		ticker := time.NewTicker(200 * time.Millisecond)
+		var result queryResult
+		cancelled := false
+	wait:
+		for dots := 0; ; {
+			select {
+			case result = <-done:
+				break wait
+			case <-sigChan:
+				// First Ctrl+C cancels the in-flight request, not the program
+				cancelQuery()
+				cancelled = true
+				result = <-done
+				break wait
+			case <-ticker.C:
+				if dots < 3 {
+					gray.Print(".")
+					dots++
+				}
+			}
 		}
+		ticker.Stop()
+		cancelQuery()
 		fmt.Print("\r\033[K") // Clear the "thinking" line
 
-		// Process question
-		answer, err := cb.Query(ctx, input)
+		if cancelled {
+			yellow.Println("⏹  Request cancelled (press Ctrl+C again to exit)")
+			fmt.Println()
+			streaming = false
+			continue
+		}
+		if info.Filter != "" {
+			gray.Printf("🔎 filter: %s\n", info.Filter)
+		}
+		for _, q := range info.TableQueries {
+			gray.Printf("🧮 table query: %s\n", q)
+		}
+		if cb.config.Debug && info.SearchQuery != "" {
+			gray.Printf("🔍 search query: %s\n", info.SearchQuery)
+			for _, e := range info.Expansions {
+				gray.Printf("   %s\n", e)
+			}
+			if info.Rerank != "" {
+				gray.Printf("   rerank %s\n", info.Rerank)
+			}
+		}
+		for _, w := range info.Warnings {
+			yellow.Printf("⚠️  %s\n", w)
+		}
+		answer, err := result.answer, result.err
+		var blocked *guard.BlockedError
+		if errors.As(err, &blocked) {
+			red.Printf("🚫 Blocked by %s: %s\n\n", blocked.Check, blocked.Reason)
+			streaming = false
+			continue
+		}
 		if err != nil {
 			red.Printf("\n❌ Error: %v\n\n", err)
 			streaming = false
@@ -420,8 +1151,36 @@ func (cb *ChatBot) RunInteractive(ctx context.Context) error {
 		botTimeStr := GetTimeString()
 		magenta.Printf("%s (%s): ", cb.config.Provider, botTimeStr)
 
-		// Stream response with simple code highlighting
-		StreamResponseWithCodeHighlight(answer)
+		// Stream response with simple code highlighting; Ctrl+C stops the typing effect
+		streamCtx, stopStream := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-sigChan:
+				stopStream()
+			case <-streamCtx.Done():
+			}
+		}()
+		shown := StreamResponseWithCodeHighlight(streamCtx, answer, info.Sources)
+		stopStream()
+		if shown < len(answer) {
+			// Cut at a rune boundary so history never holds half a character
+			for shown > 0 && !utf8.RuneStart(answer[shown]) {
+				shown--
+			}
+			cb.MarkLastInterrupted(answer[:shown])
+			yellow.Println("⏹  Answer interrupted (press Ctrl+C again to exit)")
+		}
+
+		if len(info.Sources) > 0 {
+			printSourceList(info.Sources)
+		}
+		if cacheStatus.Hit {
+			if cacheStatus.Semantic {
+				gray.Printf("⚡ cached (similar question, %.2f match, %s old)\n", cacheStatus.Similarity, cacheStatus.Age.Round(time.Second))
+			} else {
+				gray.Printf("⚡ cached (%s old)\n", cacheStatus.Age.Round(time.Second))
+			}
+		}
 
 		fmt.Println()
 
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"go-groq/internal/rag"
+	"go-groq/internal/retrieve"
+
+	"github.com/fatih/color"
+)
+
+// citationInstructions ask the model to cite the labelled context
+const citationInstructions = `Cite the sources you use inline with their labels, like [1] or [2][3], right after the statement they support. Do not cite sources you did not use.`
+
+// parseCitation parses a citation like "[12]" at the start of s and returns its number
+// and width in bytes, or 0 if s does not start with one
+func parseCitation(s string) (int, int) {
+	n, i := 0, 1
+	for i < len(s) && i <= 3 && s[i] >= '0' && s[i] <= '9' {
+		n = n*10 + int(s[i]-'0')
+		i++
+	}
+	if i == 1 || i >= len(s) || s[i] != ']' {
+		return 0, 0
+	}
+	return n, i + 1
+}
+
+// printCitation prints "[n]" as a link to its source
+func printCitation(n int, src retrieve.Result) {
+	label := fmt.Sprintf("[%d]", n)
+	fmt.Print(hyperlink(sourceLink(src.Chunk), color.New(color.FgCyan).Sprint(label)))
+}
+
+// printSourceList prints the sources of an answer on one line, each linked to its file
+func printSourceList(sources []retrieve.Result) {
+	gray := color.New(color.FgHiBlack)
+	gray.Print("📚 ")
+	for i, src := range sources {
+		if i > 0 {
+			gray.Print("  ")
+		}
+		fmt.Print(hyperlink(sourceLink(src.Chunk), gray.Sprintf("[%d] %s", i+1, chunkLocation(src.Chunk))))
+	}
+	fmt.Println()
+}
+
+// sourceLink returns a URL that opens the chunk's document, or "" if it has none
+func sourceLink(c rag.Chunk) string {
+	if u := c.Metadata[rag.MetaURL]; u != "" {
+		return u
+	}
+	if !filepath.IsAbs(c.DocID) {
+		return ""
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(c.DocID)}
+	if line := c.Metadata[rag.MetaStartLine]; line != "" {
+		u.Fragment = "L" + line
+	}
+	if page := c.Metadata[rag.MetaPage]; page != "" {
+		u.Fragment = "page=" + page // understood by PDF viewers
+	}
+	return u.String()
+}
+
+// hyperlink wraps text in an OSC 8 terminal hyperlink to target. Terminals without
+// support show the text alone; nothing is added when output is not a terminal
+func hyperlink(target, text string) string {
+	if target == "" || color.NoColor {
+		return text
+	}
+	return "\033]8;;" + target + "\033\\" + text + "\033]8;;\033\\"
+}
+
+// answerSources returns the sources of the nth answer in the conversation (1-based), or
+// of the latest answer with sources when n is 0
+func (cb *ChatBot) answerSources(n int) ([]retrieve.Result, int, error) {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+	var answers []ConversationMessage
+	for _, msg := range cb.conversationHistory {
+		if msg.Role == "assistant" {
+			answers = append(answers, msg)
+		}
+	}
+	if n == 0 {
+		for i := len(answers) - 1; i >= 0; i-- {
+			if len(answers[i].Sources) > 0 {
+				return answers[i].Sources, i + 1, nil
+			}
+		}
+		return nil, 0, fmt.Errorf("no answer so far used the knowledge base")
+	}
+	if n < 1 || n > len(answers) {
+		return nil, 0, fmt.Errorf("there is no answer #%d (answers so far: %d)", n, len(answers))
+	}
+	if len(answers[n-1].Sources) == 0 {
+		return nil, 0, fmt.Errorf("answer #%d did not use the knowledge base", n)
+	}
+	return answers[n-1].Sources, n, nil
+}
+
+// printSources shows the exact passages an answer was given, with their labels
+func printSources(n int, sources []retrieve.Result) {
+	cyan := color.New(color.FgCyan)
+	gray := color.New(color.FgHiBlack)
+	fmt.Println()
+	cyan.Printf("  📚 Sources of answer #%d\n", n)
+	for i, src := range sources {
+		fmt.Println()
+		fmt.Print("  ")
+		fmt.Println(hyperlink(sourceLink(src.Chunk), cyan.Sprintf("[%d] %s", i+1, chunkLocation(src.Chunk))))
+		for _, line := range strings.Split(strings.TrimRight(src.Chunk.Text, "\n"), "\n") {
+			gray.Printf("    │ %s\n", line)
+		}
+	}
+	fmt.Println()
+}
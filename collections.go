@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"go-groq/internal/chunk"
+	"go-groq/internal/ingest"
+	"go-groq/internal/retrieve"
+)
+
+// CollectionConfig holds the settings of one collection, read from COLLECTIONS_FILE
+type CollectionConfig struct {
+	Chunker   chunk.Config            `json:"chunker"`
+	ByType    map[string]chunk.Config `json:"by_type,omitempty"`   // chunker overrides by file type, e.g. "md"
+	Retrieval string                  `json:"retrieval,omitempty"` // query expansion: none, multi-query or hyde
+}
+
+// loadCollections reads the collections file. A missing file is not an error.
+func loadCollections(path string) (map[string]CollectionConfig, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]CollectionConfig{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var collections map[string]CollectionConfig
+	if err := json.Unmarshal(data, &collections); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for name, coll := range collections {
+		if _, err := retrieve.ParseExpansion(coll.Retrieval); err != nil {
+			return nil, fmt.Errorf("%s: collection %s: %w", path, name, err)
+		}
+	}
+	return collections, nil
+}
+
+// Collection returns the settings for the named collection, falling back to the
+// CHUNK_* defaults for anything the collections file leaves out
+func (c *Config) Collection(name string) CollectionConfig {
+	coll := c.Collections[name]
+	if coll.Chunker.Strategy == "" {
+		coll.Chunker.Strategy = c.ChunkStrategy
+	}
+	if coll.Chunker.Size == 0 {
+		coll.Chunker.Size = c.ChunkSize
+	}
+	if coll.Chunker.Overlap == 0 {
+		coll.Chunker.Overlap = c.ChunkOverlap
+	}
+	if coll.ByType == nil {
+		coll.ByType = map[string]chunk.Config{
+			"md":       {Strategy: "markdown"},
+			"markdown": {Strategy: "markdown"},
+			"go":       {Strategy: "go"},
+			// HTML, DOCX and EPUB are loaded as Markdown
+			"html":  {Strategy: "markdown"},
+			"htm":   {Strategy: "markdown"},
+			"xhtml": {Strategy: "markdown"},
+			"docx":  {Strategy: "markdown"},
+			"epub":  {Strategy: "markdown"},
+		}
+	}
+	// Fill in defaults on a copy: the map is shared with the configured collection
+	byType := make(map[string]chunk.Config, len(coll.ByType))
+	for fileType, cfg := range coll.ByType {
+		if cfg.Size == 0 {
+			cfg.Size = coll.Chunker.Size
+		}
+		if cfg.Overlap == 0 {
+			cfg.Overlap = coll.Chunker.Overlap
+		}
+		byType[fileType] = cfg
+	}
+	coll.ByType = byType
+	return coll
+}
+
+// ingestOptions builds the pipeline options for a collection
+func (cb *ChatBot) ingestOptions(collection string) (ingest.Options, error) {
+	coll := cb.config.Collection(collection)
+	chunker, err := chunk.New(coll.Chunker, cb.embedder)
+	if err != nil {
+		return ingest.Options{}, fmt.Errorf("collection %s: %w", collection, err)
+	}
+	byType := make(map[string]chunk.Chunker, len(coll.ByType))
+	for fileType, cfg := range coll.ByType {
+		if byType[fileType], err = chunk.New(cfg, cb.embedder); err != nil {
+			return ingest.Options{}, fmt.Errorf("collection %s, type %s: %w", collection, fileType, err)
+		}
+	}
+	return ingest.Options{
+		Collection:   collection,
+		Chunker:      chunker,
+		TypeChunkers: byType,
+	}, nil
+}
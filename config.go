@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -15,6 +17,97 @@ type Config struct {
 	APIKey       string // API key for the selected provider
 	ChatModel    string
 	SystemPrompt string
+
+	// Embeddings (optional, used by the semantic cache)
+	EmbeddingProvider string // openai, gemini or local; empty disables embeddings
+	EmbeddingAPIKey   string
+	EmbeddingModel    string
+
+	// Response cache
+	CacheEnabled           bool
+	CacheDir               string
+	CacheTTL               time.Duration
+	CacheMaxBytes          int64
+	CacheSemantic          bool
+	CacheSemanticThreshold float64
+
+	// Redaction of secrets and personal data before messages leave the machine
+	RedactEnabled  bool
+	RedactRules    []string          // built-in rules to apply; empty means all
+	RedactPatterns map[string]string // custom rules from REDACT_PATTERN_<NAME>=<regex>
+
+	// Ingestion defaults; COLLECTIONS_FILE can override them per collection
+	ChunkStrategy  string // fixed, recursive, markdown or semantic
+	ChunkSize      int    // maximum chunk size in tokens
+	ChunkOverlap   int    // tokens shared by neighbouring chunks
+	Collections    map[string]CollectionConfig
+	RepoIgnoreFile string // extra ignore file honored by /ingest-repo, in .gitignore syntax
+
+	// Tables: CSV and JSON files
+	TableRowsPerChunk int  // records per chunk
+	TableMode         bool // let the model run exact aggregate queries over retrieved tables; /tables toggles it
+
+	// Web crawling with /ingest-url
+	CrawlMaxPages  int           // pages fetched per crawl at most
+	CrawlDelay     time.Duration // wait between requests to a site; robots.txt Crawl-delay can raise it
+	CrawlUserAgent string        // sent with requests and looked up in robots.txt
+
+	// Vector store
+	VectorMetric       string // cosine or dot
+	IndexPersist       bool   // keep the index on disk so it survives restarts
+	IndexDir           string
+	IndexType          string // exact or hnsw
+	HNSWM              int
+	HNSWEfConstruction int
+	HNSWEfSearch       int
+
+	// Retrieval
+	RetrievalMode       string  // hybrid, vector or keyword
+	HybridVectorWeight  float64 // weight of vector results in rank fusion
+	HybridKeywordWeight float64 // weight of BM25 results in rank fusion
+	RRFK                int     // rank constant of reciprocal rank fusion
+	RetrievalExpansion  string  // none, multi-query or hyde; collections can override it
+	MultiQueryCount     int     // paraphrases generated by multi-query expansion
+
+	// Reranking: reorder the first-stage candidates before the best k are kept
+	Rerank           string        // none, pointwise, listwise or endpoint
+	RerankCandidates int           // first-stage results reranked
+	RerankBudget     time.Duration // time allowed before falling back to the first-stage order
+	RerankProvider   string        // LLM for pointwise and listwise; defaults to the rewrite client
+	RerankModel      string        // LLM model, or the model name sent to the endpoint
+	RerankURL        string        // rerank endpoint of an OpenAI-compatible server
+	RerankAPIKey     string
+
+	// Retrieval-augmented answers; /rag and /k change these at runtime
+	RAGEnabled       bool
+	RAGTopK          int // chunks retrieved per question
+	RAGContextTokens int // token budget for retrieved context in the prompt
+
+	// Query rewriting: condense follow-ups into standalone search queries
+	RewriteEnabled  bool
+	RewriteProvider string // defaults to the chat provider and model
+	RewriteModel    string
+	RewriteHistory  int // conversation messages given to the rewriter
+
+	Debug bool // show retrieval details such as the rewritten query; /debug toggles it
+
+	// Guardrails: policy checks on questions, retrieved content and answers
+	GuardMaxInputChars      int      // 0 disables the size check
+	GuardDenyTerms          []string // words or phrases that are not allowed
+	GuardDenyAction         string   // block, warn or rewrite
+	GuardInjectionAction    string   // action for prompt-injection heuristics; allow disables them
+	GuardModeration         bool     // run an LLM moderation classifier on questions and answers
+	GuardModerationProvider string   // defaults to the chat provider
+	GuardModerationModel    string
+	GuardModerationAction   string
+
+	// LLM middleware, applied outermost first (redact, logging, metrics, retry, cache, ratelimit)
+	Middleware     []string
+	LogFile        string
+	RetryAttempts  int
+	RetryBackoff   time.Duration
+	RateLimitRPS   float64 // 0 disables rate limiting
+	RateLimitBurst int
 }
 
 // GetAPIKey returns the API key for the specified provider
@@ -41,6 +134,24 @@ func GetAPIKey(provider string) (string, error) {
 	return apiKey, nil
 }
 
+// defaultModel returns the default chat model for the specified provider
+func defaultModel(provider string) string {
+	switch provider {
+	case "groq":
+		return "llama-3.3-70b-versatile"
+	case "openai":
+		return "gpt-4o-mini"
+	case "anthropic":
+		return "claude-3-5-sonnet-20241022"
+	case "gemini":
+		return "gemini-1.5-flash"
+	case "openrouter":
+		return "meta-llama/llama-3.1-8b-instruct:free"
+	default:
+		return ""
+	}
+}
+
 // LoadConfig loads configuration from environment variables
 func LoadConfig() (*Config, error) {
 	// Load .env file if it exists
@@ -62,24 +173,175 @@ func LoadConfig() (*Config, error) {
 	// Determine default model per provider
 	chatModel := os.Getenv("LLM_MODEL")
 	if chatModel == "" {
-		switch provider {
-		case "groq":
-			chatModel = "llama-3.3-70b-versatile"
-		case "openai":
-			chatModel = "gpt-4o-mini"
-		case "anthropic":
-			chatModel = "claude-3-5-sonnet-20241022"
-		case "gemini":
-			chatModel = "gemini-1.5-flash"
-		case "openrouter":
-			chatModel = "meta-llama/llama-3.1-8b-instruct:free"
+		chatModel = defaultModel(provider)
+	}
+
+	// Embeddings are optional; a missing key only disables the features that need them
+	embeddingProvider := os.Getenv("EMBEDDING_PROVIDER")
+	var embeddingKey string
+	if embeddingProvider != "" && embeddingProvider != "local" {
+		embeddingKey, err = GetAPIKey(embeddingProvider)
+		if err != nil {
+			log.Printf("Embeddings disabled: %v (EMBEDDING_PROVIDER=local works without a key)", err)
+			embeddingProvider = ""
 		}
 	}
 
+	// Per-collection settings are optional
+	collections, err := loadCollections(envString("COLLECTIONS_FILE", "collections.json"))
+	if err != nil {
+		return nil, fmt.Errorf("collections: %w", err)
+	}
+
 	return &Config{
 		Provider:     provider,
 		APIKey:       apiKey,
 		ChatModel:    chatModel,
-		SystemPrompt: "You are a helpful assistant. Use the conversation history to provide contextual responses.",
+		SystemPrompt: envString("SYSTEM_PROMPT", "You are a helpful assistant that answers questions about the user's documents and code. Use the conversation history to understand follow-up questions."),
+
+		EmbeddingProvider: embeddingProvider,
+		EmbeddingAPIKey:   embeddingKey,
+		EmbeddingModel:    os.Getenv("EMBEDDING_MODEL"),
+
+		CacheEnabled:           envBool("CACHE_ENABLED", false),
+		CacheDir:               envString("CACHE_DIR", ".cache/responses"),
+		CacheTTL:               envDuration("CACHE_TTL", 24*time.Hour),
+		CacheMaxBytes:          int64(envInt("CACHE_MAX_MB", 100)) << 20,
+		CacheSemantic:          envBool("CACHE_SEMANTIC", false),
+		CacheSemanticThreshold: envFloat("CACHE_SEMANTIC_THRESHOLD", 0.95),
+
+		RedactEnabled:  envBool("REDACT_ENABLED", false),
+		RedactRules:    envList("REDACT_RULES", ""),
+		RedactPatterns: envPrefixed("REDACT_PATTERN_"),
+
+		ChunkStrategy:  envString("CHUNK_STRATEGY", "recursive"),
+		ChunkSize:      envInt("CHUNK_SIZE", 400),
+		ChunkOverlap:   envInt("CHUNK_OVERLAP", 50),
+		Collections:    collections,
+		RepoIgnoreFile: envString("REPO_IGNORE_FILE", ".ragignore"),
+
+		TableRowsPerChunk: envInt("TABLE_ROWS_PER_CHUNK", 1),
+		TableMode:         envBool("TABLE_MODE", false),
+
+		CrawlMaxPages:  envInt("CRAWL_MAX_PAGES", 100),
+		CrawlDelay:     envDuration("CRAWL_DELAY", time.Second),
+		CrawlUserAgent: envString("CRAWL_USER_AGENT", "go-rag-ai"),
+
+		VectorMetric:       envString("VECTOR_METRIC", "cosine"),
+		IndexPersist:       envBool("INDEX_PERSIST", true),
+		IndexDir:           envString("INDEX_DIR", ".cache/index"),
+		IndexType:          envString("INDEX_TYPE", "exact"),
+		HNSWM:              envInt("HNSW_M", 16),
+		HNSWEfConstruction: envInt("HNSW_EF_CONSTRUCTION", 100),
+		HNSWEfSearch:       envInt("HNSW_EF_SEARCH", 64),
+
+		RetrievalMode:       envString("RETRIEVAL_MODE", "hybrid"),
+		HybridVectorWeight:  envFloat("HYBRID_VECTOR_WEIGHT", 1),
+		HybridKeywordWeight: envFloat("HYBRID_KEYWORD_WEIGHT", 1),
+		RRFK:                envInt("RRF_K", 60),
+		RetrievalExpansion:  envString("RETRIEVAL_EXPANSION", "none"),
+		MultiQueryCount:     envInt("MULTI_QUERY_COUNT", 3),
+
+		Rerank:           envString("RERANK", "none"),
+		RerankCandidates: envInt("RERANK_CANDIDATES", 20),
+		RerankBudget:     envDuration("RERANK_BUDGET", 5*time.Second),
+		RerankProvider:   os.Getenv("RERANK_PROVIDER"),
+		RerankModel:      os.Getenv("RERANK_MODEL"),
+		RerankURL:        os.Getenv("RERANK_URL"),
+		RerankAPIKey:     os.Getenv("RERANK_API_KEY"),
+
+		RAGEnabled:       envBool("RAG_ENABLED", true),
+		RAGTopK:          envInt("RAG_TOP_K", 5),
+		RAGContextTokens: envInt("RAG_CONTEXT_TOKENS", 2000),
+
+		RewriteEnabled:  envBool("REWRITE_ENABLED", true),
+		RewriteProvider: os.Getenv("REWRITE_PROVIDER"),
+		RewriteModel:    os.Getenv("REWRITE_MODEL"),
+		RewriteHistory:  envInt("REWRITE_HISTORY", 6),
+
+		Debug: envBool("DEBUG", false),
+
+		GuardMaxInputChars:      envInt("GUARD_MAX_INPUT_CHARS", 8000),
+		GuardDenyTerms:          envList("GUARD_DENY_TERMS", ""),
+		GuardDenyAction:         envString("GUARD_DENY_ACTION", "block"),
+		GuardInjectionAction:    envString("GUARD_INJECTION_ACTION", "rewrite"),
+		GuardModeration:         envBool("GUARD_MODERATION", false),
+		GuardModerationProvider: os.Getenv("GUARD_MODERATION_PROVIDER"),
+		GuardModerationModel:    os.Getenv("GUARD_MODERATION_MODEL"),
+		GuardModerationAction:   envString("GUARD_MODERATION_ACTION", "block"),
+
+		Middleware:     envList("LLM_MIDDLEWARE", "redact,metrics,retry,cache,ratelimit"),
+		LogFile:        envString("LOG_FILE", "go-rag-ai.log"),
+		RetryAttempts:  envInt("RETRY_ATTEMPTS", 3),
+		RetryBackoff:   envDuration("RETRY_BACKOFF", time.Second),
+		RateLimitRPS:   envFloat("RATE_LIMIT_RPS", 0),
+		RateLimitBurst: envInt("RATE_LIMIT_BURST", 1),
 	}, nil
 }
+
+// envString returns the environment variable or def if it is unset
+func envString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// envList splits a comma-separated environment variable, falling back to def
+func envList(key, def string) []string {
+	var list []string
+	for _, item := range strings.Split(envString(key, def), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, strings.ToLower(item))
+		}
+	}
+	return list
+}
+
+// envPrefixed collects all environment variables starting with prefix, keyed by the rest of the name
+func envPrefixed(prefix string) map[string]string {
+	vars := make(map[string]string)
+	for _, kv := range os.Environ() {
+		key, value, ok := strings.Cut(kv, "=")
+		if ok && strings.HasPrefix(key, prefix) && value != "" {
+			vars[strings.TrimPrefix(key, prefix)] = value
+		}
+	}
+	return vars
+}
+
+// envBool parses a boolean environment variable, falling back to def
+func envBool(key string, def bool) bool {
+	v, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// envInt parses an integer environment variable, falling back to def
+func envInt(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// envFloat parses a float environment variable, falling back to def
+func envFloat(key string, def float64) float64 {
+	v, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// envDuration parses a duration environment variable (e.g. "24h"), falling back to def
+func envDuration(key string, def time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
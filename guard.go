@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go-groq/internal/guard"
+	"go-groq/internal/llm"
+)
+
+// newGuard builds the policy checks configured with GUARD_* variables
+func (cb *ChatBot) newGuard() (*guard.Guard, error) {
+	cfg := cb.config
+	var checks []guard.Check
+
+	if cfg.GuardMaxInputChars > 0 {
+		checks = append(checks, guard.MaxInputSize{Limit: cfg.GuardMaxInputChars})
+	}
+
+	if len(cfg.GuardDenyTerms) > 0 {
+		action, err := guard.ParseAction(cfg.GuardDenyAction)
+		if err != nil {
+			return nil, fmt.Errorf("GUARD_DENY_ACTION: %w", err)
+		}
+		checks = append(checks, guard.NewDenyList(cfg.GuardDenyTerms, action,
+			guard.StageInput, guard.StageContext, guard.StageOutput))
+	}
+
+	injection, err := guard.ParseAction(cfg.GuardInjectionAction)
+	if err != nil {
+		return nil, fmt.Errorf("GUARD_INJECTION_ACTION: %w", err)
+	}
+	if injection != guard.Allow {
+		checks = append(checks, guard.InjectionHeuristics{Action: injection})
+	}
+
+	if cfg.GuardModeration {
+		action, err := guard.ParseAction(cfg.GuardModerationAction)
+		if err != nil {
+			return nil, fmt.Errorf("GUARD_MODERATION_ACTION: %w", err)
+		}
+		client, err := cb.newModerationClient()
+		if err != nil {
+			return nil, fmt.Errorf("moderation client: %w", err)
+		}
+		checks = append(checks, guard.NewModeration(client, action))
+	}
+
+	return guard.New(cb.logger(), checks...), nil
+}
+
+// newModerationClient creates the classifier client. Without GUARD_MODERATION_PROVIDER
+// it follows the chat provider, also after /model: the chat client itself, or the
+// GUARD_MODERATION_MODEL of the current provider. It goes through the same middleware
+// chain as chat requests
+func (cb *ChatBot) newModerationClient() (llm.LLMClient, error) {
+	if provider := cb.config.GuardModerationProvider; provider != "" {
+		apiKey, err := GetAPIKey(provider)
+		if err != nil {
+			return nil, err
+		}
+		model := defaultModel(provider)
+		if cb.config.GuardModerationModel != "" {
+			model = cb.config.GuardModerationModel
+		}
+		return cb.newClient(provider, apiKey, model)
+	}
+
+	var (
+		mu       sync.Mutex
+		client   llm.LLMClient
+		provider string // provider client was built for
+	)
+	return llm.ClientFunc(func(ctx context.Context, messages []llm.Message) (string, error) {
+		cb.mu.RLock()
+		current, chat := cb.config.Provider, cb.llmClient
+		cb.mu.RUnlock()
+		if cb.config.GuardModerationModel == "" {
+			return chat.Generate(ctx, messages)
+		}
+		mu.Lock()
+		if client == nil || provider != current {
+			apiKey, err := GetAPIKey(current)
+			if err == nil {
+				client, err = cb.newClient(current, apiKey, cb.config.GuardModerationModel)
+			}
+			if err != nil {
+				mu.Unlock()
+				return "", fmt.Errorf("moderation client: %w", err)
+			}
+			provider = current
+		}
+		c := client
+		mu.Unlock()
+		return c.Generate(ctx, messages)
+	}), nil
+}
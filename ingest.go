@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go-groq/internal/ingest"
+	"go-groq/internal/rag"
+	"go-groq/internal/vectorstore"
+
+	"github.com/fatih/color"
+)
+
+// Ingest loads the supported files under path into the named collection of the
+// knowledge store, printing progress as it goes. Files that have not changed since
+// they were last ingested are skipped unless force is set, and the chunks of files
+// deleted from under path are removed
+func (cb *ChatBot) Ingest(ctx context.Context, path, collection string, force bool) (ingest.Summary, error) {
+	pipeline, err := cb.pipeline(collection, force)
+	if err != nil {
+		return ingest.Summary{}, err
+	}
+	summary, err := pipeline.Run(ctx, path, printIngestProgress)
+	fmt.Print("\r\033[K") // Clear the progress line
+	return summary, err
+}
+
+// pipeline creates an ingestion pipeline for collection that writes to the vector
+// store and the keyword index
+func (cb *ChatBot) pipeline(collection string, force bool) (*ingest.Pipeline, error) {
+	opts, err := cb.ingestOptions(collection)
+	if err != nil {
+		return nil, err
+	}
+	opts.Manifest = cb.manifest()
+	opts.Force = force
+	sink := ingest.MultiSink(cb.store, cb.keywordIndex())
+	return ingest.New(cb.embedder, sink, opts), nil
+}
+
+// manifest returns the content hashes of the ingested documents, reading them from
+// the vector store on first use
+func (cb *ChatBot) manifest() *ingest.Manifest {
+	cb.manifestOnce.Do(func() {
+		cb.docs = ingest.NewManifest()
+		scanner, ok := cb.store.(vectorstore.Scanner)
+		if !ok {
+			return
+		}
+		err := scanner.Scan(func(c rag.Chunk) bool {
+			cb.docs.Add(c)
+			return true
+		})
+		if err != nil {
+			cb.logger().Printf("ingest manifest: %v", err)
+		}
+	})
+	return cb.docs
+}
+
+// printIngestProgress shows a single updating progress line, and keeps failures visible
+func printIngestProgress(p ingest.Progress) {
+	gray := color.New(color.FgHiBlack)
+	red := color.New(color.FgRed)
+	if p.Err != nil {
+		fmt.Print("\r\033[K")
+		red.Printf("  ✗ %s: %v\n", p.File, p.Err)
+		return
+	}
+	if p.Removed {
+		fmt.Print("\r\033[K")
+		gray.Printf("  − %s (chunks removed)\n", p.File)
+		return
+	}
+	fmt.Print("\r\033[K")
+	for _, w := range p.Warnings {
+		color.New(color.FgYellow).Printf("  ⚠️  %s: %s\n", p.File, w)
+	}
+	gray.Printf("  [%d/%d] %s", p.Done, p.Total, p.File)
+	if p.Chunks > 0 {
+		gray.Printf(" (%d chunks)", p.Chunks)
+	}
+}
+
+// printIngestSummary prints the result of an ingestion
+func (cb *ChatBot) printIngestSummary(s ingest.Summary) {
+	green := color.New(color.FgGreen)
+	yellow := color.New(color.FgYellow)
+	gray := color.New(color.FgHiBlack)
+
+	green.Printf("✅ Ingested %d files into %d chunks", s.Files, s.Chunks)
+	gray.Printf(" in %s\n", s.Duration.Round(100*time.Millisecond))
+	if s.Unchanged > 0 || s.Removed > 0 {
+		gray.Printf("   %d unchanged, %d removed\n", s.Unchanged, s.Removed)
+	}
+	if s.Skipped > 0 || s.Failed > 0 {
+		gray.Printf("   %d skipped, %d failed\n", s.Skipped, s.Failed)
+	}
+	if s.Warnings > 0 {
+		yellow.Printf("   %d warnings, see above\n", s.Warnings)
+	}
+	if !s.Embedded {
+		yellow.Println("   No embedding provider configured (EMBEDDING_PROVIDER); chunks were stored without vectors")
+	}
+	gray.Printf("   Knowledge base: %d chunks\n\n", cb.store.Count())
+}
+
+// ingestArgs are the arguments shared by /ingest and the ingest subcommand
+type ingestArgs struct {
+	collection string
+	paths      []string
+	force      bool // re-ingest unchanged files
+	watch      bool // keep the paths up to date afterwards
+}
+
+// parseIngestArgs parses "[--collection <name>] [--force] [--watch] <path>..."; flags may
+// appear anywhere
+func parseIngestArgs(args []string) (ingestArgs, error) {
+	parsed := ingestArgs{collection: "default"}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--collection" || arg == "-c":
+			if i+1 >= len(args) {
+				return parsed, fmt.Errorf("%s needs a value", arg)
+			}
+			i++
+			parsed.collection = args[i]
+		case strings.HasPrefix(arg, "--collection="):
+			parsed.collection = strings.TrimPrefix(arg, "--collection=")
+		case arg == "--force" || arg == "-f":
+			parsed.force = true
+		case arg == "--watch" || arg == "-w":
+			parsed.watch = true
+		case strings.HasPrefix(arg, "-"):
+			return parsed, fmt.Errorf("unknown flag %s", arg)
+		default:
+			parsed.paths = append(parsed.paths, arg)
+		}
+	}
+	if len(parsed.paths) == 0 {
+		return parsed, fmt.Errorf("no path given")
+	}
+	return parsed, nil
+}
+
+// runIngestCommand implements the "ingest [--collection <name>] [--force] [--watch] <path>..."
+// subcommand. With --watch it keeps the index up to date until interrupted
+func runIngestCommand(ctx context.Context, cb *ChatBot, args []string) error {
+	parsed, err := parseIngestArgs(args)
+	if err != nil {
+		return fmt.Errorf("%v\nusage: %s ingest [--collection <name>] [--force] [--watch] <path>...", err, os.Args[0])
+	}
+	for _, path := range parsed.paths {
+		color.New(color.FgCyan).Printf("📥 Ingesting %s into %s\n", path, parsed.collection)
+		summary, err := cb.Ingest(ctx, path, parsed.collection, parsed.force)
+		if err != nil {
+			return fmt.Errorf("ingest %s: %w", path, err)
+		}
+		cb.printIngestSummary(summary)
+	}
+	if !parsed.watch {
+		return nil
+	}
+
+	color.New(color.FgCyan).Printf("👀 Watching %s for changes (Ctrl+C to stop)\n", strings.Join(parsed.paths, ", "))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error, len(parsed.paths))
+	for _, path := range parsed.paths {
+		pipeline, err := cb.pipeline(parsed.collection, false)
+		if err != nil {
+			return err
+		}
+		go func(path string) {
+			errs <- pipeline.Watch(ctx, path, printWatchEvent)
+		}(path)
+	}
+	var firstErr error
+	for range parsed.paths {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+			cancel() // stop the other watches before the index is closed
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,81 @@
+// Package chunk splits document text into pieces sized for embedding and
+// retrieval.
+package chunk
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go-groq/internal/llm"
+)
+
+// Piece is one chunk of text with any metadata the Chunker adds, such as
+// the Markdown heading path.
+type Piece struct {
+	Text     string
+	Metadata map[string]string
+}
+
+// Chunker splits text into pieces.
+type Chunker interface {
+	Chunk(ctx context.Context, text string) ([]Piece, error)
+}
+
+// Config selects and sizes a Chunker. Sizes are in tokens, estimated with
+// CountTokens.
+type Config struct {
+	Strategy  string  `json:"strategy"`            // fixed, recursive, markdown, semantic or go
+	Size      int     `json:"size,omitempty"`      // maximum tokens per chunk
+	Overlap   int     `json:"overlap,omitempty"`   // tokens repeated between neighbouring chunks
+	Threshold float64 `json:"threshold,omitempty"` // semantic: percentile of sentence distances that starts a new chunk
+}
+
+// Default sizes used when a Config leaves them zero.
+const (
+	DefaultSize    = 400
+	DefaultOverlap = 50
+)
+
+// New returns the Chunker described by cfg. embedder is only needed by the
+// semantic strategy.
+func New(cfg Config, embedder llm.EmbeddingClient) (Chunker, error) {
+	if cfg.Size <= 0 {
+		cfg.Size = DefaultSize
+	}
+	if cfg.Overlap < 0 || cfg.Overlap >= cfg.Size {
+		cfg.Overlap = min(DefaultOverlap, cfg.Size/4)
+	}
+	switch strings.ToLower(cfg.Strategy) {
+	case "fixed":
+		return Fixed{Size: cfg.Size, Overlap: cfg.Overlap}, nil
+	case "", "recursive":
+		return Recursive{Size: cfg.Size, Overlap: cfg.Overlap}, nil
+	case "markdown":
+		return Markdown{Size: cfg.Size, Overlap: cfg.Overlap}, nil
+	case "go":
+		return GoSource{Size: cfg.Size, Overlap: cfg.Overlap}, nil
+	case "semantic":
+		if embedder == nil {
+			return nil, fmt.Errorf("semantic chunking requires an embedding provider (EMBEDDING_PROVIDER)")
+		}
+		return Semantic{Embedder: embedder, Size: cfg.Size, Percentile: cfg.Threshold}, nil
+	default:
+		return nil, fmt.Errorf("unknown chunking strategy %q (supported: fixed, recursive, markdown, semantic, go)", cfg.Strategy)
+	}
+}
+
+// tokenPattern approximates model tokens: every word and every punctuation
+// character counts as one.
+var tokenPattern = regexp.MustCompile(`[\p{L}\p{N}_]+|[^\p{L}\p{N}_\s]`)
+
+// CountTokens estimates the number of model tokens in text.
+func CountTokens(text string) int {
+	return len(tokenPattern.FindAllStringIndex(text, -1))
+}
+
+// tokenSpans returns the byte offsets of every token in text.
+func tokenSpans(text string) [][]int {
+	return tokenPattern.FindAllStringIndex(text, -1)
+}
@@ -0,0 +1,40 @@
+package chunk
+
+import (
+	"context"
+	"strings"
+)
+
+// Fixed cuts text into windows of Size tokens, each starting Size-Overlap
+// tokens after the previous one. The original spacing is preserved.
+type Fixed struct {
+	Size    int
+	Overlap int
+}
+
+// Chunk implements Chunker.
+func (f Fixed) Chunk(ctx context.Context, text string) ([]Piece, error) {
+	var pieces []Piece
+	for _, t := range windows(text, f.Size, f.Overlap) {
+		pieces = append(pieces, Piece{Text: t})
+	}
+	return pieces, nil
+}
+
+// windows returns token windows of text. It is shared with the other
+// strategies as their last resort for text without usable boundaries.
+func windows(text string, size, overlap int) []string {
+	spans := tokenSpans(text)
+	step := max(size-overlap, 1)
+	var out []string
+	for start := 0; start < len(spans); start += step {
+		end := min(start+size, len(spans))
+		if t := strings.TrimSpace(text[spans[start][0]:spans[end-1][1]]); t != "" {
+			out = append(out, t)
+		}
+		if end == len(spans) {
+			break
+		}
+	}
+	return out
+}
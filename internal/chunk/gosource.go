@@ -0,0 +1,166 @@
+package chunk
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"go-groq/internal/rag"
+)
+
+// Metadata keys added by GoSource.
+const (
+	MetaPackage   = "package"   // Go package name
+	MetaKind      = "kind"      // package, func, method, type, const or var
+	MetaSymbol    = "symbol"    // declared name(s), e.g. "ChatBot.Query"
+	MetaReceiver  = "receiver"  // method receiver type, e.g. "*ChatBot"
+	MetaSignature = "signature" // declaration line(s) without the body
+	MetaPart      = "part"      // "i/n" when a declaration was split
+)
+
+// GoSource parses Go files and emits one chunk per top-level declaration
+// (func, method, type, const or var block) together with its doc comment.
+// The package clause and imports form one more chunk. Declarations longer
+// than Size are split, and every part repeats the signature. Files that do
+// not parse are split recursively instead.
+type GoSource struct {
+	Size    int
+	Overlap int
+}
+
+// Chunk implements Chunker.
+func (g GoSource) Chunk(ctx context.Context, text string) ([]Piece, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", text, parser.ParseComments)
+	if err != nil {
+		return Recursive{Size: g.Size, Overlap: g.Overlap}.Chunk(ctx, text)
+	}
+	pkg := file.Name.Name
+	offset := func(p token.Pos) int { return fset.Position(p).Offset }
+	line := func(p token.Pos) int { return fset.Position(p).Line }
+
+	var pieces []Piece
+	emit := func(start, end token.Pos, meta map[string]string) {
+		meta[MetaPackage] = pkg
+		meta[rag.MetaStartLine] = strconv.Itoa(line(start))
+		meta[rag.MetaEndLine] = strconv.Itoa(line(end))
+		pieces = append(pieces, g.split(text[offset(start):offset(end)], meta)...)
+	}
+
+	// Package clause, package doc and imports.
+	start, end := file.Package, file.Name.End()
+	if file.Doc != nil {
+		start = file.Doc.Pos()
+	}
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			end = gen.End()
+		}
+	}
+	emit(start, end, map[string]string{
+		MetaKind:      "package",
+		MetaSymbol:    pkg,
+		MetaSignature: "package " + pkg,
+	})
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			meta := map[string]string{MetaKind: "func", MetaSymbol: d.Name.Name}
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				recv := text[offset(d.Recv.List[0].Type.Pos()):offset(d.Recv.List[0].Type.End())]
+				meta[MetaKind] = "method"
+				meta[MetaReceiver] = recv
+				meta[MetaSymbol] = strings.TrimLeft(baseType(recv), "*") + "." + d.Name.Name
+			}
+			sigEnd := d.End()
+			if d.Body != nil {
+				sigEnd = d.Body.Lbrace
+			}
+			meta[MetaSignature] = strings.TrimSpace(text[offset(d.Pos()):offset(sigEnd)])
+			start := d.Pos()
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+			emit(start, d.End(), meta)
+
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			var names []string
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					names = append(names, s.Name.Name)
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						names = append(names, n.Name)
+					}
+				}
+			}
+			start := d.Pos()
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+			emit(start, d.End(), map[string]string{
+				MetaKind:      d.Tok.String(),
+				MetaSymbol:    strings.Join(names, ", "),
+				MetaSignature: firstLineOf(text[offset(d.Pos()):offset(d.End())]),
+			})
+		}
+	}
+	return pieces, nil
+}
+
+// split returns decl as one piece, or as several when it exceeds Size. Every
+// part after the first starts with the signature so it can be understood on
+// its own.
+func (g GoSource) split(decl string, meta map[string]string) []Piece {
+	if CountTokens(decl) <= g.Size {
+		return []Piece{{Text: decl, Metadata: meta}}
+	}
+	parts := Recursive{Size: g.Size, Overlap: g.Overlap}.chunks(decl)
+	pieces := make([]Piece, 0, len(parts))
+	firstLine, _ := strconv.Atoi(meta[rag.MetaStartLine])
+	searchFrom := 0
+	for i, part := range parts {
+		partMeta := make(map[string]string, len(meta)+1)
+		for k, v := range meta {
+			partMeta[k] = v
+		}
+		partMeta[MetaPart] = fmt.Sprintf("%d/%d", i+1, len(parts))
+		// Narrow the line range to the part itself.
+		if at := strings.Index(decl[searchFrom:], part); at >= 0 {
+			at += searchFrom
+			start := firstLine + strings.Count(decl[:at], "\n")
+			partMeta[rag.MetaStartLine] = strconv.Itoa(start)
+			partMeta[rag.MetaEndLine] = strconv.Itoa(start + strings.Count(part, "\n"))
+			searchFrom = at + 1
+		}
+		if i > 0 {
+			part = fmt.Sprintf("%s { // continued (part %d/%d)\n%s", meta[MetaSignature], i+1, len(parts), part)
+		}
+		pieces = append(pieces, Piece{Text: part, Metadata: partMeta})
+	}
+	return pieces
+}
+
+// baseType strips type parameters from a receiver type, e.g. "*List[T]" -> "*List".
+func baseType(recv string) string {
+	if i := strings.IndexByte(recv, '['); i >= 0 {
+		return recv[:i]
+	}
+	return recv
+}
+
+func firstLineOf(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return strings.TrimSpace(s[:i])
+	}
+	return strings.TrimSpace(s)
+}
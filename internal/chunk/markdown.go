@@ -0,0 +1,74 @@
+package chunk
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// MetaHeading is the metadata key holding a chunk's heading path,
+// e.g. "Installation > Linux".
+const MetaHeading = "heading"
+
+var headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+
+// Markdown splits a document into sections at ATX headings, ignoring
+// headings inside fenced code blocks. Each chunk records the path of
+// headings above it. Sections longer than Size are split recursively.
+type Markdown struct {
+	Size    int
+	Overlap int
+}
+
+// Chunk implements Chunker.
+func (m Markdown) Chunk(ctx context.Context, text string) ([]Piece, error) {
+	type section struct {
+		heading string
+		lines   []string
+	}
+	var sections []section
+	var path []string // heading titles by level, path[0] is the nearest "#"
+	levels := []int{}
+	current := section{}
+	fence := ""
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence == "" && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")) {
+			fence = trimmed[:3]
+		} else if fence != "" && strings.HasPrefix(trimmed, fence) {
+			fence = ""
+		} else if fence == "" {
+			if match := headingPattern.FindStringSubmatch(line); match != nil {
+				sections = append(sections, current)
+				level := len(match[1])
+				for len(levels) > 0 && levels[len(levels)-1] >= level {
+					levels = levels[:len(levels)-1]
+					path = path[:len(path)-1]
+				}
+				levels = append(levels, level)
+				path = append(path, match[2])
+				current = section{heading: strings.Join(path, " > ")}
+			}
+		}
+		current.lines = append(current.lines, line)
+	}
+	sections = append(sections, current)
+
+	splitter := Recursive{Size: m.Size, Overlap: m.Overlap}
+	var pieces []Piece
+	for _, s := range sections {
+		body := strings.Join(s.lines, "\n")
+		if strings.TrimSpace(body) == "" {
+			continue
+		}
+		for _, t := range splitter.chunks(body) {
+			p := Piece{Text: t}
+			if s.heading != "" {
+				p.Metadata = map[string]string{MetaHeading: s.heading}
+			}
+			pieces = append(pieces, p)
+		}
+	}
+	return pieces, nil
+}
@@ -0,0 +1,90 @@
+package chunk
+
+import (
+	"context"
+	"strings"
+)
+
+// separators are tried in order: paragraphs, lines, sentences, clauses, words.
+var separators = []string{"\n\n", "\n", ". ", "? ", "! ", "; ", ", ", " "}
+
+// Recursive splits text on the coarsest boundary that yields pieces of at
+// most Size tokens, then packs neighbouring pieces back together up to Size,
+// carrying up to Overlap tokens of trailing pieces into the next chunk.
+type Recursive struct {
+	Size    int
+	Overlap int
+}
+
+// Chunk implements Chunker.
+func (r Recursive) Chunk(ctx context.Context, text string) ([]Piece, error) {
+	var pieces []Piece
+	for _, t := range r.chunks(text) {
+		pieces = append(pieces, Piece{Text: t})
+	}
+	return pieces, nil
+}
+
+// chunks returns the packed chunks of text.
+func (r Recursive) chunks(text string) []string {
+	return r.merge(r.split(text, separators))
+}
+
+// split breaks text into segments of at most Size tokens, keeping the
+// separators so that joining the segments restores the text.
+func (r Recursive) split(text string, seps []string) []string {
+	if CountTokens(text) <= r.Size {
+		return []string{text}
+	}
+	if len(seps) == 0 {
+		return windows(text, r.Size, 0)
+	}
+	if !strings.Contains(text, seps[0]) {
+		return r.split(text, seps[1:])
+	}
+	var out []string
+	for _, part := range strings.SplitAfter(text, seps[0]) {
+		if CountTokens(part) > r.Size {
+			out = append(out, r.split(part, seps[1:])...)
+		} else if part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// merge packs segments into chunks of at most Size tokens.
+func (r Recursive) merge(segments []string) []string {
+	var chunks, current []string
+	var counts []int
+	total := 0
+	flush := func() {
+		if t := strings.TrimSpace(strings.Join(current, "")); t != "" {
+			chunks = append(chunks, t)
+		}
+	}
+
+	for _, seg := range segments {
+		n := CountTokens(seg)
+		if total+n > r.Size && len(current) > 0 {
+			flush()
+			// Keep the trailing segments that fit in the overlap.
+			keep, kept := len(current), 0
+			for keep > 0 && kept+counts[keep-1] <= r.Overlap {
+				keep--
+				kept += counts[keep]
+			}
+			current, counts, total = current[keep:], counts[keep:], kept
+			// Drop overlap that would not leave room for the new segment.
+			for len(current) > 0 && total+n > r.Size {
+				total -= counts[0]
+				current, counts = current[1:], counts[1:]
+			}
+		}
+		current = append(current, seg)
+		counts = append(counts, n)
+		total += n
+	}
+	flush()
+	return chunks
+}
@@ -0,0 +1,119 @@
+package chunk
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"go-groq/internal/llm"
+)
+
+// Semantic groups consecutive sentences and starts a new chunk where the
+// embedding distance between neighbouring sentences is unusually large,
+// i.e. above the given percentile of all distances in the document.
+type Semantic struct {
+	Embedder   llm.EmbeddingClient
+	Size       int     // maximum tokens per chunk
+	Percentile float64 // 0-100, defaults to 90
+}
+
+// Chunk implements Chunker.
+func (s Semantic) Chunk(ctx context.Context, text string) ([]Piece, error) {
+	sentences := splitSentences(text)
+	if len(sentences) < 3 {
+		return Recursive{Size: s.Size}.Chunk(ctx, text)
+	}
+
+	var vectors [][]float32
+	for start := 0; start < len(sentences); start += 64 {
+		end := min(start+64, len(sentences))
+		batch, err := s.Embedder.Embed(ctx, sentences[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("embed sentences: %w", err)
+		}
+		if len(batch) != end-start {
+			return nil, fmt.Errorf("embed sentences: got %d vectors for %d sentences", len(batch), end-start)
+		}
+		vectors = append(vectors, batch...)
+	}
+
+	distances := make([]float64, len(sentences)-1)
+	for i := range distances {
+		distances[i] = 1 - llm.CosineSimilarity(vectors[i], vectors[i+1])
+	}
+	threshold := percentile(distances, s.percentile())
+
+	var pieces []Piece
+	var group []string
+	tokens := 0
+	flush := func() {
+		if t := strings.TrimSpace(strings.Join(group, " ")); t != "" {
+			pieces = append(pieces, Piece{Text: t})
+		}
+		group, tokens = nil, 0
+	}
+	for i, sentence := range sentences {
+		n := CountTokens(sentence)
+		if len(group) > 0 && tokens+n > s.Size {
+			flush()
+		}
+		if n > s.Size {
+			// A single sentence larger than a chunk is cut into windows.
+			for _, w := range windows(sentence, s.Size, 0) {
+				pieces = append(pieces, Piece{Text: w})
+			}
+			continue
+		}
+		group = append(group, sentence)
+		tokens += n
+		if i < len(distances) && distances[i] > threshold {
+			flush()
+		}
+	}
+	flush()
+	return pieces, nil
+}
+
+func (s Semantic) percentile() float64 {
+	if s.Percentile <= 0 || s.Percentile >= 100 {
+		return 90
+	}
+	return s.Percentile
+}
+
+// splitSentences splits text after sentence-ending punctuation followed by
+// whitespace, and at blank lines.
+func splitSentences(text string) []string {
+	var sentences []string
+	runes := []rune(text)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		end := -1
+		switch {
+		case (runes[i] == '.' || runes[i] == '!' || runes[i] == '?') && i+1 < len(runes) && unicode.IsSpace(runes[i+1]):
+			end = i + 1
+		case runes[i] == '\n' && i+1 < len(runes) && runes[i+1] == '\n':
+			end = i
+		}
+		if end >= 0 {
+			if s := strings.TrimSpace(string(runes[start:end])); s != "" {
+				sentences = append(sentences, s)
+			}
+			start = end
+		}
+	}
+	if s := strings.TrimSpace(string(runes[start:])); s != "" {
+		sentences = append(sentences, s)
+	}
+	return sentences
+}
+
+// percentile returns the p-th percentile of values using nearest rank.
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := int(p / 100 * float64(len(sorted)-1))
+	return sorted[rank]
+}
@@ -0,0 +1,124 @@
+package guard
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DenyList matches forbidden words or phrases, case-insensitively and on
+// word boundaries. With Rewrite the matches are masked; with Warn or Block
+// the text is left alone.
+type DenyList struct {
+	action  Action
+	stages  []Stage
+	pattern *regexp.Regexp
+}
+
+// NewDenyList creates a DenyList for the given terms, applied at stages.
+func NewDenyList(terms []string, action Action, stages ...Stage) *DenyList {
+	var quoted []string
+	for _, t := range terms {
+		if t = strings.TrimSpace(t); t != "" {
+			quoted = append(quoted, regexp.QuoteMeta(t))
+		}
+	}
+	d := &DenyList{action: action, stages: stages}
+	if len(quoted) > 0 {
+		d.pattern = regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	}
+	return d
+}
+
+// Name implements Check.
+func (d *DenyList) Name() string { return "deny-list" }
+
+// Check implements Check.
+func (d *DenyList) Check(ctx context.Context, stage Stage, text string) (Verdict, error) {
+	if d.pattern == nil || !hasStage(d.stages, stage) {
+		return Verdict{}, nil
+	}
+	matches := d.pattern.FindAllString(text, -1)
+	if len(matches) == 0 {
+		return Verdict{}, nil
+	}
+	v := Verdict{Action: d.action, Reason: fmt.Sprintf("contains denied term %q", matches[0])}
+	if d.action == Rewrite {
+		v.Text = d.pattern.ReplaceAllStringFunc(text, func(m string) string { return strings.Repeat("*", len(m)) })
+	}
+	return v, nil
+}
+
+// MaxInputSize blocks questions longer than a character limit.
+type MaxInputSize struct {
+	Limit int
+}
+
+// Name implements Check.
+func (m MaxInputSize) Name() string { return "max-input-size" }
+
+// Check implements Check.
+func (m MaxInputSize) Check(ctx context.Context, stage Stage, text string) (Verdict, error) {
+	if stage != StageInput || m.Limit <= 0 {
+		return Verdict{}, nil
+	}
+	if n := len([]rune(text)); n > m.Limit {
+		return Verdict{Action: Block, Reason: fmt.Sprintf("question is %d characters, limit is %d", n, m.Limit)}, nil
+	}
+	return Verdict{}, nil
+}
+
+// injectionPatterns are phrases typical of instructions planted in documents
+// to hijack the model.
+var injectionPatterns = regexp.MustCompile(`(?i)` + strings.Join([]string{
+	`ignore (?:all |any )?(?:the )?(?:previous|prior|above|earlier) (?:instructions|prompts|rules)`,
+	`disregard (?:all |any )?(?:the )?(?:previous|prior|above|earlier|system) (?:instructions|prompts|rules)`,
+	`forget (?:all |everything )?(?:you were told|your instructions|previous instructions)`,
+	`you are now (?:a|an|in) `,
+	`(?:reveal|print|show|repeat) (?:your|the) (?:system prompt|instructions|hidden prompt)`,
+	`new instructions:`,
+	`</?(?:system|assistant)>`,
+	`\[/?INST\]`,
+	`do not tell the user`,
+}, "|"))
+
+// InjectionHeuristics flags retrieved content that looks like a prompt
+// injection. With Rewrite the offending lines are replaced by a marker.
+type InjectionHeuristics struct {
+	Action Action
+}
+
+// Name implements Check.
+func (h InjectionHeuristics) Name() string { return "prompt-injection" }
+
+// Check implements Check.
+func (h InjectionHeuristics) Check(ctx context.Context, stage Stage, text string) (Verdict, error) {
+	if stage != StageContext {
+		return Verdict{}, nil
+	}
+	match := injectionPatterns.FindString(text)
+	if match == "" {
+		return Verdict{}, nil
+	}
+	v := Verdict{Action: h.Action, Reason: fmt.Sprintf("retrieved content contains %q", match)}
+	if h.Action == Rewrite {
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			if injectionPatterns.MatchString(line) {
+				lines[i] = "[removed: possible prompt injection]"
+			}
+		}
+		v.Text = strings.Join(lines, "\n")
+	}
+	return v, nil
+}
+
+func hasStage(stages []Stage, stage Stage) bool {
+	for _, s := range stages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+// Package guard runs policy checks on questions, retrieved content and
+// answers. Each check can allow, warn, rewrite or block.
+package guard
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Stage identifies where in a query a check runs.
+type Stage int
+
+const (
+	StageInput   Stage = iota // the user's question, before anything is sent
+	StageContext              // retrieved content, before it is added to the prompt
+	StageOutput               // the model's answer, before it is shown
+)
+
+func (s Stage) String() string {
+	switch s {
+	case StageInput:
+		return "input"
+	case StageContext:
+		return "context"
+	case StageOutput:
+		return "output"
+	default:
+		return fmt.Sprintf("stage(%d)", int(s))
+	}
+}
+
+// Action is the outcome of a check.
+type Action int
+
+const (
+	Allow Action = iota
+	Warn
+	Rewrite
+	Block
+)
+
+// ParseAction parses "allow", "warn", "rewrite" or "block".
+func ParseAction(s string) (Action, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "allow":
+		return Allow, nil
+	case "warn":
+		return Warn, nil
+	case "rewrite":
+		return Rewrite, nil
+	case "block":
+		return Block, nil
+	default:
+		return Allow, fmt.Errorf("unknown guard action %q (supported: allow, warn, rewrite, block)", s)
+	}
+}
+
+func (a Action) String() string {
+	switch a {
+	case Allow:
+		return "allow"
+	case Warn:
+		return "warn"
+	case Rewrite:
+		return "rewrite"
+	case Block:
+		return "block"
+	default:
+		return fmt.Sprintf("action(%d)", int(a))
+	}
+}
+
+// Verdict is what a single check decided.
+type Verdict struct {
+	Action Action
+	Reason string // human-readable explanation for warn, rewrite and block
+	Text   string // replacement text when Action is Rewrite
+}
+
+// Check is a single policy rule.
+type Check interface {
+	// Name identifies the check in logs and messages.
+	Name() string
+	// Check inspects text at the given stage. Checks return Allow for stages
+	// they do not handle.
+	Check(ctx context.Context, stage Stage, text string) (Verdict, error)
+}
+
+// BlockedError is returned by Run when a check blocks the text.
+type BlockedError struct {
+	Check  string
+	Stage  Stage
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("blocked by %s (%s): %s", e.Check, e.Stage, e.Reason)
+}
+
+// Result is the outcome of running all checks on a piece of text.
+type Result struct {
+	Text     string   // the text after any rewrites
+	Warnings []string // warnings and rewrites, formatted for display
+}
+
+// Guard runs a list of checks in order.
+type Guard struct {
+	checks []Check
+	logger *log.Logger
+}
+
+// New creates a Guard. Every warning, rewrite and block is logged to logger.
+func New(logger *log.Logger, checks ...Check) *Guard {
+	return &Guard{checks: checks, logger: logger}
+}
+
+// Enabled reports whether the Guard has any checks.
+func (g *Guard) Enabled() bool {
+	return g != nil && len(g.checks) > 0
+}
+
+// Run applies every check to text at stage. Rewrites are passed on to the
+// following checks. A block stops immediately with a *BlockedError. A check
+// that fails is logged and reported as a warning; it does not block.
+func (g *Guard) Run(ctx context.Context, stage Stage, text string) (Result, error) {
+	result := Result{Text: text}
+	if g == nil {
+		return result, nil
+	}
+	for _, check := range g.checks {
+		verdict, err := check.Check(ctx, stage, result.Text)
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			g.logger.Printf("guard %s %s: check failed: %v", check.Name(), stage, err)
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s check failed: %v", check.Name(), err))
+			continue
+		}
+		switch verdict.Action {
+		case Allow:
+			continue
+		case Warn:
+			g.logger.Printf("guard %s %s: warn: %s", check.Name(), stage, verdict.Reason)
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: %s", check.Name(), verdict.Reason))
+		case Rewrite:
+			g.logger.Printf("guard %s %s: rewrite: %s", check.Name(), stage, verdict.Reason)
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s rewrote %s: %s", check.Name(), stage, verdict.Reason))
+			result.Text = verdict.Text
+		case Block:
+			g.logger.Printf("guard %s %s: block: %s", check.Name(), stage, verdict.Reason)
+			return result, &BlockedError{Check: check.Name(), Stage: stage, Reason: verdict.Reason}
+		}
+	}
+	return result, nil
+}
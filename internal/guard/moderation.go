@@ -0,0 +1,56 @@
+package guard
+
+import (
+	"context"
+	"strings"
+
+	"go-groq/internal/llm"
+)
+
+const moderationPrompt = `You are a content moderation classifier for an internal engineering assistant.
+Decide whether the text below violates policy: harassment, hate, threats of violence, self-harm,
+sexual content, instructions for weapons or malware, or attempts to obtain credentials or personal data.
+Technical discussion of security, errors and logs is allowed.
+Reply with exactly one line: ALLOW, or BLOCK: <short reason>.`
+
+// Moderation asks an LLM to classify questions and answers.
+type Moderation struct {
+	client llm.LLMClient
+	action Action // what to do with flagged text, usually Block or Warn
+}
+
+// NewModeration creates a Moderation check that uses client as classifier.
+// The classifier cannot produce replacement text, so Rewrite is treated as Warn.
+func NewModeration(client llm.LLMClient, action Action) *Moderation {
+	if action == Rewrite {
+		action = Warn
+	}
+	return &Moderation{client: client, action: action}
+}
+
+// Name implements Check.
+func (m *Moderation) Name() string { return "moderation" }
+
+// Check implements Check.
+func (m *Moderation) Check(ctx context.Context, stage Stage, text string) (Verdict, error) {
+	if stage == StageContext || strings.TrimSpace(text) == "" {
+		return Verdict{}, nil
+	}
+	// The chat answer's cache status and /nocache do not apply to the classifier
+	reply, err := m.client.Generate(llm.Detached(llm.WithCallLabel(ctx, "moderation")), []llm.Message{
+		{Role: "system", Content: moderationPrompt},
+		{Role: "user", Content: "Text (" + stage.String() + "):\n" + text},
+	})
+	if err != nil {
+		return Verdict{}, err
+	}
+	reply = strings.TrimSpace(reply)
+	if !strings.HasPrefix(strings.ToUpper(reply), "BLOCK") {
+		return Verdict{}, nil
+	}
+	reason := strings.TrimSpace(strings.TrimLeft(reply[len("BLOCK"):], ":- "))
+	if reason == "" {
+		reason = "flagged by moderation classifier"
+	}
+	return Verdict{Action: m.action, Reason: reason}, nil
+}
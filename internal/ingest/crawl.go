@@ -0,0 +1,450 @@
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"go-groq/internal/rag"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// CrawlOptions configures Pipeline.Crawl.
+type CrawlOptions struct {
+	MaxDepth  int           // links followed from the start page; 0 fetches the start page only
+	SameHost  bool          // only follow links to the host of the start page
+	MaxPages  int           // pages fetched at most; defaults to 100
+	Delay     time.Duration // wait between requests to a host; a longer robots.txt Crawl-delay wins
+	UserAgent string        // sent with every request and looked up in robots.txt; defaults to "go-rag-ai"
+	Client    *http.Client  // defaults to a client with a 30 second timeout
+}
+
+// CrawlSummary describes a finished crawl.
+type CrawlSummary struct {
+	Summary
+	Pages      int  // pages fetched
+	Disallowed int  // pages not fetched because robots.txt disallows them
+	Duplicates int  // pages whose canonical URL was stored already
+	Limited    bool // MaxPages stopped the crawl before every link was followed
+}
+
+// defaultUserAgent identifies the crawler to web servers.
+const defaultUserAgent = "go-rag-ai"
+
+// skipExtensions are links that never lead to a web page, left out without
+// a request.
+var skipExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true, ".ico": true,
+	".css": true, ".js": true, ".json": true, ".xml": true, ".zip": true, ".gz": true, ".tar": true,
+	".pdf": true, ".mp3": true, ".mp4": true, ".webm": true, ".woff": true, ".woff2": true,
+}
+
+// crawlTarget is a page waiting to be fetched.
+type crawlTarget struct {
+	u     *url.URL
+	depth int // links followed from the start page
+}
+
+// crawler holds the state of one crawl.
+type crawler struct {
+	p       *Pipeline
+	opts    CrawlOptions
+	start   *url.URL
+	robots  map[string]*robotsRules // by scheme and host
+	next    map[string]time.Time    // earliest time of the next request, by host
+	seen    map[string]bool         // URLs queued or fetched
+	stored  map[string]bool         // canonical URLs of the pages fetched
+	summary CrawlSummary
+
+	checkNext func(*http.Request, []*http.Request) error // CheckRedirect of the client passed in, if any
+}
+
+// Crawl ingests the web page at start and, up to opts.MaxDepth links away,
+// the pages it links to, in breadth-first order. It honors robots.txt and
+// the noindex and nofollow robots meta tags, spaces the requests to a host by
+// opts.Delay, and stops after opts.MaxPages pages. The main content of every
+// page is stored as Markdown, like HTMLLoader does for files, under its
+// canonical URL, which is recorded as the url and path metadata; pages found
+// under a canonical URL already stored are skipped. progress is called after
+// every page, with its URL as File.
+func (p *Pipeline) Crawl(ctx context.Context, start string, opts CrawlOptions, progress func(Progress)) (CrawlSummary, error) {
+	begin := time.Now()
+	u, err := url.Parse(start)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return CrawlSummary{}, fmt.Errorf("%q is not an http or https URL", start)
+	}
+	if opts.MaxPages <= 0 {
+		opts.MaxPages = 100
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = defaultUserAgent
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	client := *opts.Client
+	c := &crawler{
+		p:      p,
+		opts:   opts,
+		start:  normalizeURL(u),
+		robots: make(map[string]*robotsRules),
+		next:   make(map[string]time.Time),
+		seen:   make(map[string]bool),
+		stored: make(map[string]bool),
+	}
+	c.checkNext = client.CheckRedirect
+	client.CheckRedirect = c.checkRedirect
+	c.opts.Client = &client
+	c.summary.Embedded = p.embedder != nil
+	report := func(event Progress) {
+		if progress != nil {
+			progress(event)
+		}
+	}
+
+	queue := []crawlTarget{{u: c.start}}
+	c.seen[c.start.String()] = true
+	for len(queue) > 0 {
+		if err := ctx.Err(); err != nil {
+			return c.summary, err
+		}
+		if c.summary.Pages >= opts.MaxPages {
+			c.summary.Limited = true
+			break
+		}
+		target := queue[0]
+		queue = queue[1:]
+
+		rules, err := c.rulesFor(ctx, target.u)
+		if err != nil {
+			if ctx.Err() != nil {
+				return c.summary, ctx.Err()
+			}
+			c.summary.Failed++
+			report(Progress{File: target.u.String(), Err: err})
+			continue
+		}
+		if !rules.allowed(target.u) {
+			c.summary.Disallowed++
+			continue
+		}
+
+		c.summary.Pages++
+		event := Progress{File: target.u.String(), Done: c.summary.Pages, Total: min(c.summary.Pages+len(queue), opts.MaxPages)}
+		n, links, err := c.ingestPage(ctx, target.u, max(rules.delay, opts.Delay))
+		switch {
+		case errors.Is(err, errSkipped):
+			event.Skipped = true
+			c.summary.Skipped++
+		case errors.Is(err, errDuplicate):
+			event.Skipped = true
+			c.summary.Duplicates++
+		case errors.Is(err, errDisallowed):
+			c.summary.Pages--
+			c.summary.Disallowed++
+			continue
+		case errors.Is(err, errUnchanged):
+			event.Unchanged = true
+			c.summary.Unchanged++
+		case err != nil:
+			if ctx.Err() != nil {
+				return c.summary, ctx.Err()
+			}
+			event.Err = err
+			c.summary.Failed++
+		default:
+			event.Chunks = n
+			c.summary.Files++
+			c.summary.Chunks += n
+		}
+		report(event)
+
+		if target.depth >= opts.MaxDepth {
+			continue
+		}
+		for _, link := range links {
+			key := link.String()
+			if c.seen[key] || (opts.SameHost && link.Host != c.start.Host) {
+				continue
+			}
+			c.seen[key] = true
+			queue = append(queue, crawlTarget{u: link, depth: target.depth + 1})
+		}
+	}
+	c.summary.Duration = time.Since(begin)
+	return c.summary, nil
+}
+
+// errDuplicate marks pages whose canonical URL was stored already.
+var errDuplicate = errors.New("duplicate")
+
+// errDisallowed marks pages that redirect to a URL robots.txt disallows.
+var errDisallowed = errors.New("disallowed by robots.txt")
+
+// ingestPage fetches and stores one page. It returns the number of chunks
+// written and the links the page allows to be followed, which are returned
+// even when the page itself is not stored.
+func (c *crawler) ingestPage(ctx context.Context, u *url.URL, delay time.Duration) (int, []*url.URL, error) {
+	resp, data, err := c.get(ctx, u, c.p.opts.MaxFileSize, delay)
+	if err != nil {
+		return 0, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, nil, fmt.Errorf("HTTP %s", resp.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return 0, nil, errSkipped
+	}
+	final := normalizeURL(resp.Request.URL) // after redirects, which checkRedirect allowed
+
+	root, err := parseHTML(data)
+	if err != nil {
+		return 0, nil, err
+	}
+	page := readLinks(root, final)
+	links := page.links
+	if page.nofollow {
+		links = nil
+	}
+
+	docID := final.String()
+	if page.canonical != nil {
+		docID = page.canonical.String()
+	}
+	c.seen[final.String()] = true
+	c.seen[docID] = true // links to the canonical URL need not be fetched
+	if c.stored[docID] {
+		return 0, links, errDuplicate
+	}
+	c.stored[docID] = true
+	if page.noindex {
+		return 0, links, errSkipped
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	opts := c.p.opts
+	if opts.Manifest != nil && !opts.Force && opts.Manifest.unchanged(docID, hash, opts.Collection) {
+		return 0, links, errUnchanged
+	}
+	modified := time.Now()
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		modified = t
+	}
+	meta := map[string]string{
+		rag.MetaPath:       docID,
+		rag.MetaURL:        docID,
+		rag.MetaMTime:      modified.UTC().Format(time.RFC3339),
+		rag.MetaHash:       hash,
+		rag.MetaCollection: opts.Collection,
+		rag.MetaType:       "html",
+		rag.MetaTitle:      docID,
+	}
+	for k, v := range opts.Metadata {
+		meta[k] = v
+	}
+	n, err := c.p.store(ctx, docID, hash, htmlDocument(docID, root), meta)
+	return n, links, err
+}
+
+// get fetches u, waiting until delay has passed since the previous request
+// to its host. Bodies larger than limit are skipped.
+func (c *crawler) get(ctx context.Context, u *url.URL, limit int64, delay time.Duration) (*http.Response, []byte, error) {
+	if wait := time.Until(c.next[u.Host]); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	defer func() { c.next[u.Host] = time.Now().Add(delay) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", c.opts.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.5")
+	resp, err := c.opts.Client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, nil, errSkipped
+	}
+	return resp, data, nil
+}
+
+// checkRedirect holds redirects to the rules links are followed by: a page
+// redirecting to another host is skipped with SameHost, and one redirecting
+// to a URL robots.txt disallows is not fetched. Redirects of robots.txt
+// itself are followed as RFC 9309 asks.
+func (c *crawler) checkRedirect(req *http.Request, via []*http.Request) error {
+	if c.checkNext != nil {
+		if err := c.checkNext(req, via); err != nil {
+			return err
+		}
+	} else if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if via[0].URL.Path == "/robots.txt" {
+		return nil
+	}
+	u := normalizeURL(req.URL)
+	if c.opts.SameHost && u.Host != c.start.Host {
+		return errSkipped
+	}
+	rules, err := c.rulesFor(req.Context(), u)
+	if err != nil && req.Context().Err() != nil {
+		return err
+	}
+	if !rules.allowed(u) {
+		return errDisallowed
+	}
+	return nil
+}
+
+// rulesFor returns the robots.txt rules of the site of u, fetching them on
+// first use. A missing robots.txt allows everything; one that cannot be
+// fetched disallows everything, and the error is returned once.
+func (c *crawler) rulesFor(ctx context.Context, u *url.URL) (*robotsRules, error) {
+	site := u.Scheme + "://" + u.Host
+	if rules, ok := c.robots[site]; ok {
+		return rules, nil
+	}
+	robotsURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	resp, data, err := c.get(ctx, robotsURL, 512<<10, c.opts.Delay)
+	switch {
+	case errors.Is(err, errSkipped):
+		c.robots[site] = allowAll // larger than the 500 KiB crawlers need to read
+	case err != nil:
+		if ctx.Err() != nil {
+			return disallowAll, err
+		}
+		c.robots[site] = disallowAll
+		return disallowAll, fmt.Errorf("robots.txt unavailable, not crawling %s: %w", u.Host, err)
+	case resp.StatusCode >= 500:
+		c.robots[site] = disallowAll
+		return disallowAll, fmt.Errorf("robots.txt unavailable (HTTP %s), not crawling %s", resp.Status, u.Host)
+	case resp.StatusCode >= 400:
+		c.robots[site] = allowAll
+	default:
+		c.robots[site] = parseRobots(data, c.opts.UserAgent)
+	}
+	return c.robots[site], nil
+}
+
+// pageLinks is what a page says about itself and where it leads.
+type pageLinks struct {
+	links     []*url.URL // normalized, without duplicates
+	canonical *url.URL   // the rel=canonical link, if any
+	noindex   bool       // robots meta tag: do not store the page
+	nofollow  bool       // robots meta tag: do not follow its links
+}
+
+// readLinks collects the links of a page and its robots directives. Links
+// are resolved against base, or the page's base element.
+func readLinks(root *html.Node, base *url.URL) pageLinks {
+	var page pageLinks
+	if b := findFirst(root, atom.Base); b != nil {
+		if href, ok := attrOK(b, "href"); ok {
+			if u, err := base.Parse(strings.TrimSpace(href)); err == nil {
+				base = u
+			}
+		}
+	}
+	resolve := func(href string) *url.URL {
+		u, err := base.Parse(strings.TrimSpace(href))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil
+		}
+		return normalizeURL(u)
+	}
+	seen := make(map[string]bool)
+	walk(root, func(n *html.Node) {
+		if n.Type != html.ElementNode {
+			return
+		}
+		rel := strings.Fields(strings.ToLower(attr(n, "rel")))
+		switch n.DataAtom {
+		case atom.Meta:
+			name := strings.ToLower(attr(n, "name"))
+			if name == "robots" || name == strings.ToLower(defaultUserAgent) {
+				for _, d := range strings.Split(strings.ToLower(attr(n, "content")), ",") {
+					switch strings.TrimSpace(d) {
+					case "noindex":
+						page.noindex = true
+					case "nofollow":
+						page.nofollow = true
+					case "none":
+						page.noindex, page.nofollow = true, true
+					}
+				}
+			}
+		case atom.Link:
+			if contains(rel, "canonical") && page.canonical == nil {
+				page.canonical = resolve(attr(n, "href"))
+			}
+		case atom.A, atom.Area:
+			href, ok := attrOK(n, "href")
+			if !ok || contains(rel, "nofollow") {
+				return
+			}
+			u := resolve(href)
+			if u == nil || skipExtensions[strings.ToLower(path.Ext(u.Path))] || seen[u.String()] {
+				return
+			}
+			seen[u.String()] = true
+			page.links = append(page.links, u)
+		}
+	})
+	return page
+}
+
+// contains reports whether list holds s.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeURL returns u in the form used to recognize pages seen before:
+// lower-case scheme and host, no default port, no fragment and a path of at
+// least "/".
+func normalizeURL(u *url.URL) *url.URL {
+	n := *u
+	n.Scheme = strings.ToLower(n.Scheme)
+	n.Host = strings.ToLower(n.Host)
+	if port := n.Port(); (n.Scheme == "http" && port == "80") || (n.Scheme == "https" && port == "443") {
+		n.Host = strings.TrimSuffix(n.Host, ":"+port)
+	}
+	n.Fragment, n.RawFragment = "", ""
+	if n.Path == "" {
+		n.Path, n.RawPath = "/", ""
+	}
+	n.ForceQuery = false
+	n.User = nil
+	return &n
+}
@@ -0,0 +1,156 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"go-groq/internal/rag"
+)
+
+// memorySink records the documents a Pipeline stores.
+type memorySink struct {
+	mu   sync.Mutex
+	docs map[string]int // chunks by document
+}
+
+func (s *memorySink) Upsert(ctx context.Context, chunks []rag.Chunk) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range chunks {
+		s.docs[c.DocID]++
+	}
+	return nil
+}
+
+func (s *memorySink) DeleteDoc(ctx context.Context, docID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.docs, docID)
+	return nil
+}
+
+// testSite serves a small web site linking to other, whose requests it counts.
+func testSite(t *testing.T, other string) *httptest.Server {
+	t.Helper()
+	page := func(head, body string) string {
+		return fmt.Sprintf("<html><head><title>Page</title>%s</head><body><main><p>Some text about the crawler.</p>%s</main></body></html>", head, body)
+	}
+	pages := map[string]string{
+		"/": page("", `<a href="/a">A</a> <a href="/a-copy">A again</a> <a href="/b">B</a>
+			<a href="/private/x">Private</a> <a href="/moved">Moved</a> <a href="/away">Away</a>
+			<a href="`+other+`/">Other host</a>`),
+		"/a":      page(`<link rel="canonical" href="/a">`, `<a href="/c">C</a>`),
+		"/a-copy": page(`<link rel="canonical" href="/a">`, ""),
+		"/b":      page("", ""),
+		"/c":      page("", ""),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nDisallow: /private/\n")
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/private/y", http.StatusFound)
+	})
+	mux.HandleFunc("/away", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other+"/", http.StatusFound)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected request for %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, body)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCrawl(t *testing.T) {
+	var otherHits atomic.Int32
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		otherHits.Add(1)
+		fmt.Fprint(w, "<html><body><p>Elsewhere.</p></body></html>")
+	}))
+	defer other.Close()
+	site := testSite(t, other.URL)
+
+	sink := &memorySink{docs: make(map[string]int)}
+	p := New(nil, sink, Options{})
+	got, err := p.Crawl(context.Background(), site.URL+"/", CrawlOptions{MaxDepth: 2, SameHost: true, Client: site.Client()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// /, /a, /a-copy, /b, /away and /c are fetched; /private/x and /moved,
+	// which redirects under /private/, are disallowed
+	want := CrawlSummary{Pages: 6, Disallowed: 2, Duplicates: 1}
+	want.Files, want.Skipped = 4, 1
+	if got.Pages != want.Pages || got.Disallowed != want.Disallowed || got.Duplicates != want.Duplicates ||
+		got.Files != want.Files || got.Skipped != want.Skipped || got.Failed != 0 || got.Limited {
+		t.Errorf("summary = %+v, want %+v", got, want)
+	}
+	if n := otherHits.Load(); n != 0 {
+		t.Errorf("other host got %d requests, want none with SameHost", n)
+	}
+	for _, doc := range []string{"/", "/a", "/b", "/c"} {
+		if sink.docs[site.URL+doc] == 0 {
+			t.Errorf("%s was not stored", doc)
+		}
+	}
+	if len(sink.docs) != 4 {
+		t.Errorf("stored %d documents, want 4", len(sink.docs))
+	}
+}
+
+func TestCrawlMaxPages(t *testing.T) {
+	site := testSite(t, "http://other.invalid")
+
+	sink := &memorySink{docs: make(map[string]int)}
+	p := New(nil, sink, Options{})
+	got, err := p.Crawl(context.Background(), site.URL+"/", CrawlOptions{MaxDepth: 2, SameHost: true, MaxPages: 2, Client: site.Client()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Pages != 2 || got.Files != 2 || !got.Limited {
+		t.Errorf("summary = %+v, want 2 pages, 2 files and Limited", got)
+	}
+}
+
+func TestCrawlRobotsUnavailable(t *testing.T) {
+	var pageHits atomic.Int32
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		pageHits.Add(1)
+	}))
+	defer site.Close()
+
+	p := New(nil, &memorySink{docs: make(map[string]int)}, Options{})
+	var reported []Progress
+	got, err := p.Crawl(context.Background(), site.URL+"/", CrawlOptions{Client: site.Client()}, func(p Progress) {
+		reported = append(reported, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Failed != 1 || got.Disallowed != 0 || got.Pages != 0 {
+		t.Errorf("summary = %+v, want 1 failed page and nothing disallowed or fetched", got)
+	}
+	if len(reported) != 1 || reported[0].Err == nil {
+		t.Errorf("progress = %+v, want the robots.txt error", reported)
+	}
+	if n := pageHits.Load(); n != 0 {
+		t.Errorf("site got %d page requests, want none", n)
+	}
+}
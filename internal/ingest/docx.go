@@ -0,0 +1,266 @@
+package ingest
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go-groq/internal/rag"
+)
+
+// DOCXLoader extracts the text of Word documents from the OOXML package as
+// Markdown: paragraphs, headings (from the heading styles or outline levels),
+// list items and tables. Text boxes are included once; deleted revisions,
+// field codes, headers and footers are left out. The title in the document
+// properties becomes the document title.
+type DOCXLoader struct{}
+
+// Load implements Loader.
+func (DOCXLoader) Load(path string, data []byte) (*Document, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("read DOCX: %w", err)
+	}
+	body, err := zipFile(zr, "word/document.xml")
+	if err != nil {
+		return nil, fmt.Errorf("read DOCX: %w", err)
+	}
+	levels := map[string]int{}
+	if styles, err := zipFile(zr, "word/styles.xml"); err == nil {
+		levels = headingStyles(styles)
+	}
+	text, err := docxMarkdown(body, levels)
+	if err != nil {
+		return nil, fmt.Errorf("read DOCX: %w", err)
+	}
+
+	doc := &Document{Path: path}
+	if core, err := zipFile(zr, "docProps/core.xml"); err == nil {
+		var props struct {
+			Title string `xml:"title"`
+		}
+		if xml.Unmarshal(core, &props) == nil && strings.TrimSpace(props.Title) != "" {
+			doc.Metadata = map[string]string{rag.MetaTitle: strings.TrimSpace(props.Title)}
+		}
+	}
+	if text != "" {
+		doc.Sections = []Section{{Text: text}}
+	}
+	return doc, nil
+}
+
+// zipFile returns the contents of the named file in a zip archive.
+func zipFile(zr *zip.Reader, name string) ([]byte, error) {
+	for _, f := range zr.File {
+		if f.Name == name {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			return io.ReadAll(io.LimitReader(rc, 256<<20))
+		}
+	}
+	return nil, fmt.Errorf("%s not found", name)
+}
+
+// headingName matches the names Word gives its heading styles.
+var headingName = regexp.MustCompile(`(?i)^heading\s*([1-6])$`)
+
+// headingStyles maps the IDs of heading styles to their level. Built-in
+// heading styles are named "heading N"; custom styles may set an outline
+// level instead. The title style counts as a level 1 heading.
+func headingStyles(data []byte) map[string]int {
+	var styles struct {
+		Style []struct {
+			ID   string `xml:"styleId,attr"`
+			Name struct {
+				Val string `xml:"val,attr"`
+			} `xml:"name"`
+			Outline *struct {
+				Val string `xml:"val,attr"`
+			} `xml:"pPr>outlineLvl"`
+		} `xml:"style"`
+	}
+	levels := make(map[string]int)
+	if xml.Unmarshal(data, &styles) != nil {
+		return levels
+	}
+	for _, s := range styles.Style {
+		switch m := headingName.FindStringSubmatch(s.Name.Val); {
+		case m != nil:
+			levels[s.ID] = int(m[1][0] - '0')
+		case strings.EqualFold(s.Name.Val, "title"):
+			levels[s.ID] = 1
+		case s.Outline != nil:
+			if n, err := strconv.Atoi(s.Outline.Val); err == nil && n < 6 {
+				levels[s.ID] = n + 1
+			}
+		}
+	}
+	return levels
+}
+
+// docxTable collects the rows of a table while the document is read.
+type docxTable struct {
+	rows [][]string
+	cell *strings.Builder // nil outside a cell
+}
+
+// docxMarkdown converts the body of document.xml to Markdown. levels maps
+// heading style IDs to their level.
+func docxMarkdown(data []byte, levels map[string]int) (string, error) {
+	w := &mdWriter{atLineStart: true}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+
+	var (
+		para     strings.Builder
+		inText   bool // inside w:t
+		level    int  // heading level of the paragraph
+		listItem bool
+		skip     int // depth inside elements whose text is left out
+		tables   []*docxTable
+	)
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 {
+				skip++
+				continue
+			}
+			switch t.Name.Local {
+			case "Fallback", "instrText", "delText", "footnoteReference":
+				// The fallback of alternate content repeats the choice
+				skip = 1
+			case "p":
+				para.Reset()
+				level, listItem = 0, false
+			case "pStyle":
+				level = max(level, levels[xmlAttr(t, "val")])
+			case "outlineLvl":
+				if n, err := strconv.Atoi(xmlAttr(t, "val")); err == nil && n < 6 {
+					level = max(level, n+1)
+				}
+			case "numPr":
+				listItem = true
+			case "t":
+				inText = true
+			case "tab":
+				para.WriteByte('\t')
+			case "br", "cr":
+				if xmlAttr(t, "type") != "page" {
+					para.WriteByte('\n')
+				}
+			case "tbl":
+				tables = append(tables, &docxTable{})
+			case "tr":
+				if n := len(tables); n > 0 {
+					tables[n-1].rows = append(tables[n-1].rows, nil)
+				}
+			case "tc":
+				if n := len(tables); n > 0 {
+					tables[n-1].cell = &strings.Builder{}
+				}
+			}
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text := strings.TrimSpace(para.String())
+				if n := len(tables); n > 0 && tables[n-1].cell != nil {
+					if text != "" {
+						if tables[n-1].cell.Len() > 0 {
+							tables[n-1].cell.WriteByte(' ')
+						}
+						tables[n-1].cell.WriteString(text)
+					}
+					continue
+				}
+				if text == "" {
+					continue
+				}
+				switch {
+				case level > 0:
+					w.paragraph()
+					w.inline(strings.Repeat("#", level) + " " + collapseSpace(text))
+					w.paragraph()
+				case listItem:
+					w.breakLine()
+					w.inline("- " + text)
+					w.breakLine()
+				default:
+					w.paragraph()
+					for i, line := range strings.Split(text, "\n") {
+						if i > 0 {
+							w.breakLine()
+						}
+						w.inline(strings.TrimSpace(line))
+					}
+					w.paragraph()
+				}
+			case "tc":
+				if n := len(tables); n > 0 && tables[n-1].cell != nil {
+					tbl := tables[n-1]
+					if r := len(tbl.rows); r > 0 {
+						tbl.rows[r-1] = append(tbl.rows[r-1], tableCell(tbl.cell.String()))
+					}
+					tbl.cell = nil
+				}
+			case "tbl":
+				n := len(tables)
+				if n == 0 {
+					continue
+				}
+				tbl := tables[n-1]
+				tables = tables[:n-1]
+				if n > 1 && tables[n-2].cell != nil {
+					// A nested table goes into the outer cell as text
+					for _, row := range tbl.rows {
+						tables[n-2].cell.WriteString(" " + strings.Join(row, " "))
+					}
+					continue
+				}
+				var rows [][]string
+				for _, row := range tbl.rows {
+					if len(row) > 0 {
+						rows = append(rows, row)
+					}
+				}
+				w.writeTable(rows)
+			}
+		case xml.CharData:
+			if inText && skip == 0 {
+				para.Write(t)
+			}
+		}
+	}
+	return w.String(), nil
+}
+
+// xmlAttr returns the value of the attribute with the given local name.
+func xmlAttr(e xml.StartElement, local string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,127 @@
+package ingest
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"go-groq/internal/rag"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// EPUBLoader extracts the chapters of EPUB books in reading (spine) order.
+// Every chapter becomes a section, written as Markdown like HTML pages, with
+// its first heading as the chapter metadata. The book title becomes the
+// document title.
+type EPUBLoader struct{}
+
+// epubPackage is the part of the OPF package document that lists the content.
+type epubPackage struct {
+	Title    []string `xml:"metadata>title"`
+	Manifest []struct {
+		ID        string `xml:"id,attr"`
+		Href      string `xml:"href,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// Load implements Loader.
+func (EPUBLoader) Load(p string, data []byte) (*Document, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("read EPUB: %w", err)
+	}
+	container, err := zipFile(zr, "META-INF/container.xml")
+	if err != nil {
+		return nil, fmt.Errorf("read EPUB: %w", err)
+	}
+	var rootfiles struct {
+		Rootfile []struct {
+			Path string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := xml.Unmarshal(container, &rootfiles); err != nil || len(rootfiles.Rootfile) == 0 {
+		return nil, fmt.Errorf("read EPUB: no package document in META-INF/container.xml")
+	}
+	opfPath := rootfiles.Rootfile[0].Path
+	opf, err := zipFile(zr, opfPath)
+	if err != nil {
+		return nil, fmt.Errorf("read EPUB: %w", err)
+	}
+	var pkg epubPackage
+	if err := xml.Unmarshal(opf, &pkg); err != nil {
+		return nil, fmt.Errorf("read EPUB: parse %s: %w", opfPath, err)
+	}
+
+	doc := &Document{Path: p}
+	if len(pkg.Title) > 0 && strings.TrimSpace(pkg.Title[0]) != "" {
+		doc.Metadata = map[string]string{rag.MetaTitle: collapseSpace(pkg.Title[0])}
+	}
+	hrefs := make(map[string]string, len(pkg.Manifest))
+	for _, item := range pkg.Manifest {
+		if strings.Contains(item.MediaType, "html") {
+			hrefs[item.ID] = item.Href
+		}
+	}
+	dir := path.Dir(opfPath)
+	for _, ref := range pkg.Spine {
+		href, ok := hrefs[ref.IDRef]
+		if !ok {
+			continue
+		}
+		if u, err := url.PathUnescape(href); err == nil {
+			href = u
+		}
+		name := path.Join(dir, href)
+		chapter, err := zipFile(zr, name)
+		if err != nil {
+			doc.Warnings = append(doc.Warnings, fmt.Sprintf("chapter %s: %v", name, err))
+			continue
+		}
+		root, err := parseHTML(chapter)
+		if err != nil {
+			doc.Warnings = append(doc.Warnings, fmt.Sprintf("chapter %s: %v", name, err))
+			continue
+		}
+		title := chapterTitle(root)
+		removeBoilerplate(root)
+		body := findFirst(root, atom.Body)
+		if body == nil {
+			body = root
+		}
+		text := htmlMarkdown(body)
+		if text == "" {
+			continue // cover pages and the like
+		}
+		section := Section{Text: text}
+		if title != "" {
+			section.Metadata = map[string]string{rag.MetaChapter: title}
+		}
+		doc.Sections = append(doc.Sections, section)
+	}
+	return doc, nil
+}
+
+// chapterTitle returns the first heading of a chapter, or its title.
+func chapterTitle(root *html.Node) string {
+	for _, a := range []atom.Atom{atom.H1, atom.H2, atom.H3} {
+		if h := findFirst(root, a); h != nil {
+			if title := collapseSpace(textContent(h)); title != "" {
+				return title
+			}
+		}
+	}
+	if t := findFirst(root, atom.Title); t != nil {
+		return collapseSpace(textContent(t))
+	}
+	return ""
+}
@@ -0,0 +1,109 @@
+package ingest
+
+import (
+	"strings"
+
+	"go-groq/internal/rag"
+)
+
+// reservedKeys are metadata keys the pipeline sets itself, which front
+// matter cannot override.
+var reservedKeys = map[string]bool{
+	rag.MetaPath:       true,
+	rag.MetaMTime:      true,
+	rag.MetaHash:       true,
+	rag.MetaCollection: true,
+	rag.MetaType:       true,
+	rag.MetaStartLine:  true,
+	rag.MetaEndLine:    true,
+	rag.MetaRepo:       true,
+	rag.MetaCommit:     true,
+	rag.MetaBranch:     true,
+	rag.MetaRelPath:    true,
+}
+
+// frontMatterAliases maps common front-matter fields to metadata keys.
+var frontMatterAliases = map[string]string{
+	"tag":      rag.MetaTags,
+	"keywords": rag.MetaTags,
+	"lang":     rag.MetaLanguage,
+}
+
+// frontMatter reads the YAML front matter at the start of a Markdown
+// document into metadata. It understands the flat subset used in practice:
+// "key: value" lines and lists written as [a, b] or as "- item" lines, which
+// become comma-separated values. Nested fields are ignored.
+func frontMatter(text string) map[string]string {
+	text = strings.TrimPrefix(text, "\ufeff")
+	if !strings.HasPrefix(text, "---\n") && !strings.HasPrefix(text, "---\r\n") {
+		return nil
+	}
+	lines := strings.Split(text, "\n")[1:]
+
+	meta := make(map[string]string)
+	var listKey string
+	var list []string
+	flush := func() {
+		if listKey != "" && len(list) > 0 {
+			meta[listKey] = strings.Join(list, ",")
+		}
+		listKey, list = "", nil
+	}
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if line == "---" || line == "..." {
+			flush()
+			return meta
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "- ") {
+			if listKey != "" {
+				list = append(list, unquote(strings.TrimSpace(trimmed[2:])))
+			}
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			continue // nested field
+		}
+		flush()
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		if alias, ok := frontMatterAliases[key]; ok {
+			key = alias
+		}
+		if key == "" || reservedKeys[key] {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case value == "":
+			listKey = key // a list may follow
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			var items []string
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = unquote(strings.TrimSpace(item)); item != "" {
+					items = append(items, item)
+				}
+			}
+			meta[key] = strings.Join(items, ",")
+		default:
+			meta[key] = unquote(value)
+		}
+	}
+	return nil // no closing delimiter: not front matter
+}
+
+// unquote strips matching single or double quotes around a YAML scalar.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
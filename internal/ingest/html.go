@@ -0,0 +1,542 @@
+package ingest
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"go-groq/internal/rag"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTMLLoader extracts the main content of HTML pages, such as a Confluence
+// export, in the manner of browser reading modes: scripts, navigation,
+// sidebars and footers are dropped, and the part of the page that holds the
+// most paragraph text is kept. The content is written as Markdown, keeping
+// headings, lists, tables and code blocks, so that chunks can be split at
+// headings. The page title becomes the document title.
+type HTMLLoader struct{}
+
+// Load implements Loader.
+func (HTMLLoader) Load(path string, data []byte) (*Document, error) {
+	root, err := parseHTML(data)
+	if err != nil {
+		return nil, err
+	}
+	return htmlDocument(path, root), nil
+}
+
+// htmlDocument extracts the main content of a parsed page. It removes the
+// boilerplate from root.
+func htmlDocument(path string, root *html.Node) *Document {
+	doc := &Document{Path: path}
+	if title := htmlTitle(root); title != "" {
+		doc.Metadata = map[string]string{rag.MetaTitle: title}
+	}
+	removeBoilerplate(root)
+	content := mainContent(root)
+	text := htmlMarkdown(content)
+	// Keep the page heading when the content was found below it
+	if h1 := findFirst(root, atom.H1); h1 != nil && findFirst(content, atom.H1) == nil {
+		if heading := collapseSpace(textContent(h1)); heading != "" {
+			text = strings.TrimSpace("# " + heading + "\n\n" + text)
+		}
+	}
+	if text != "" {
+		doc.Sections = []Section{{Text: text}}
+	}
+	return doc
+}
+
+// parseHTML parses an HTML document, reading bytes that are not UTF-8 as
+// Latin-1, the usual encoding of older pages.
+func parseHTML(data []byte) (*html.Node, error) {
+	if !utf8.Valid(data) {
+		data = []byte(latin1(string(data)))
+	}
+	root, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("parse HTML: %w", err)
+	}
+	return root, nil
+}
+
+// htmlTitle returns the text of the title element, or of the first h1.
+func htmlTitle(root *html.Node) string {
+	if t := findFirst(root, atom.Title); t != nil {
+		if title := collapseSpace(textContent(t)); title != "" {
+			return title
+		}
+	}
+	if h := findFirst(root, atom.H1); h != nil {
+		return collapseSpace(textContent(h))
+	}
+	return ""
+}
+
+// boilerplate matches class names and IDs of page furniture.
+var boilerplate = regexp.MustCompile(`(?i)(^|[-_ ])(nav|navbar|navigation|menu|sidebar|breadcrumbs?|footer|masthead|comments?|share|social|cookies?|banner|advert|ads|popup|related|pagination|toolbar|skip)([-_ ]|$)`)
+
+// contentHint matches class names and IDs of containers that hold the content.
+var contentHint = regexp.MustCompile(`(?i)(article|content|main|post|entry|body|text|wiki)`)
+
+// removeBoilerplate deletes the elements that never hold the main content.
+func removeBoilerplate(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode || (c.Type == html.ElementNode && isBoilerplate(c)) {
+			n.RemoveChild(c)
+		} else {
+			removeBoilerplate(c)
+		}
+		c = next
+	}
+}
+
+func isBoilerplate(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Svg, atom.Iframe,
+		atom.Form, atom.Button, atom.Select, atom.Nav, atom.Aside, atom.Footer, atom.Head:
+		return true
+	case atom.Header:
+		return !hasAncestor(n, atom.Article, atom.Main)
+	case atom.Html, atom.Body, atom.Main, atom.Article:
+		return false
+	}
+	switch attr(n, "role") {
+	case "navigation", "banner", "contentinfo", "complementary", "search":
+		return true
+	}
+	if _, hidden := attrOK(n, "hidden"); hidden || attr(n, "aria-hidden") == "true" {
+		return true
+	}
+	if style := strings.ReplaceAll(attr(n, "style"), " ", ""); strings.Contains(style, "display:none") {
+		return true
+	}
+	names := attr(n, "class") + " " + attr(n, "id")
+	return boilerplate.MatchString(names) && !contentHint.MatchString(names)
+}
+
+// mainContent returns the element holding the main content of a page: a
+// main element or the largest article when they hold most of the text,
+// otherwise the element whose paragraphs score best, as in readability.
+func mainContent(root *html.Node) *html.Node {
+	body := findFirst(root, atom.Body)
+	if body == nil {
+		return root
+	}
+	total := len(collapseSpace(textContent(body)))
+
+	var best *html.Node
+	bestLen := 0
+	walk(body, func(n *html.Node) {
+		if n.DataAtom == atom.Main || n.DataAtom == atom.Article || attr(n, "role") == "main" {
+			if l := len(collapseSpace(textContent(n))); l > bestLen {
+				best, bestLen = n, l
+			}
+		}
+	})
+	if best != nil && bestLen*2 >= total {
+		return best
+	}
+
+	// Score containers by the paragraphs inside them: longer paragraphs and
+	// more commas mean prose; a parent gets the full score, a grandparent half
+	scores := make(map[*html.Node]float64)
+	walk(body, func(n *html.Node) {
+		switch n.DataAtom {
+		case atom.P, atom.Pre, atom.Td, atom.Blockquote:
+		default:
+			return
+		}
+		text := collapseSpace(textContent(n))
+		if len(text) < 25 {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+		if p := n.Parent; p != nil {
+			scores[p] += score
+			if gp := p.Parent; gp != nil {
+				scores[gp] += score / 2
+			}
+		}
+	})
+	best, bestScore := body, 0.0
+	for n, s := range scores {
+		names := attr(n, "class") + " " + attr(n, "id")
+		if contentHint.MatchString(names) {
+			s *= 1.25
+		}
+		s *= 1 - linkDensity(n)
+		if s > bestScore {
+			best, bestScore = n, s
+		}
+	}
+	// A container with a small part of the text is more likely a teaser
+	if len(collapseSpace(textContent(best)))*4 < total {
+		return body
+	}
+	return best
+}
+
+// linkDensity is the share of an element's text that is link text.
+func linkDensity(n *html.Node) float64 {
+	total := len(collapseSpace(textContent(n)))
+	if total == 0 {
+		return 0
+	}
+	links := 0
+	walk(n, func(c *html.Node) {
+		if c.DataAtom == atom.A {
+			links += len(collapseSpace(textContent(c)))
+		}
+	})
+	return float64(links) / float64(total)
+}
+
+// htmlMarkdown writes the content of n as Markdown.
+func htmlMarkdown(n *html.Node) string {
+	w := &mdWriter{atLineStart: true}
+	w.node(n)
+	return w.String()
+}
+
+// mdWriter renders HTML as Markdown.
+type mdWriter struct {
+	b           strings.Builder
+	prefix      string // written at the start of every line, for quotes and lists
+	marker      string // list marker for the next line, written instead of the end of prefix
+	atLineStart bool
+	space       bool // white space seen since the last word
+	newlines    int  // newlines at the end of b
+	inList      int  // list nesting; paragraphs in list items are not separated by blank lines
+}
+
+func (w *mdWriter) startLine() {
+	if !w.atLineStart {
+		return
+	}
+	if w.marker != "" {
+		w.b.WriteString(w.prefix[:len(w.prefix)-len(w.marker)] + w.marker)
+		w.marker = ""
+	} else {
+		w.b.WriteString(w.prefix)
+	}
+	w.atLineStart = false
+	w.newlines = 0
+}
+
+// text writes inline text, collapsing white space.
+func (w *mdWriter) text(s string) {
+	if s == "" {
+		return
+	}
+	if isSpace(s[0]) {
+		w.space = true
+	}
+	if words := collapseSpace(s); words != "" {
+		w.inline(words)
+	}
+	if isSpace(s[len(s)-1]) {
+		w.space = true
+	}
+}
+
+// inline writes s on the current line, after a space if one is pending.
+func (w *mdWriter) inline(s string) {
+	if w.space && !w.atLineStart {
+		w.b.WriteByte(' ')
+	}
+	w.startLine()
+	w.b.WriteString(s)
+	w.space = false
+}
+
+// breakLine ends the current line.
+func (w *mdWriter) breakLine() {
+	if !w.atLineStart {
+		w.b.WriteByte('\n')
+		w.atLineStart = true
+		w.newlines = 1
+	}
+	w.space = false
+}
+
+// paragraph ends the current block with a blank line.
+func (w *mdWriter) paragraph() {
+	w.breakLine()
+	if w.inList > 0 || w.b.Len() == 0 || w.newlines >= 2 {
+		return
+	}
+	w.b.WriteString(strings.TrimRight(w.prefix, " "))
+	w.b.WriteByte('\n')
+	w.newlines = 2
+}
+
+// String returns the Markdown written, without trailing spaces.
+func (w *mdWriter) String() string {
+	lines := strings.Split(w.b.String(), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " \t")
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+func (w *mdWriter) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.node(c)
+	}
+}
+
+func (w *mdWriter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.text(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		w.children(n)
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		text := collapseSpace(textContent(n))
+		if text == "" {
+			return
+		}
+		w.paragraph()
+		w.inline(strings.Repeat("#", int(n.Data[1]-'0')) + " " + text)
+		w.paragraph()
+	case atom.Br:
+		w.breakLine()
+	case atom.Hr:
+		w.paragraph()
+	case atom.Pre:
+		w.codeBlock(n)
+	case atom.Code, atom.Kbd, atom.Samp:
+		if text := strings.TrimSpace(textContent(n)); text != "" && !strings.Contains(text, "\n") {
+			w.inline("`" + text + "`")
+		} else {
+			w.children(n)
+		}
+	case atom.Ul, atom.Ol:
+		w.list(n)
+	case atom.Table:
+		w.table(n)
+	case atom.Blockquote:
+		w.paragraph()
+		w.prefix += "> "
+		w.children(n)
+		w.breakLine()
+		// Drop the blank quote line left by the last paragraph
+		blank := "\n" + strings.TrimRight(w.prefix, " ") + "\n"
+		if s := w.b.String(); strings.HasSuffix(s, blank) {
+			w.b.Reset()
+			w.b.WriteString(s[:len(s)-len(blank)+1])
+		}
+		w.prefix = w.prefix[:len(w.prefix)-2]
+		w.paragraph()
+	case atom.Img:
+		if alt := collapseSpace(attr(n, "alt")); alt != "" {
+			w.inline("[" + alt + "]")
+		}
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Header, atom.Figure,
+		atom.Figcaption, atom.Dl, atom.Dt, atom.Dd, atom.Address, atom.Details, atom.Summary, atom.Li:
+		w.paragraph()
+		w.children(n)
+		w.paragraph()
+	default:
+		w.children(n)
+	}
+}
+
+// list writes the items of ul or ol, indenting nested lists.
+func (w *mdWriter) list(n *html.Node) {
+	if w.inList == 0 {
+		w.paragraph()
+	} else {
+		w.breakLine()
+	}
+	w.inList++
+	num := 0
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.DataAtom != atom.Li {
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			num++
+			marker = fmt.Sprintf("%d. ", num)
+		}
+		w.breakLine()
+		saved := w.prefix
+		w.prefix += strings.Repeat(" ", len(marker))
+		w.marker = marker
+		w.children(c)
+		w.marker = ""
+		w.breakLine()
+		w.prefix = saved
+	}
+	w.inList--
+	w.paragraph()
+}
+
+// codeBlock writes pre as a fenced code block, keeping its white space.
+func (w *mdWriter) codeBlock(n *html.Node) {
+	text := strings.Trim(textContent(n), "\n")
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	lang := ""
+	if code := findFirst(n, atom.Code); code != nil {
+		for _, class := range strings.Fields(attr(code, "class")) {
+			if l, ok := strings.CutPrefix(class, "language-"); ok {
+				lang = l
+			}
+		}
+	}
+	w.paragraph()
+	for _, line := range append(append([]string{"```" + lang}, strings.Split(text, "\n")...), "```") {
+		w.startLine()
+		w.b.WriteString(line)
+		w.breakLine()
+	}
+	w.paragraph()
+}
+
+// table writes a table as Markdown rows, the first row as the header.
+func (w *mdWriter) table(n *html.Node) {
+	var rows [][]string
+	walk(n, func(c *html.Node) {
+		if c.DataAtom != atom.Tr || closestTable(c) != n {
+			return
+		}
+		var row []string
+		for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+			if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+				row = append(row, tableCell(htmlMarkdown(cell)))
+			}
+		}
+		if len(row) > 0 {
+			rows = append(rows, row)
+		}
+	})
+	w.writeTable(rows)
+}
+
+// writeTable writes rows as a Markdown table, the first row as the header.
+func (w *mdWriter) writeTable(rows [][]string) {
+	if len(rows) == 0 {
+		return
+	}
+	cols := 0
+	for _, r := range rows {
+		cols = max(cols, len(r))
+	}
+	w.paragraph()
+	for i, r := range rows {
+		for len(r) < cols {
+			r = append(r, "")
+		}
+		w.startLine()
+		w.b.WriteString("| " + strings.Join(r, " | ") + " |")
+		w.breakLine()
+		if i == 0 {
+			w.startLine()
+			w.b.WriteString("|" + strings.Repeat(" --- |", cols))
+			w.breakLine()
+		}
+	}
+	w.paragraph()
+}
+
+// tableCell puts cell content on one line, escaping the column separator.
+func tableCell(s string) string {
+	return strings.ReplaceAll(collapseSpace(s), "|", `\|`)
+}
+
+// closestTable returns the nearest table element above n.
+func closestTable(n *html.Node) *html.Node {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.DataAtom == atom.Table {
+			return p
+		}
+	}
+	return nil
+}
+
+// walk calls fn for n and every element below it.
+func walk(n *html.Node, fn func(*html.Node)) {
+	if n.Type == html.ElementNode {
+		fn(n)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
+
+// findFirst returns the first element of the given kind at or below n.
+func findFirst(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findFirst(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func hasAncestor(n *html.Node, atoms ...atom.Atom) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		for _, a := range atoms {
+			if p.DataAtom == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// textContent returns all the text below n.
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+func attr(n *html.Node, key string) string {
+	v, _ := attrOK(n, key)
+	return v
+}
+
+func attrOK(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// collapseSpace trims s and replaces runs of white space with one space.
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -0,0 +1,144 @@
+package ingest
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is one pattern line of a .gitignore-style file.
+type ignoreRule struct {
+	base    string // directory of the ignore file, relative to the root; "" for the root
+	negate  bool   // "!pattern" re-includes what an earlier rule excluded
+	dirOnly bool   // "pattern/" only matches directories
+	re      *regexp.Regexp
+}
+
+// Ignore matches paths against .gitignore-style rules. Rules are checked in
+// the order they were added and the last one that matches decides, so rules
+// from deeper directories, added later during a walk, take precedence.
+type Ignore struct {
+	rules []ignoreRule
+}
+
+// AddFile adds the rules in the ignore file at file, which lives in the
+// directory base (relative to the root, slash-separated). A missing file adds
+// nothing.
+func (ig *Ignore) AddFile(file, base string) error {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		ig.Add(scanner.Text(), base)
+	}
+	return scanner.Err()
+}
+
+// Add adds one pattern line for the directory base.
+func (ig *Ignore) Add(line, base string) {
+	line = strings.TrimRight(line, "\r")
+	if !strings.HasSuffix(line, "\\ ") {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || line[0] == '#' {
+		return
+	}
+	rule := ignoreRule{base: strings.Trim(base, "/")}
+	if line[0] == '!' {
+		rule.negate = true
+		line = line[1:]
+	} else if line[0] == '\\' && len(line) > 1 && (line[1] == '!' || line[1] == '#') {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return
+	}
+	// A slash anywhere but the end anchors the pattern to base; otherwise it
+	// matches at any depth
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		line = "**/" + line
+	}
+	re, err := regexp.Compile("^" + globToRegexp(line) + "$")
+	if err != nil {
+		return // not a valid pattern; git ignores these too
+	}
+	rule.re = re
+	ig.rules = append(ig.rules, rule)
+}
+
+// Match reports whether the slash-separated path rel, relative to the root,
+// is ignored.
+func (ig *Ignore) Match(rel string, isDir bool) bool {
+	ignored := false
+	for _, r := range ig.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		p := rel
+		if r.base != "" {
+			if !strings.HasPrefix(rel, r.base+"/") {
+				continue
+			}
+			p = rel[len(r.base)+1:]
+		}
+		if r.re.MatchString(p) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// globToRegexp translates a gitignore glob to a regular expression: * and ?
+// stay within a path element, ** crosses them.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
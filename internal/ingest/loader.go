@@ -0,0 +1,177 @@
+package ingest
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Section is a part of a document with its own metadata, such as a PDF page.
+// Chunks never span sections.
+type Section struct {
+	Text     string
+	Metadata map[string]string
+	Whole    bool // stored as one chunk rather than split by the chunker, such as a table record
+}
+
+// Document is the text extracted from one source file.
+type Document struct {
+	Path     string
+	Sections []Section
+	Metadata map[string]string // document-level metadata, copied onto every chunk
+	Warnings []string          // problems that did not stop the file from loading, such as unreadable pages
+}
+
+// Loader extracts text from a file.
+type Loader interface {
+	// Load reads the file at path. data holds its full contents.
+	Load(path string, data []byte) (*Document, error)
+}
+
+// TextLoader loads plain text, Markdown and source files as a single section.
+// The front matter of Markdown files becomes document metadata.
+type TextLoader struct{}
+
+// Load implements Loader.
+func (TextLoader) Load(path string, data []byte) (*Document, error) {
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("%s is not valid UTF-8 text", path)
+	}
+	doc := &Document{
+		Path:     path,
+		Sections: []Section{{Text: string(data)}},
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		doc.Metadata = frontMatter(string(data))
+	}
+	return doc, nil
+}
+
+// textExtensions lists the file types handled by TextLoader.
+var textExtensions = []string{
+	".txt", ".md", ".markdown", ".rst",
+	".go", ".py", ".js", ".ts", ".tsx", ".jsx", ".java", ".kt", ".rs", ".rb", ".php",
+	".c", ".h", ".cc", ".cpp", ".hpp", ".cs", ".swift", ".scala", ".sh", ".sql", ".proto",
+	".yaml", ".yml", ".toml", ".ini", ".cfg",
+}
+
+// languages maps source file extensions to the programming language
+// recorded as chunk metadata.
+var languages = map[string]string{
+	".go": "go", ".py": "python", ".js": "javascript", ".jsx": "javascript",
+	".ts": "typescript", ".tsx": "typescript", ".java": "java", ".kt": "kotlin",
+	".rs": "rust", ".rb": "ruby", ".php": "php", ".c": "c", ".h": "c",
+	".cc": "cpp", ".cpp": "cpp", ".hpp": "cpp", ".cs": "csharp", ".swift": "swift",
+	".scala": "scala", ".sh": "shell", ".sql": "sql", ".proto": "protobuf",
+}
+
+// loaders maps file extensions to their Loader.
+var loaders = func() map[string]Loader {
+	m := make(map[string]Loader)
+	for _, ext := range textExtensions {
+		m[ext] = TextLoader{}
+	}
+	m[".pdf"] = PDFLoader{}
+	for _, ext := range []string{".html", ".htm", ".xhtml"} {
+		m[ext] = HTMLLoader{}
+	}
+	m[".docx"] = DOCXLoader{}
+	m[".epub"] = EPUBLoader{}
+	for _, ext := range []string{".csv", ".tsv"} {
+		m[ext] = CSVLoader{}
+	}
+	for _, ext := range []string{".json", ".jsonl", ".ndjson"} {
+		m[ext] = JSONLoader{}
+	}
+	return m
+}()
+
+// RegisterLoader makes loader handle files with the given extension (including the dot).
+func RegisterLoader(ext string, loader Loader) {
+	loaders[strings.ToLower(ext)] = loader
+}
+
+// LoaderFor returns the Loader for path, or nil if the file type is not supported.
+func LoaderFor(path string) Loader {
+	return loaders[strings.ToLower(filepath.Ext(path))]
+}
+
+// isText reports whether loader reads plain text files, which are held to
+// Options.MaxFileSize and checked for binary content, rather than documents
+// such as PDFs.
+func isText(loader Loader) bool {
+	switch loader.(type) {
+	case TextLoader, CSVLoader, JSONLoader:
+		return true
+	}
+	return false
+}
+
+// DetectLoader returns the Loader for path, chosen by extension or, for files
+// without a known extension such as exported pages, by sniffing the content.
+// It returns nil if the file type is not supported.
+func DetectLoader(path string) Loader {
+	if loader := LoaderFor(path); loader != nil {
+		return loader
+	}
+	head, err := readPrefix(path, 512)
+	if err != nil || len(head) == 0 {
+		return nil
+	}
+	switch {
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		return PDFLoader{}
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		// EPUB stores its MIME type uncompressed as the first file
+		if bytes.Contains(head, []byte("mimetypeapplication/epub+zip")) {
+			return EPUBLoader{}
+		}
+		if isDOCX(path) {
+			return DOCXLoader{}
+		}
+	case strings.HasPrefix(http.DetectContentType(head), "text/html"):
+		return HTMLLoader{}
+	}
+	return nil
+}
+
+// isDOCX reports whether the zip archive at path is a Word document.
+func isDOCX(path string) bool {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return false
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" {
+			return true
+		}
+	}
+	return false
+}
+
+// loaderTypes names the file type of documents whose loader was found by
+// sniffing, for the type metadata.
+var loaderTypes = map[Loader]string{
+	PDFLoader{}:  "pdf",
+	HTMLLoader{}: "html",
+	DOCXLoader{}: "docx",
+	EPUBLoader{}: "epub",
+	TextLoader{}: "txt",
+}
+
+// fileType returns the type recorded for a file: its extension, or the
+// format its loader handles when the extension is unknown.
+func fileType(path string, loader Loader) string {
+	if LoaderFor(path) == nil {
+		if t, ok := loaderTypes[loader]; ok {
+			return t
+		}
+	}
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+}
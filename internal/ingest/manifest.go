@@ -0,0 +1,83 @@
+package ingest
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"go-groq/internal/rag"
+)
+
+// manifestEntry is what a Manifest knows about one document.
+type manifestEntry struct {
+	hash       string
+	collection string
+}
+
+// Manifest records the content hash and collection of every document in a
+// sink, so that a Pipeline can skip files that have not changed and remove
+// the chunks of files that were deleted. It is safe for concurrent use.
+type Manifest struct {
+	mu   sync.Mutex
+	docs map[string]manifestEntry
+}
+
+// NewManifest returns an empty Manifest.
+func NewManifest() *Manifest {
+	return &Manifest{docs: make(map[string]manifestEntry)}
+}
+
+// Add records the document of a stored chunk, for building a Manifest from
+// an existing index.
+func (m *Manifest) Add(c rag.Chunk) {
+	m.set(c.DocID, c.Metadata[rag.MetaHash], c.Metadata[rag.MetaCollection])
+}
+
+// Len returns the number of documents recorded.
+func (m *Manifest) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.docs)
+}
+
+// unchanged reports whether docID is recorded with the same hash and collection.
+func (m *Manifest) unchanged(docID, hash, collection string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.docs[docID]
+	return ok && e.hash != "" && e.hash == hash && e.collection == collection
+}
+
+func (m *Manifest) set(docID, hash, collection string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.docs[docID] = manifestEntry{hash: hash, collection: collection}
+}
+
+func (m *Manifest) remove(docID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.docs, docID)
+}
+
+// under returns the recorded documents that are dir itself or lie below it, sorted.
+func (m *Manifest) under(dir string) []string {
+	prefix := strings.TrimSuffix(dir, string(os.PathSeparator)) + string(os.PathSeparator)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []string
+	for id := range m.docs {
+		if id == dir || strings.HasPrefix(id, prefix) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// docIDFor returns the document ID of the file at path.
+func docIDFor(path string) (string, error) {
+	return filepath.Abs(path)
+}
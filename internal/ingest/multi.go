@@ -0,0 +1,33 @@
+package ingest
+
+import (
+	"context"
+
+	"go-groq/internal/rag"
+)
+
+// MultiSink returns a Sink that writes to every sink in order, stopping at
+// the first error.
+func MultiSink(sinks ...Sink) Sink {
+	return multiSink(sinks)
+}
+
+type multiSink []Sink
+
+func (m multiSink) Upsert(ctx context.Context, chunks []rag.Chunk) error {
+	for _, s := range m {
+		if err := s.Upsert(ctx, chunks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multiSink) DeleteDoc(ctx context.Context, docID string) error {
+	for _, s := range m {
+		if err := s.DeleteDoc(ctx, docID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
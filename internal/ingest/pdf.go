@@ -0,0 +1,307 @@
+package ingest
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go-groq/internal/rag"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// PDFLoader extracts the text layer of PDF files without external tools.
+// Every page becomes a section with its page number as metadata, so chunks
+// can be cited by page. Text is put back in reading order from the position
+// of each glyph, which keeps the columns of multi-column layouts apart.
+// Pages without text but with images, such as scans, are skipped with a
+// warning; they would need OCR.
+type PDFLoader struct{}
+
+// Load implements Loader.
+func (PDFLoader) Load(path string, data []byte) (doc *Document, err error) {
+	// The PDF reader panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			doc, err = nil, fmt.Errorf("malformed PDF: %v", r)
+		}
+	}()
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("read PDF: %w", err)
+	}
+
+	doc = &Document{Path: path}
+	if title := strings.TrimSpace(r.Trailer().Key("Info").Key("Title").Text()); title != "" {
+		doc.Metadata = map[string]string{rag.MetaTitle: title}
+	}
+	var imageOnly []string
+	for n := 1; n <= r.NumPage(); n++ {
+		text, images, err := pageText(r.Page(n))
+		if err != nil {
+			doc.Warnings = append(doc.Warnings, fmt.Sprintf("page %d: %v", n, err))
+		}
+		switch {
+		case text != "":
+			doc.Sections = append(doc.Sections, Section{
+				Text:     text,
+				Metadata: map[string]string{rag.MetaPage: strconv.Itoa(n)},
+			})
+		case images:
+			imageOnly = append(imageOnly, strconv.Itoa(n))
+		}
+	}
+	if len(imageOnly) > 0 {
+		doc.Warnings = append(doc.Warnings, fmt.Sprintf("%d image-only pages without a text layer skipped (OCR needed): %s",
+			len(imageOnly), strings.Join(imageOnly, ", ")))
+	}
+	return doc, nil
+}
+
+// pageText returns the text of a page in reading order, and whether the
+// page draws images.
+func pageText(page pdf.Page) (text string, images bool, err error) {
+	if page.V.IsNull() {
+		return "", false, nil
+	}
+	glyphs, images, err := readPage(page)
+	text = layoutText(glyphs)
+	switch {
+	case err != nil && text != "":
+		err = fmt.Errorf("text may be incomplete: %w", err)
+	case err != nil:
+		err = fmt.Errorf("skipped: %w", err)
+	}
+	return text, images, err
+}
+
+// fragment is a run of text on one baseline without a wide gap in it: a
+// line, or the part of a line that lies in one column.
+type fragment struct {
+	x0, x1 float64 // horizontal extent
+	y      float64 // baseline
+	size   float64 // font size
+	text   string
+}
+
+// Layout thresholds, as fractions of the font size.
+const (
+	wordGap     = 0.15 // a gap wider than this between glyphs is a space
+	fragmentGap = 1.5  // a gap wider than this splits a line into fragments
+	sameLine    = 0.4  // baselines closer than this are on the same line
+	paragraph   = 1.8  // baselines further apart than this start a paragraph
+)
+
+// ligatures are expanded so that extracted words match what people search for.
+var ligatures = strings.NewReplacer("ﬀ", "ff", "ﬁ", "fi", "ﬂ", "fl", "ﬃ", "ffi", "ﬄ", "ffl", "ﬅ", "st", "ﬆ", "st")
+
+// layoutText puts the glyphs of a page back into reading order: lines top to
+// bottom and, where the page has columns, each column before the next.
+// Text spanning the columns, such as a title, separates bands of columns.
+func layoutText(glyphs []glyph) string {
+	frags := fragments(glyphs)
+	if len(frags) == 0 {
+		return ""
+	}
+	sortLines(frags)
+
+	gutters := findGutters(frags)
+	var b strings.Builder
+	var band []fragment
+	flush := func() {
+		for col := 0; col <= len(gutters); col++ {
+			var lines []fragment
+			for _, f := range band {
+				if columnOf(f, gutters) == col {
+					lines = append(lines, f)
+				}
+			}
+			writeLines(&b, lines)
+		}
+		band = band[:0]
+	}
+	for _, f := range frags {
+		if len(gutters) > 0 && columnOf(f, gutters) < 0 {
+			flush()
+			writeLines(&b, []fragment{f})
+			continue
+		}
+		band = append(band, f)
+	}
+	flush()
+	return strings.TrimSpace(b.String())
+}
+
+// fragments joins glyphs, in the order they were drawn, into fragments,
+// adding spaces where the gap between two glyphs is wide enough.
+func fragments(glyphs []glyph) []fragment {
+	var frags []fragment
+	var cur *fragment
+	var b strings.Builder
+	end := func() {
+		if cur != nil {
+			if text := strings.TrimSpace(b.String()); text != "" {
+				cur.text = text
+				frags = append(frags, *cur)
+			}
+		}
+		cur = nil
+		b.Reset()
+	}
+	for _, g := range glyphs {
+		if g.s == "" || g.size <= 0 {
+			continue
+		}
+		size, x, width := g.size, g.x, g.w
+		if cur != nil {
+			gap := x - cur.x1
+			switch {
+			case math.Abs(g.y-cur.y) > sameLine*size, gap > fragmentGap*size, gap < -size:
+				end()
+			case gap > wordGap*size && !strings.HasSuffix(b.String(), " "):
+				b.WriteByte(' ')
+			}
+		}
+		if cur == nil {
+			cur = &fragment{x0: x, x1: x, y: g.y, size: size}
+		}
+		if strings.TrimSpace(g.s) == "" {
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), " ") {
+				b.WriteByte(' ')
+			}
+		} else {
+			b.WriteString(ligatures.Replace(g.s))
+		}
+		cur.x1 = math.Max(cur.x1, x+width)
+		cur.size = math.Max(cur.size, size)
+	}
+	end()
+	return frags
+}
+
+// sortLines sorts fragments top to bottom and, within a line, left to right.
+func sortLines(frags []fragment) {
+	sort.SliceStable(frags, func(i, j int) bool { return frags[i].y > frags[j].y })
+	start := 0
+	for i := 1; i <= len(frags); i++ {
+		if i < len(frags) && frags[start].y-frags[i].y <= sameLine*frags[start].size {
+			frags[i].y = frags[start].y // snap superscripts and the like onto the line
+			continue
+		}
+		line := frags[start:i]
+		sort.SliceStable(line, func(a, b int) bool { return line[a].x0 < line[b].x0 })
+		start = i
+	}
+}
+
+// findGutters returns the x positions of the gaps between the columns of a
+// page, or nil when it has one column. Fragments wider than half the text
+// are left out, as they span the columns. Groups that are too small, or made
+// of short cells as in tables, do not count as columns.
+func findGutters(frags []fragment) []float64 {
+	left, right := frags[0].x0, frags[0].x1
+	for _, f := range frags {
+		left, right = math.Min(left, f.x0), math.Max(right, f.x1)
+	}
+	width := right - left
+	if width <= 0 {
+		return nil
+	}
+
+	type span struct {
+		x0, x1 float64
+		count  int
+		wide   int // fragments wider than a quarter of the text
+	}
+	var body []fragment
+	for _, f := range frags {
+		if f.x1-f.x0 <= width/2 {
+			body = append(body, f)
+		}
+	}
+	sort.Slice(body, func(i, j int) bool { return body[i].x0 < body[j].x0 })
+	var spans []span
+	for _, f := range body {
+		if n := len(spans); n > 0 && f.x0 < spans[n-1].x1+f.size {
+			spans[n-1].x1 = math.Max(spans[n-1].x1, f.x1)
+		} else {
+			spans = append(spans, span{x0: f.x0, x1: f.x1})
+		}
+		spans[len(spans)-1].count++
+		if f.x1-f.x0 > width/4 {
+			spans[len(spans)-1].wide++
+		}
+	}
+	if len(spans) < 2 {
+		return nil
+	}
+	var gutters []float64
+	for i, s := range spans {
+		if s.count < 3 || s.wide*2 < s.count {
+			return nil
+		}
+		if i > 0 {
+			gutters = append(gutters, (spans[i-1].x1+s.x0)/2)
+		}
+	}
+	return gutters
+}
+
+// columnOf returns the column a fragment lies in, or -1 if it crosses a gutter.
+func columnOf(f fragment, gutters []float64) int {
+	col := 0
+	for _, g := range gutters {
+		switch {
+		case f.x1 <= g:
+			return col
+		case f.x0 < g:
+			return -1
+		}
+		col++
+	}
+	return col
+}
+
+// writeLines writes the fragments of one column, sorted top to bottom, as
+// lines. Fragments on the same baseline are separated by a tab, a wider gap
+// between lines starts a paragraph, and words hyphenated across lines are
+// joined again.
+func writeLines(b *strings.Builder, frags []fragment) {
+	for i, f := range frags {
+		if i > 0 {
+			prev := frags[i-1]
+			dy := prev.y - f.y
+			switch {
+			case math.Abs(dy) <= sameLine*math.Min(prev.size, f.size):
+				b.WriteByte('\t')
+			case dy > paragraph*math.Max(prev.size, f.size):
+				b.WriteString("\n\n")
+			case hyphenated(b.String(), f.text):
+				s := b.String()
+				b.Reset()
+				b.WriteString(s[:len(s)-1])
+			default:
+				b.WriteByte('\n')
+			}
+		} else if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(f.text)
+	}
+}
+
+// hyphenated reports whether text ends with a word broken by a hyphen that
+// continues at the start of next.
+func hyphenated(text, next string) bool {
+	body, ok := strings.CutSuffix(text, "-")
+	if !ok || body == "" || next == "" {
+		return false
+	}
+	last := []rune(body)
+	first := []rune(next)
+	return unicode.IsLetter(last[len(last)-1]) && unicode.IsLower(first[0])
+}
@@ -0,0 +1,334 @@
+package ingest
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// glyph is one character drawn on a PDF page, in page coordinates.
+type glyph struct {
+	x, y float64 // origin on the baseline
+	w    float64 // advance width
+	size float64 // font size
+	s    string  // the character, or a ligature such as "fi"
+}
+
+// matrix is a PDF transformation matrix [a b c d e f], as given to cm.
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2], m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2], m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4], m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func translate(x, y float64) matrix {
+	return matrix{1, 0, 0, 1, x, y}
+}
+
+// toMatrix reads a six-number array, or returns identity.
+func toMatrix(v pdf.Value) matrix {
+	if v.Len() != 6 {
+		return identity
+	}
+	var m matrix
+	for i := range m {
+		m[i] = v.Index(i).Float64()
+	}
+	return m
+}
+
+// pdfFont is what text extraction needs to know about a font.
+type pdfFont struct {
+	enc    pdf.TextEncoding
+	cid    bool            // two-byte codes, as in Type0 fonts
+	widths map[int]float64 // advance widths in thousandths of an em
+	dflt   float64         // width of codes missing from widths
+}
+
+// avgWidth is assumed for glyphs of fonts that do not list their widths,
+// such as some uses of the standard 14 fonts.
+const avgWidth = 500
+
+func loadFont(v pdf.Value) *pdfFont {
+	f := &pdfFont{enc: pdf.Font{V: v}.Encoder(), widths: make(map[int]float64), dflt: avgWidth}
+	if f.enc == nil {
+		f.enc = noEncoding{}
+	}
+	if v.Key("Subtype").Name() == "Type0" {
+		f.cid = true
+		desc := v.Key("DescendantFonts").Index(0)
+		f.dflt = 1000
+		if dw := desc.Key("DW"); !dw.IsNull() {
+			f.dflt = dw.Float64()
+		}
+		// W holds "first [w1 w2 ...]" and "first last w" entries
+		w := desc.Key("W")
+		for i := 0; i+1 < w.Len(); {
+			first := int(w.Index(i).Int64())
+			if next := w.Index(i + 1); next.Kind() == pdf.Array {
+				for j := 0; j < next.Len(); j++ {
+					f.widths[first+j] = next.Index(j).Float64()
+				}
+				i += 2
+				continue
+			}
+			if i+2 >= w.Len() {
+				break
+			}
+			last, width := int(w.Index(i+1).Int64()), w.Index(i+2).Float64()
+			for c := first; c <= last && c-first < 1<<16; c++ {
+				f.widths[c] = width
+			}
+			i += 3
+		}
+		return f
+	}
+
+	// Type3 glyph widths are in glyph space, scaled by the font matrix
+	scale := 1.0
+	if fm := v.Key("FontMatrix"); fm.Len() == 6 {
+		scale = fm.Index(0).Float64() * 1000
+	}
+	first, widths := int(v.Key("FirstChar").Int64()), v.Key("Widths")
+	for i := 0; i < widths.Len(); i++ {
+		f.widths[first+i] = widths.Index(i).Float64() * scale
+	}
+	return f
+}
+
+func (f *pdfFont) width(code int) float64 {
+	if w, ok := f.widths[code]; ok && w > 0 {
+		return w
+	}
+	return f.dflt
+}
+
+// noEncoding passes codes through, for fonts whose encoding is unknown.
+type noEncoding struct{}
+
+func (noEncoding) Decode(raw string) string { return raw }
+
+// textState is the part of the graphics state that positions text.
+type textState struct {
+	ctm       matrix
+	tm, tlm   matrix
+	font      *pdfFont
+	size      float64
+	charSpace float64
+	wordSpace float64
+	scale     float64 // horizontal scaling, 1 = 100%
+	leading   float64
+	rise      float64
+}
+
+// pageReader collects the glyphs a page draws by interpreting its content
+// streams, including those of form XObjects.
+type pageReader struct {
+	glyphs []glyph
+	images bool // the page draws an image
+}
+
+// readPage returns the glyphs drawn on page and whether it draws images. If
+// a content stream cannot be read, the glyphs found so far are returned
+// with the error.
+func readPage(page pdf.Page) (glyphs []glyph, images bool, err error) {
+	r := &pageReader{}
+	contents := page.V.Key("Contents")
+	streams := []pdf.Value{contents}
+	if contents.Kind() == pdf.Array {
+		streams = streams[:0]
+		for i := 0; i < contents.Len(); i++ {
+			streams = append(streams, contents.Index(i))
+		}
+	}
+	g := &textState{ctm: identity, tm: identity, tlm: identity, scale: 1}
+	for _, s := range streams {
+		if err := r.run(s, page.Resources(), g, 0); err != nil {
+			return r.glyphs, r.images, err
+		}
+	}
+	return r.glyphs, r.images, nil
+}
+
+// run interprets one content stream.
+func (r *pageReader) run(strm, resources pdf.Value, g *textState, depth int) (err error) {
+	// The PDF reader panics on syntax it does not understand, such as inline
+	// image data
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("%v", e)
+		}
+	}()
+	if strm.Kind() != pdf.Stream {
+		return nil
+	}
+	fonts := make(map[string]*pdfFont)
+	var stack []textState
+	pdf.Interpret(strm, func(stk *pdf.Stack, op string) {
+		args := make([]pdf.Value, stk.Len())
+		for i := len(args) - 1; i >= 0; i-- {
+			args[i] = stk.Pop()
+		}
+		num := func(i int) float64 {
+			if i < len(args) {
+				return args[i].Float64()
+			}
+			return 0
+		}
+		switch op {
+		case "q":
+			stack = append(stack, *g)
+		case "Q":
+			if n := len(stack); n > 0 {
+				*g = stack[n-1]
+				stack = stack[:n-1]
+			}
+		case "cm":
+			if len(args) == 6 {
+				g.ctm = matrix{num(0), num(1), num(2), num(3), num(4), num(5)}.mul(g.ctm)
+			}
+		case "BT":
+			g.tm, g.tlm = identity, identity
+		case "Tf":
+			if len(args) == 2 {
+				name := args[0].Name()
+				f, ok := fonts[name]
+				if !ok {
+					f = loadFont(resources.Key("Font").Key(name))
+					fonts[name] = f
+				}
+				g.font, g.size = f, num(1)
+			}
+		case "Tc":
+			g.charSpace = num(0)
+		case "Tw":
+			g.wordSpace = num(0)
+		case "Tz":
+			g.scale = num(0) / 100
+		case "TL":
+			g.leading = num(0)
+		case "Ts":
+			g.rise = num(0)
+		case "Td", "TD":
+			if op == "TD" {
+				g.leading = -num(1)
+			}
+			g.tlm = translate(num(0), num(1)).mul(g.tlm)
+			g.tm = g.tlm
+		case "Tm":
+			if len(args) == 6 {
+				g.tlm = matrix{num(0), num(1), num(2), num(3), num(4), num(5)}
+				g.tm = g.tlm
+			}
+		case "T*":
+			g.tlm = translate(0, -g.leading).mul(g.tlm)
+			g.tm = g.tlm
+		case "Tj":
+			if len(args) == 1 {
+				r.show(g, args[0].RawString())
+			}
+		case "'", "\"":
+			if op == "\"" && len(args) == 3 {
+				g.wordSpace, g.charSpace = num(0), num(1)
+				args = args[2:]
+			}
+			g.tlm = translate(0, -g.leading).mul(g.tlm)
+			g.tm = g.tlm
+			if len(args) == 1 {
+				r.show(g, args[0].RawString())
+			}
+		case "TJ":
+			if len(args) != 1 {
+				return
+			}
+			for i := 0; i < args[0].Len(); i++ {
+				v := args[0].Index(i)
+				if v.Kind() == pdf.String {
+					r.show(g, v.RawString())
+				} else {
+					g.tm = translate(-v.Float64()/1000*g.size*g.scale, 0).mul(g.tm)
+				}
+			}
+		case "BI":
+			r.images = true
+		case "Do":
+			if len(args) != 1 {
+				return
+			}
+			x := resources.Key("XObject").Key(args[0].Name())
+			switch x.Key("Subtype").Name() {
+			case "Image":
+				r.images = true
+			case "Form":
+				if depth >= 4 {
+					return
+				}
+				res := x.Key("Resources")
+				if res.IsNull() {
+					res = resources
+				}
+				form := *g
+				form.ctm = toMatrix(x.Key("Matrix")).mul(g.ctm)
+				if err := r.run(x, res, &form, depth+1); err != nil {
+					panic(err)
+				}
+			}
+		}
+	})
+	return nil
+}
+
+// show draws a string in the current font, advancing the text matrix by
+// the width of every glyph.
+func (r *pageReader) show(g *textState, raw string) {
+	f := g.font
+	if f == nil {
+		return
+	}
+	step := 1
+	if f.cid {
+		step = 2
+	}
+	for i := 0; i+step <= len(raw); i += step {
+		code := raw[i : i+step]
+		c := int(code[0])
+		if step == 2 {
+			c = c<<8 | int(code[1])
+		}
+		s := f.enc.Decode(code)
+		if !utf8.ValidString(s) {
+			s = latin1(code) // an unknown encoding: guess Latin-1
+		}
+		w0 := f.width(c) / 1000
+		trm := matrix{g.size * g.scale, 0, 0, g.size, 0, g.rise}.mul(g.tm).mul(g.ctm)
+		r.glyphs = append(r.glyphs, glyph{
+			x:    trm[4],
+			y:    trm[5],
+			w:    w0 * trm[0],
+			size: math.Hypot(trm[2], trm[3]),
+			s:    s,
+		})
+		tx := w0*g.size + g.charSpace
+		if step == 1 && code[0] == ' ' {
+			tx += g.wordSpace
+		}
+		g.tm = translate(tx*g.scale, 0).mul(g.tm)
+	}
+}
+
+func latin1(b string) string {
+	var sb strings.Builder
+	for i := 0; i < len(b); i++ {
+		sb.WriteRune(rune(b[i]))
+	}
+	return sb.String()
+}
@@ -0,0 +1,409 @@
+// Package ingest loads documents from disk, splits them into chunks, embeds
+// the chunks and writes them to a store.
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go-groq/internal/chunk"
+	"go-groq/internal/llm"
+	"go-groq/internal/rag"
+)
+
+// Sink receives the chunks produced by a Pipeline.
+type Sink interface {
+	// Upsert adds or replaces chunks by ID.
+	Upsert(ctx context.Context, chunks []rag.Chunk) error
+	// DeleteDoc removes every chunk of a document.
+	DeleteDoc(ctx context.Context, docID string) error
+}
+
+// Options configures a Pipeline.
+type Options struct {
+	Collection   string                   // stored as chunk metadata; defaults to "default"
+	Chunker      chunk.Chunker            // splits documents; defaults to recursive splitting
+	TypeChunkers map[string]chunk.Chunker // overrides Chunker by file type, e.g. "md"
+	BatchSize    int                      // chunks per embedding request
+	MaxFileSize  int64                    // larger text files are skipped
+	MaxDocSize   int64                    // larger documents, such as PDFs, are skipped; they hold images and fonts besides text
+	Manifest     *Manifest                // documents already in the sink; nil ingests every file
+	Force        bool                     // re-ingest files the Manifest records as unchanged
+	Prune        bool                     // with a Manifest, RunFiles also removes documents under root not among its files
+	Metadata     map[string]string        // added to every chunk, e.g. the commit of a repository
+	RelativeTo   string                   // when set, chunks record their path relative to it as rel_path
+}
+
+// DefaultOptions returns the options used when a field is left zero.
+func DefaultOptions() Options {
+	return Options{
+		Collection:  "default",
+		Chunker:     chunk.Recursive{Size: chunk.DefaultSize, Overlap: chunk.DefaultOverlap},
+		BatchSize:   64,
+		MaxFileSize: 5 << 20,
+		MaxDocSize:  100 << 20,
+	}
+}
+
+// Progress reports the state of a running ingestion after each file.
+type Progress struct {
+	File      string   // file just processed
+	Done      int      // files processed so far
+	Total     int      // files found
+	Chunks    int      // chunks written for File
+	Skipped   bool     // File was skipped (unsupported or too large)
+	Unchanged bool     // File has not changed since it was last ingested
+	Removed   bool     // File was deleted, or pruned, and its chunks removed
+	Warnings  []string // problems that did not stop File from being ingested, such as image-only PDF pages
+	Err       error    // File failed; ingestion continues with the next file
+}
+
+// Summary describes a finished ingestion.
+type Summary struct {
+	Files     int // files ingested
+	Chunks    int // chunks written
+	Skipped   int // files skipped
+	Unchanged int // files left alone because their content hash matched
+	Removed   int // deleted or pruned files whose chunks were removed
+	Failed    int // files that failed
+	Warnings  int // warnings of the files ingested, see Progress.Warnings
+	Embedded  bool
+	Duration  time.Duration
+}
+
+// Add accumulates the counts of another summary into s.
+func (s *Summary) Add(other Summary) {
+	s.Files += other.Files
+	s.Chunks += other.Chunks
+	s.Skipped += other.Skipped
+	s.Unchanged += other.Unchanged
+	s.Removed += other.Removed
+	s.Failed += other.Failed
+	s.Warnings += other.Warnings
+	s.Embedded = s.Embedded || other.Embedded
+	s.Duration += other.Duration
+}
+
+// Pipeline ingests files into a Sink.
+type Pipeline struct {
+	embedder llm.EmbeddingClient
+	sink     Sink
+	opts     Options
+}
+
+// New creates a Pipeline. embedder may be nil, in which case chunks are
+// stored without vectors.
+func New(embedder llm.EmbeddingClient, sink Sink, opts Options) *Pipeline {
+	def := DefaultOptions()
+	if opts.Collection == "" {
+		opts.Collection = def.Collection
+	}
+	if opts.Chunker == nil {
+		opts.Chunker = def.Chunker
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = def.BatchSize
+	}
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = def.MaxFileSize
+	}
+	if opts.MaxDocSize <= 0 {
+		opts.MaxDocSize = def.MaxDocSize
+	}
+	return &Pipeline{embedder: embedder, sink: sink, opts: opts}
+}
+
+// Run ingests root, which may be a file or a directory. progress, if not
+// nil, is called after every file. With a Manifest, files whose content has
+// not changed are skipped, and documents under root whose files were deleted
+// are removed from the sink.
+func (p *Pipeline) Run(ctx context.Context, root string, progress func(Progress)) (Summary, error) {
+	start := time.Now()
+	files, err := p.collect(root)
+	if errors.Is(err, fs.ErrNotExist) && p.opts.Manifest != nil {
+		if dir, absErr := docIDFor(root); absErr == nil && len(p.opts.Manifest.under(dir)) > 0 {
+			files, err = nil, nil // root was deleted: only remove what was ingested from it
+		}
+	}
+	if err != nil {
+		return Summary{Embedded: p.embedder != nil}, err
+	}
+	summary, err := p.RunFiles(ctx, root, files, progress)
+	summary.Duration = time.Since(start)
+	return summary, err
+}
+
+// RunFiles ingests files, which were found under root by the caller, for
+// example with Repo.Scan. It behaves like Run otherwise; with Options.Prune,
+// documents under root that are not among files are removed as well.
+func (p *Pipeline) RunFiles(ctx context.Context, root string, files []string, progress func(Progress)) (Summary, error) {
+	start := time.Now()
+	summary := Summary{Embedded: p.embedder != nil}
+
+	for i, path := range files {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+		event := Progress{File: path, Done: i + 1, Total: len(files)}
+		n, warnings, err := p.ingestFile(ctx, path)
+		switch {
+		case errors.Is(err, errSkipped):
+			event.Skipped = true
+			summary.Skipped++
+		case errors.Is(err, errUnchanged):
+			event.Unchanged = true
+			summary.Unchanged++
+		case err != nil:
+			if ctx.Err() != nil {
+				return summary, ctx.Err()
+			}
+			event.Err = err
+			summary.Failed++
+		default:
+			event.Chunks = n
+			event.Warnings = warnings
+			summary.Files++
+			summary.Chunks += n
+			summary.Warnings += len(warnings)
+		}
+		if progress != nil {
+			progress(event)
+		}
+	}
+
+	if p.opts.Manifest != nil {
+		var keep map[string]bool
+		if p.opts.Prune {
+			keep = make(map[string]bool, len(files))
+			for _, f := range files {
+				if id, err := docIDFor(f); err == nil {
+					keep[id] = true
+				}
+			}
+		}
+		removed, err := p.removeMissing(ctx, root, keep, progress)
+		summary.Removed = removed
+		if err != nil {
+			return summary, err
+		}
+	}
+
+	summary.Duration = time.Since(start)
+	return summary, nil
+}
+
+// removeMissing deletes the documents recorded under root whose files no
+// longer exist, or, if keep is not nil, those not in keep. It returns how
+// many it deleted.
+func (p *Pipeline) removeMissing(ctx context.Context, root string, keep map[string]bool, progress func(Progress)) (int, error) {
+	dir, err := docIDFor(root)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, id := range p.opts.Manifest.under(dir) {
+		if keep != nil {
+			if keep[id] {
+				continue
+			}
+		} else if _, err := os.Stat(id); !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err := p.sink.DeleteDoc(ctx, id); err != nil {
+			return removed, err
+		}
+		p.opts.Manifest.remove(id)
+		removed++
+		if progress != nil {
+			progress(Progress{File: id, Removed: true})
+		}
+	}
+	return removed, nil
+}
+
+// errSkipped marks files that are intentionally not ingested.
+var errSkipped = errors.New("skipped")
+
+// errUnchanged marks files whose content matches what was ingested before.
+var errUnchanged = errors.New("unchanged")
+
+// collect lists the supported files under root, skipping hidden directories.
+func (p *Pipeline) collect(root string) ([]string, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{root}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if DetectLoader(path) != nil {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// ingestFile loads, chunks, embeds and stores one file, replacing any chunks
+// previously stored for it. It returns the number of chunks written and the
+// warnings of the loader.
+func (p *Pipeline) ingestFile(ctx context.Context, path string) (int, []string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	loader := DetectLoader(path)
+	if loader == nil {
+		return 0, nil, errSkipped
+	}
+	limit := p.opts.MaxFileSize
+	if !isText(loader) {
+		limit = p.opts.MaxDocSize
+	}
+	if info.Size() > limit {
+		return 0, nil, errSkipped
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	docID, err := docIDFor(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if p.opts.Manifest != nil && !p.opts.Force && p.opts.Manifest.unchanged(docID, hash, p.opts.Collection) {
+		return 0, nil, errUnchanged
+	}
+
+	doc, err := loader.Load(path, data)
+	if err != nil {
+		return 0, nil, err
+	}
+	meta := map[string]string{
+		rag.MetaPath:       path,
+		rag.MetaMTime:      info.ModTime().UTC().Format(time.RFC3339),
+		rag.MetaHash:       hash,
+		rag.MetaCollection: p.opts.Collection,
+		rag.MetaType:       fileType(path, loader),
+		rag.MetaTitle:      filepath.Base(path),
+	}
+	if lang, ok := languages[strings.ToLower(filepath.Ext(path))]; ok {
+		meta[rag.MetaLanguage] = lang
+	}
+	for k, v := range p.opts.Metadata {
+		meta[k] = v
+	}
+	if p.opts.RelativeTo != "" {
+		if rel, err := filepath.Rel(p.opts.RelativeTo, docID); err == nil {
+			meta[rag.MetaRelPath] = filepath.ToSlash(rel)
+		}
+	}
+	n, err := p.store(ctx, docID, hash, doc, meta)
+	if err != nil {
+		return 0, nil, err
+	}
+	return n, doc.Warnings, nil
+}
+
+// store chunks, embeds and writes a loaded document under docID, replacing
+// any chunks previously stored for it. meta is copied onto every chunk,
+// overridden by the metadata of the document. It returns the number of
+// chunks written.
+func (p *Pipeline) store(ctx context.Context, docID, hash string, doc *Document, meta map[string]string) (int, error) {
+	for k, v := range doc.Metadata {
+		meta[k] = v
+	}
+
+	chunker := p.opts.Chunker
+	if c, ok := p.opts.TypeChunkers[meta[rag.MetaType]]; ok {
+		chunker = c
+	}
+
+	var chunks []rag.Chunk
+	for _, section := range doc.Sections {
+		pieces := []chunk.Piece{{Text: section.Text}}
+		if !section.Whole {
+			var err error
+			if pieces, err = chunker.Chunk(ctx, section.Text); err != nil {
+				return 0, fmt.Errorf("chunk: %w", err)
+			}
+		}
+		for _, piece := range pieces {
+			chunkMeta := make(map[string]string, len(meta)+len(section.Metadata)+len(piece.Metadata))
+			for _, m := range []map[string]string{meta, section.Metadata, piece.Metadata} {
+				for k, v := range m {
+					chunkMeta[k] = v
+				}
+			}
+			chunks = append(chunks, rag.Chunk{
+				ID:       fmt.Sprintf("%s#%d", docID, len(chunks)),
+				DocID:    docID,
+				Text:     piece.Text,
+				Metadata: chunkMeta,
+			})
+		}
+	}
+
+	if err := p.embed(ctx, chunks); err != nil {
+		return 0, err
+	}
+	if err := p.sink.DeleteDoc(ctx, docID); err != nil {
+		return 0, err
+	}
+	if len(chunks) > 0 {
+		if err := p.sink.Upsert(ctx, chunks); err != nil {
+			return 0, err
+		}
+	}
+	if p.opts.Manifest != nil {
+		p.opts.Manifest.set(docID, hash, p.opts.Collection)
+	}
+	return len(chunks), nil
+}
+
+// embed fills in the vectors of chunks in batches.
+func (p *Pipeline) embed(ctx context.Context, chunks []rag.Chunk) error {
+	if p.embedder == nil {
+		return nil
+	}
+	for start := 0; start < len(chunks); start += p.opts.BatchSize {
+		end := min(start+p.opts.BatchSize, len(chunks))
+		texts := make([]string, 0, end-start)
+		for _, c := range chunks[start:end] {
+			texts = append(texts, c.Text)
+		}
+		vectors, err := p.embedder.Embed(ctx, texts)
+		if err != nil {
+			return fmt.Errorf("embed chunks: %w", err)
+		}
+		if len(vectors) != len(texts) {
+			return fmt.Errorf("embed chunks: got %d vectors for %d texts", len(vectors), len(texts))
+		}
+		for i, v := range vectors {
+			chunks[start+i].Vector = v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go-groq/internal/rag"
+)
+
+// Repo is a git working tree.
+type Repo struct {
+	Root   string // absolute path of the working tree
+	Commit string // SHA of HEAD; empty before the first commit
+	Branch string // checked-out branch; empty when HEAD is detached
+	gitDir string
+}
+
+// OpenRepo finds the git working tree that contains path and reads its HEAD.
+// It reads .git directly, so git does not need to be installed.
+func OpenRepo(path string) (*Repo, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		if gitDir, err := findGitDir(dir); err == nil {
+			r := &Repo{Root: dir, gitDir: gitDir}
+			return r, r.readHead()
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			return nil, fmt.Errorf("%s is not inside a git working tree", path)
+		}
+	}
+}
+
+// Name returns the base name of the working tree, used as the repo metadata.
+func (r *Repo) Name() string {
+	return filepath.Base(r.Root)
+}
+
+// Metadata returns the chunk metadata shared by every file of the repository.
+func (r *Repo) Metadata() map[string]string {
+	meta := map[string]string{rag.MetaRepo: r.Name()}
+	if r.Commit != "" {
+		meta[rag.MetaCommit] = r.Commit
+	}
+	if r.Branch != "" {
+		meta[rag.MetaBranch] = r.Branch
+	}
+	return meta
+}
+
+// findGitDir returns the git directory of the working tree rooted at dir. A
+// .git file, as used by worktrees and submodules, points to it.
+func findGitDir(dir string) (string, error) {
+	p := filepath.Join(dir, ".git")
+	info, err := os.Stat(p)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return p, nil
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return "", fmt.Errorf("%s: not a gitdir file", p)
+	}
+	target = strings.TrimSpace(target)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(dir, target)
+	}
+	return target, nil
+}
+
+// readHead resolves HEAD to a branch and commit.
+func (r *Repo) readHead() error {
+	data, err := os.ReadFile(filepath.Join(r.gitDir, "HEAD"))
+	if err != nil {
+		return fmt.Errorf("read HEAD: %w", err)
+	}
+	head := strings.TrimSpace(string(data))
+	ref, ok := strings.CutPrefix(head, "ref: ")
+	if !ok {
+		r.Commit = head // detached
+		return nil
+	}
+	r.Branch = strings.TrimPrefix(ref, "refs/heads/")
+	r.Commit, err = r.resolveRef(ref)
+	return err
+}
+
+// resolveRef returns the commit a ref points to, looking in the loose refs
+// and then in packed-refs. An unborn branch resolves to "".
+func (r *Repo) resolveRef(ref string) (string, error) {
+	// Worktrees keep branch refs in the common git directory
+	dirs := []string{r.gitDir}
+	if common, err := os.ReadFile(filepath.Join(r.gitDir, "commondir")); err == nil {
+		c := strings.TrimSpace(string(common))
+		if !filepath.IsAbs(c) {
+			c = filepath.Join(r.gitDir, c)
+		}
+		dirs = append(dirs, c)
+	}
+	for _, dir := range dirs {
+		if data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(ref))); err == nil {
+			return strings.TrimSpace(string(data)), nil
+		}
+		f, err := os.Open(filepath.Join(dir, "packed-refs"))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			sha, name, ok := strings.Cut(scanner.Text(), " ")
+			if ok && name == ref {
+				f.Close()
+				return sha, nil
+			}
+		}
+		f.Close()
+	}
+	return "", nil
+}
+
+// vendorDirs are directories of third-party code that repository ingestion skips.
+var vendorDirs = map[string]bool{
+	"vendor":           true,
+	"node_modules":     true,
+	"third_party":      true,
+	"bower_components": true,
+	"Pods":             true,
+}
+
+// generatedNames match file names that are generated or minified by convention.
+var generatedNames = regexp.MustCompile(`(\.pb\.go|\.pb\.gw\.go|_generated\.\w+|\.generated\.\w+|\.min\.js|\.min\.css)$|^(package-lock|npm-shrinkwrap)\.json$`)
+
+// generatedHeader matches the markers that code generators put at the top of
+// their output, such as Go's "Code generated ... DO NOT EDIT."
+var generatedHeader = regexp.MustCompile(`(?m)^\W*(Code generated .*DO NOT EDIT|@generated\b|Autogenerated by|This file is automatically generated)`)
+
+// RepoScan is the result of walking a repository.
+type RepoScan struct {
+	Files     []string // files to ingest
+	Ignored   int      // files and directories excluded by ignore files
+	Vendored  int      // vendored directories skipped
+	Generated int      // generated files skipped
+	Binary    int      // binary files skipped
+}
+
+// Scan walks the working tree below path, which must lie inside r.Root. It
+// honors .gitignore files, .git/info/exclude and the custom ignoreFile (in
+// .gitignore syntax, looked up in every directory like .gitignore; "" for
+// none), and skips hidden and vendored directories, generated code and
+// binaries. If only is not nil, just the files it contains (absolute paths)
+// are checked and returned.
+func (r *Repo) Scan(path, ignoreFile string, only map[string]bool) (RepoScan, error) {
+	var scan RepoScan
+	start, err := filepath.Abs(path)
+	if err != nil {
+		return scan, err
+	}
+	if start != r.Root && !strings.HasPrefix(start, r.Root+string(filepath.Separator)) {
+		return scan, fmt.Errorf("%s is outside the repository %s", path, r.Root)
+	}
+
+	ig := &Ignore{}
+	if err := ig.AddFile(filepath.Join(r.gitDir, "info", "exclude"), ""); err != nil {
+		return scan, err
+	}
+	addIgnores := func(dir string) error {
+		base := r.rel(dir)
+		if err := ig.AddFile(filepath.Join(dir, ".gitignore"), base); err != nil {
+			return err
+		}
+		if ignoreFile != "" {
+			return ig.AddFile(filepath.Join(dir, ignoreFile), base)
+		}
+		return nil
+	}
+	// Rules from the directories above the starting point apply too
+	var above []string
+	for dir := start; dir != r.Root; {
+		dir = filepath.Dir(dir)
+		above = append([]string{dir}, above...)
+	}
+	for _, dir := range above {
+		if err := addIgnores(dir); err != nil {
+			return scan, err
+		}
+	}
+
+	err = filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := r.rel(p)
+		if d.IsDir() {
+			if p != start {
+				switch {
+				case strings.HasPrefix(d.Name(), "."):
+					return filepath.SkipDir
+				case vendorDirs[d.Name()]:
+					scan.Vendored++
+					return filepath.SkipDir
+				case ig.Match(rel, true):
+					scan.Ignored++
+					return filepath.SkipDir
+				}
+			}
+			return addIgnores(p)
+		}
+		if !d.Type().IsRegular() || (only != nil && !only[p]) {
+			return nil
+		}
+		loader := DetectLoader(p)
+		if loader == nil {
+			return nil
+		}
+		if ig.Match(rel, false) {
+			scan.Ignored++
+			return nil
+		}
+		if generatedNames.MatchString(d.Name()) {
+			scan.Generated++
+			return nil
+		}
+		if !isText(loader) {
+			scan.Files = append(scan.Files, p) // a document format such as PDF
+			return nil
+		}
+		head, err := readPrefix(p, 8000)
+		if err != nil {
+			return err
+		}
+		switch {
+		case bytes.IndexByte(head, 0) >= 0:
+			scan.Binary++
+		case generatedHeader.Match(head[:min(len(head), 1024)]):
+			scan.Generated++
+		default:
+			scan.Files = append(scan.Files, p)
+		}
+		return nil
+	})
+	return scan, err
+}
+
+// rel returns p relative to the repository root, slash-separated; "" for the root.
+func (r *Repo) rel(p string) string {
+	rel, err := filepath.Rel(r.Root, p)
+	if err != nil || rel == "." {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// readPrefix returns up to n bytes from the start of a file.
+func readPrefix(path string, n int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := make([]byte, n)
+	read, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return buf[:read], nil
+}
+
+// ChangedSince returns the absolute paths of the files that differ from
+// commit in the working tree, committed or not, together with untracked
+// files that are not ignored. Deleted files are included. It runs the git
+// command, which must be installed.
+func (r *Repo) ChangedSince(ctx context.Context, commit string) ([]string, error) {
+	changed, err := r.git(ctx, "diff", "--name-only", "-z", commit, "--")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := r.git(ctx, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, name := range append(changed, untracked...) {
+		paths = append(paths, filepath.Join(r.Root, filepath.FromSlash(name)))
+	}
+	return paths, nil
+}
+
+// git runs a git command in the working tree and splits its NUL-separated output.
+func (r *Repo) git(ctx context.Context, args ...string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", r.Root}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	var names []string
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,141 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robotsRules are the rules of a robots.txt file that apply to a crawler,
+// as specified by RFC 9309.
+type robotsRules struct {
+	rules []robotsRule
+	delay time.Duration // Crawl-delay, a common extension
+}
+
+// robotsRule is one Allow or Disallow line.
+type robotsRule struct {
+	allow   bool
+	pattern string // path prefix; * matches any characters and a final $ the end
+}
+
+// allowAll applies when a site has no robots.txt.
+var allowAll = &robotsRules{}
+
+// disallowAll applies when robots.txt cannot be fetched because of a server
+// error: the site may not want to be crawled.
+var disallowAll = &robotsRules{rules: []robotsRule{{pattern: "/"}}}
+
+// parseRobots returns the rules for agent: those of the groups naming its
+// product token, or else those of the groups for every agent ("*").
+func parseRobots(data []byte, agent string) *robotsRules {
+	token := strings.ToLower(agent)
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+
+	var (
+		mine, all     robotsRules
+		named         bool     // a group names agent, so the groups for every agent do not apply
+		agents        []string // user agents of the current group
+		inRules       bool     // a rule was seen since the last user-agent line
+		matched, star bool     // the current group is for agent, or for every agent
+	)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		if key == "user-agent" {
+			if inRules {
+				agents, inRules = nil, false
+			}
+			agents = append(agents, strings.ToLower(value))
+			matched, star = false, false
+			for _, a := range agents {
+				matched = matched || a == token
+				star = star || a == "*"
+			}
+			named = named || matched
+			continue
+		}
+		if len(agents) == 0 {
+			continue // rules before any user-agent line
+		}
+		inRules = true
+		target := &all
+		switch {
+		case matched:
+			target = &mine
+		case !star:
+			continue
+		}
+		switch key {
+		case "allow", "disallow":
+			if value != "" {
+				target.rules = append(target.rules, robotsRule{allow: key == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+				target.delay = time.Duration(min(secs, 60) * float64(time.Second))
+			}
+		}
+	}
+	if named {
+		return &mine
+	}
+	return &all
+}
+
+// allowed reports whether u may be fetched. The longest matching rule
+// decides; Allow wins a tie.
+func (r *robotsRules) allowed(u *url.URL) bool {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	best, allow := -1, true
+	for _, rule := range r.rules {
+		if n := len(rule.pattern); n >= best && robotsMatch(rule.pattern, path) {
+			if n > best || rule.allow {
+				allow = rule.allow
+			}
+			best = n
+		}
+	}
+	return allow
+}
+
+// robotsMatch reports whether path matches a robots.txt path pattern.
+func robotsMatch(pattern, path string) bool {
+	pattern, anchored := strings.CutSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	rest, ok := strings.CutPrefix(path, parts[0])
+	if !ok {
+		return false
+	}
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, part)
+		}
+		j := strings.Index(rest, part)
+		if j < 0 {
+			return false
+		}
+		rest = rest[j+len(part):]
+	}
+	return !anchored || rest == ""
+}
@@ -0,0 +1,131 @@
+package ingest
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go-groq/internal/rag"
+	"go-groq/internal/table"
+)
+
+// CSVLoader loads CSV and TSV files record by record. The first row names the
+// columns, every record is written as "column: value" lines, and records are
+// never split across sections. The column values of a section become its
+// metadata, so that retrieval can filter on them, as in @status:open.
+type CSVLoader struct {
+	RowsPerChunk int // records per section; defaults to 1
+}
+
+// Load implements Loader.
+func (l CSVLoader) Load(path string, data []byte) (*Document, error) {
+	return loadTable(path, data, l.RowsPerChunk)
+}
+
+// JSONLoader loads JSON and JSON Lines files record by record, like
+// CSVLoader. A JSON array holds one record per element, and so does the
+// longest array of objects in a JSON object, such as "items"; any other
+// document is a single record. Nested objects become columns named by their
+// path, such as owner.name.
+type JSONLoader struct {
+	RowsPerChunk int // records per section; defaults to 1
+}
+
+// Load implements Loader.
+func (l JSONLoader) Load(path string, data []byte) (*Document, error) {
+	return loadTable(path, data, l.RowsPerChunk)
+}
+
+// Limits on the metadata taken from columns.
+const (
+	maxMetaColumns = 30  // tables with more columns get no column metadata
+	maxMetaValue   = 100 // longer values, such as descriptions, are not metadata
+)
+
+// loadTable parses a table file and turns groups of rows records into sections.
+func loadTable(path string, data []byte, rows int) (*Document, error) {
+	if !utf8.Valid(data) {
+		data = []byte(latin1(string(data)))
+	}
+	t, warnings, err := table.Read(path, data)
+	if err != nil {
+		return nil, err
+	}
+	if rows <= 0 {
+		rows = 1
+	}
+	keys := columnKeys(t.Columns)
+
+	doc := &Document{Path: path, Warnings: warnings}
+	for start := 0; start < len(t.Rows); start += rows {
+		end := min(start+rows, len(t.Rows))
+		var b strings.Builder
+		for i := start; i < end; i++ {
+			if b.Len() > 0 {
+				b.WriteString("\n") // a blank line between records
+			}
+			for c, name := range t.Columns {
+				if v := t.Value(i, c); v != "" {
+					b.WriteString(name + ": " + v + "\n")
+				}
+			}
+		}
+		text := strings.TrimSpace(b.String())
+		if text == "" {
+			continue
+		}
+
+		// Values shared by every record of the section describe it
+		meta := make(map[string]string)
+		for c, key := range keys {
+			if key == "" {
+				continue
+			}
+			v := t.Value(start, c)
+			for i := start + 1; i < end && v != ""; i++ {
+				if t.Value(i, c) != v {
+					v = ""
+				}
+			}
+			if v != "" && utf8.RuneCountInString(v) <= maxMetaValue {
+				meta[key] = v
+			}
+		}
+		if t.Lines[start] > 0 {
+			meta[rag.MetaStartLine] = strconv.Itoa(t.Lines[start])
+			meta[rag.MetaEndLine] = strconv.Itoa(t.Lines[end-1])
+		}
+		doc.Sections = append(doc.Sections, Section{Text: text, Metadata: meta, Whole: true})
+	}
+	return doc, nil
+}
+
+// columnKeys returns the metadata key of every column: its name in lower
+// case with other characters than letters, digits, dots and dashes replaced
+// by underscores. Columns whose key the pipeline sets itself, or that repeat
+// an earlier key, get "".
+func columnKeys(columns []string) []string {
+	keys := make([]string, len(columns))
+	if len(columns) > maxMetaColumns {
+		return keys
+	}
+	used := make(map[string]bool)
+	for i, name := range columns {
+		var b strings.Builder
+		for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+			switch {
+			case r == '.' || r == '-' || r == '_' || ('a' <= r && r <= 'z') || ('0' <= r && r <= '9'):
+				b.WriteRune(r)
+			case !strings.HasSuffix(b.String(), "_"):
+				b.WriteByte('_')
+			}
+		}
+		key := strings.Trim(b.String(), "_")
+		if key == "" || reservedKeys[key] || used[key] {
+			continue
+		}
+		used[key] = true
+		keys[i] = key
+	}
+	return keys
+}
@@ -0,0 +1,177 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long a file must be quiet before it is re-ingested, so
+// that an editor saving in several writes causes one ingestion.
+const watchDebounce = 500 * time.Millisecond
+
+// Watch keeps the sink up to date with root until ctx is cancelled: files
+// that are created or modified are ingested again and the chunks of deleted
+// files are removed. It does not ingest root first; call Run for that. With
+// a Manifest, saving a file without changing it costs nothing. progress is
+// called for every file handled, and errors that do not stop the watch are
+// reported through it.
+func (p *Pipeline) Watch(ctx context.Context, root string, progress func(Progress)) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	only := "" // set when watching a single file
+	if info.IsDir() {
+		err = addWatches(w, root)
+	} else {
+		only = filepath.Clean(root)
+		err = w.Add(filepath.Dir(root))
+	}
+	if err != nil {
+		return err
+	}
+
+	report := func(e Progress) {
+		if progress != nil {
+			progress(e)
+		}
+	}
+	pending := make(map[string]time.Time)
+	ticker := time.NewTicker(watchDebounce / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			path := filepath.Clean(ev.Name)
+			if only != "" && path != only {
+				continue
+			}
+			if ev.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Remove|fsnotify.Rename) != 0 {
+				pending[path] = time.Now()
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				// Events were lost: catch up by comparing everything with the manifest
+				if _, err := p.Run(ctx, root, progress); err != nil && ctx.Err() == nil {
+					report(Progress{File: root, Err: err})
+				}
+				continue
+			}
+			report(Progress{File: root, Err: err})
+		case now := <-ticker.C:
+			for path, last := range pending {
+				if now.Sub(last) < watchDebounce {
+					continue
+				}
+				delete(pending, path)
+				p.update(ctx, w, path, report)
+				if ctx.Err() != nil {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// update brings the index in line with path after it changed on disk.
+func (p *Pipeline) update(ctx context.Context, w *fsnotify.Watcher, path string, report func(Progress)) {
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		p.removePath(ctx, path, report)
+	case err != nil:
+		report(Progress{File: path, Err: err})
+	case info.IsDir():
+		// A new or moved-in directory: watch it and ingest what it holds
+		if isHidden(path) {
+			return
+		}
+		if err := addWatches(w, path); err != nil {
+			report(Progress{File: path, Err: err})
+		}
+		if _, err := p.Run(ctx, path, report); err != nil && ctx.Err() == nil {
+			report(Progress{File: path, Err: err})
+		}
+	default:
+		if DetectLoader(path) == nil {
+			return
+		}
+		n, warnings, err := p.ingestFile(ctx, path)
+		switch {
+		case errors.Is(err, errUnchanged), errors.Is(err, errSkipped):
+		case err != nil:
+			if ctx.Err() == nil {
+				report(Progress{File: path, Err: err})
+			}
+		default:
+			report(Progress{File: path, Done: 1, Total: 1, Chunks: n, Warnings: warnings})
+		}
+	}
+}
+
+// removePath deletes the chunks of a removed file, or of every file below a
+// removed directory.
+func (p *Pipeline) removePath(ctx context.Context, path string, report func(Progress)) {
+	id, err := docIDFor(path)
+	if err != nil {
+		report(Progress{File: path, Err: err})
+		return
+	}
+	if p.opts.Manifest != nil {
+		if _, err := p.removeMissing(ctx, id, nil, report); err != nil {
+			report(Progress{File: path, Err: err})
+		}
+		return
+	}
+	if LoaderFor(path) == nil {
+		return
+	}
+	if err := p.sink.DeleteDoc(ctx, id); err != nil {
+		report(Progress{File: path, Err: err})
+		return
+	}
+	report(Progress{File: path, Removed: true})
+}
+
+// addWatches watches dir and every directory below it, skipping hidden ones
+// as ingestion does.
+func addWatches(w *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		return w.Add(path)
+	})
+}
+
+// isHidden reports whether the last element of path starts with a dot.
+func isHidden(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), ".")
+}
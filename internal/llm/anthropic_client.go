@@ -75,7 +75,7 @@ func (c *AnthropicClient) Generate(ctx context.Context, messages []Message) (str
 		Messages  []anthropicMessage `json:"messages"`
 	}{
 		Model:     c.model,
-		MaxTokens: 1024,
+		MaxTokens: DefaultMaxTokens,
 		System:    systemPrompt,
 		Messages:  anthropicMsgs,
 	}
@@ -106,7 +106,7 @@ func (c *AnthropicClient) Generate(ctx context.Context, messages []Message) (str
 		return "", fmt.Errorf("read response: %w", err)
 	}
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("Anthropic API error %d: %s", resp.StatusCode, string(body))
+		return "", &APIError{Provider: "Anthropic", StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var anthropicResp anthropicResponse
@@ -0,0 +1,374 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheOptions configures a CachingClient.
+type CacheOptions struct {
+	Dir                 string        // directory holding one JSON file per cached response
+	TTL                 time.Duration // entries older than this are ignored; 0 means never expire
+	MaxBytes            int64         // total size cap for Dir; 0 means unlimited
+	Semantic            bool          // reuse answers for near-identical questions when an embedder is set
+	SimilarityThreshold float64       // minimum cosine similarity for a semantic hit
+}
+
+// CacheStatus reports how a single request was served.
+// Attach one to the context with WithCacheStatus before calling Generate.
+type CacheStatus struct {
+	Hit        bool          // the response came from the cache
+	Semantic   bool          // the hit was a near-identical question rather than an exact match
+	Similarity float64       // cosine similarity of a semantic hit
+	Age        time.Duration // age of the cached response
+}
+
+type cacheContextKey int
+
+const (
+	cacheStatusKey cacheContextKey = iota
+	cacheBypassKey
+)
+
+// WithCacheStatus returns a context that makes a CachingClient record how
+// the request was served into status.
+func WithCacheStatus(ctx context.Context, status *CacheStatus) context.Context {
+	return context.WithValue(ctx, cacheStatusKey, status)
+}
+
+// WithoutCache returns a context that makes a CachingClient skip lookups.
+// The fresh response still replaces any cached one.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheBypassKey, true)
+}
+
+// Detached returns a context for a call made on behalf of another request,
+// such as moderating its answer. The call records its own cache status
+// instead of overwriting the request's, and uses the cache even when the
+// request bypasses it.
+func Detached(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, cacheBypassKey, false)
+	return WithCacheStatus(ctx, new(CacheStatus))
+}
+
+// cacheEntry is the on-disk representation of a cached response.
+type cacheEntry struct {
+	Key            string    `json:"key"`
+	Scope          string    `json:"scope"`
+	Question       string    `json:"question"`
+	Embedding      []float32 `json:"embedding,omitempty"`
+	EmbeddingModel string    `json:"embedding_model,omitempty"`
+	Response       string    `json:"response"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// semanticEntry is the in-memory index entry used for semantic lookups.
+type semanticEntry struct {
+	key       string
+	scope     string
+	embedding []float32
+}
+
+// CachingClient is an LLMClient decorator that stores responses on disk,
+// keyed on a hash of provider, model, generation options and messages.
+type CachingClient struct {
+	next     LLMClient
+	provider string
+	model    string
+	opts     CacheOptions
+	embedder EmbeddingClient
+
+	mu       sync.Mutex
+	loaded   bool
+	semantic []semanticEntry
+}
+
+// NewCachingClient wraps next with a response cache. embedder may be nil, in
+// which case semantic matching is disabled.
+func NewCachingClient(next LLMClient, provider, model string, opts CacheOptions, embedder EmbeddingClient) *CachingClient {
+	if opts.SimilarityThreshold <= 0 {
+		opts.SimilarityThreshold = 0.95
+	}
+	return &CachingClient{
+		next:     next,
+		provider: provider,
+		model:    model,
+		opts:     opts,
+		embedder: embedder,
+	}
+}
+
+// Generate returns a cached response when one exists, otherwise it calls the
+// wrapped client and caches the result.
+func (c *CachingClient) Generate(ctx context.Context, messages []Message) (string, error) {
+	status, _ := ctx.Value(cacheStatusKey).(*CacheStatus)
+	bypass, _ := ctx.Value(cacheBypassKey).(bool)
+	key := c.hash(messages)
+	scope := c.hash(systemMessages(messages))
+	question := lastUserMessage(messages)
+
+	if !bypass {
+		if entry, ok := c.load(key); ok {
+			if status != nil {
+				*status = CacheStatus{Hit: true, Age: time.Since(entry.CreatedAt)}
+			}
+			return entry.Response, nil
+		}
+	}
+
+	// Semantic lookups are best effort: an embedding failure only costs a miss.
+	var vector []float32
+	if c.opts.Semantic && c.embedder != nil && question != "" {
+		if vectors, err := c.embedder.Embed(ctx, []string{question}); err == nil && len(vectors) == 1 {
+			vector = vectors[0]
+		}
+		if !bypass && vector != nil {
+			if entry, sim, ok := c.nearest(scope, vector); ok {
+				if status != nil {
+					*status = CacheStatus{Hit: true, Semantic: true, Similarity: sim, Age: time.Since(entry.CreatedAt)}
+				}
+				return entry.Response, nil
+			}
+		}
+	}
+
+	answer, err := c.next.Generate(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+
+	entry := cacheEntry{
+		Key:       key,
+		Scope:     scope,
+		Question:  question,
+		Response:  answer,
+		CreatedAt: time.Now(),
+	}
+	if vector != nil {
+		entry.Embedding = vector
+		entry.EmbeddingModel = c.embedder.Model()
+	}
+	// A cache that cannot be written must never fail the request.
+	_ = c.store(entry)
+	return answer, nil
+}
+
+// hash returns a stable key for the messages under this client's provider,
+// model and generation options.
+func (c *CachingClient) hash(messages []Message) string {
+	data, _ := json.Marshal(struct {
+		Provider    string    `json:"provider"`
+		Model       string    `json:"model"`
+		Temperature float64   `json:"temperature"`
+		MaxTokens   int       `json:"max_tokens"`
+		Messages    []Message `json:"messages"`
+	}{c.provider, c.model, DefaultTemperature, DefaultMaxTokens, normalizeMessages(messages)})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *CachingClient) path(key string) string {
+	return filepath.Join(c.opts.Dir, key+".json")
+}
+
+// load reads the entry for key, dropping it if it has expired.
+func (c *CachingClient) load(key string) (cacheEntry, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return cacheEntry{}, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return cacheEntry{}, false
+	}
+	if c.expired(entry) {
+		c.mu.Lock()
+		c.remove(key)
+		c.mu.Unlock()
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *CachingClient) expired(entry cacheEntry) bool {
+	return c.opts.TTL > 0 && time.Since(entry.CreatedAt) > c.opts.TTL
+}
+
+// nearest returns the most similar cached question in scope above the threshold.
+func (c *CachingClient) nearest(scope string, vector []float32) (cacheEntry, float64, bool) {
+	c.mu.Lock()
+	c.loadIndex()
+	bestKey, bestSim := "", 0.0
+	for _, e := range c.semantic {
+		if e.scope != scope {
+			continue
+		}
+		if sim := CosineSimilarity(vector, e.embedding); sim >= c.opts.SimilarityThreshold && sim > bestSim {
+			bestKey, bestSim = e.key, sim
+		}
+	}
+	c.mu.Unlock()
+
+	if bestKey == "" {
+		return cacheEntry{}, 0, false
+	}
+	entry, ok := c.load(bestKey)
+	return entry, bestSim, ok
+}
+
+// loadIndex reads the embeddings of all cached entries once. Callers hold c.mu.
+func (c *CachingClient) loadIndex() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	files, _ := filepath.Glob(filepath.Join(c.opts.Dir, "*.json"))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var entry cacheEntry
+		if err := json.Unmarshal(data, &entry); err != nil || c.expired(entry) {
+			continue
+		}
+		c.index(entry)
+	}
+}
+
+// index adds entry to the semantic index if it was embedded with the current model.
+// Callers hold c.mu.
+func (c *CachingClient) index(entry cacheEntry) {
+	if len(entry.Embedding) == 0 || c.embedder == nil || entry.EmbeddingModel != c.embedder.Model() {
+		return
+	}
+	c.semantic = append(c.semantic, semanticEntry{key: entry.Key, scope: entry.Scope, embedding: entry.Embedding})
+}
+
+// store writes entry atomically and then enforces the size cap.
+func (c *CachingClient) store(entry cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(c.opts.Dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.opts.Dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path(entry.Key)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if c.loaded {
+		c.dropFromIndex(entry.Key)
+		c.index(entry)
+	}
+	c.evict()
+	return nil
+}
+
+// evict removes the oldest entries until the cache fits in MaxBytes.
+// Callers hold c.mu.
+func (c *CachingClient) evict() {
+	if c.opts.MaxBytes <= 0 {
+		return
+	}
+	files, _ := filepath.Glob(filepath.Join(c.opts.Dir, "*.json"))
+	type fileInfo struct {
+		key     string
+		size    int64
+		modTime time.Time
+	}
+	var infos []fileInfo
+	var total int64
+	for _, file := range files {
+		fi, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		infos = append(infos, fileInfo{
+			key:     strings.TrimSuffix(filepath.Base(file), ".json"),
+			size:    fi.Size(),
+			modTime: fi.ModTime(),
+		})
+		total += fi.Size()
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].modTime.Before(infos[j].modTime) })
+	for _, fi := range infos {
+		if total <= c.opts.MaxBytes {
+			break
+		}
+		c.remove(fi.key)
+		total -= fi.size
+	}
+}
+
+// remove deletes the entry for key. Callers hold c.mu.
+func (c *CachingClient) remove(key string) {
+	os.Remove(c.path(key))
+	c.dropFromIndex(key)
+}
+
+func (c *CachingClient) dropFromIndex(key string) {
+	kept := c.semantic[:0]
+	for _, e := range c.semantic {
+		if e.key != key {
+			kept = append(kept, e)
+		}
+	}
+	c.semantic = kept
+}
+
+// normalizeMessages trims surrounding whitespace and lowercases roles so that
+// cosmetic differences do not defeat the cache.
+func normalizeMessages(messages []Message) []Message {
+	out := make([]Message, len(messages))
+	for i, m := range messages {
+		out[i] = Message{Role: strings.ToLower(m.Role), Content: strings.TrimSpace(m.Content)}
+	}
+	return out
+}
+
+func systemMessages(messages []Message) []Message {
+	var out []Message
+	for _, m := range messages {
+		if m.Role == "system" {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func lastUserMessage(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return strings.TrimSpace(messages[i].Content)
+		}
+	}
+	return ""
+}
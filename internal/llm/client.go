@@ -1,7 +1,11 @@
 // Package llm provides a pluggable interface for LLM providers.
 package llm
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
 
 // Message represents a single message in a conversation.
 type Message struct {
@@ -14,3 +18,25 @@ type LLMClient interface {
 	// Generate returns the model's response for the given messages.
 	Generate(ctx context.Context, messages []Message) (string, error)
 }
+
+// Default generation parameters shared by all providers.
+const (
+	DefaultTemperature = 0.7
+	DefaultMaxTokens   = 1024
+)
+
+// APIError is returned when a provider responds with a non-200 status.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API error %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// Retryable reports whether the same request may succeed if sent again.
+func (e *APIError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
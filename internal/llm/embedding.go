@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// EmbeddingClient is implemented by providers that can turn text into vectors.
+type EmbeddingClient interface {
+	// Embed returns one vector per input text, in the same order.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// Model returns the embedding model name. Vectors from different models
+	// must never be compared with each other.
+	Model() string
+}
+
+// NewEmbeddingClient returns an EmbeddingClient for the specified provider.
+// Supported providers: "openai", "gemini" and "local", which needs no API
+// key or network. An empty model selects the provider's default embedding
+// model.
+func NewEmbeddingClient(provider, apiKey, model string) (EmbeddingClient, error) {
+	switch provider {
+	case "openai":
+		if model == "" {
+			model = "text-embedding-3-small"
+		}
+		return NewOpenAIEmbeddingClient(apiKey, model), nil
+	case "gemini":
+		if model == "" {
+			model = "text-embedding-004"
+		}
+		return NewGeminiEmbeddingClient(apiKey, model), nil
+	case "local":
+		dim, err := parseLocalModel(model)
+		if err != nil {
+			return nil, err
+		}
+		return NewLocalEmbeddingClient(dim), nil
+	default:
+		return nil, fmt.Errorf("unsupported embedding provider: %q (supported: openai, gemini, local)", provider)
+	}
+}
+
+// CosineSimilarity returns the cosine similarity of a and b, or 0 if the
+// vectors differ in length or either is all zeros.
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
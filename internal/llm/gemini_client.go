@@ -89,8 +89,8 @@ func (c *GeminiClient) Generate(ctx context.Context, messages []Message) (string
 		Contents:          contents,
 		SystemInstruction: systemInstruction,
 		GenerationConfig: geminiGenerationConfig{
-			Temperature:     0.7,
-			MaxOutputTokens: 1024,
+			Temperature:     DefaultTemperature,
+			MaxOutputTokens: DefaultMaxTokens,
 		},
 	}
 
@@ -120,7 +120,7 @@ func (c *GeminiClient) Generate(ctx context.Context, messages []Message) (string
 		return "", fmt.Errorf("read response: %w", err)
 	}
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("Gemini API error %d: %s", resp.StatusCode, string(body))
+		return "", &APIError{Provider: "Gemini", StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var geminiResp geminiResponse
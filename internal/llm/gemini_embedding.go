@@ -0,0 +1,108 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// GeminiEmbeddingClient implements EmbeddingClient for the Google Gemini API.
+type GeminiEmbeddingClient struct {
+	apiKey string
+	model  string
+	client *http.Client
+}
+
+// NewGeminiEmbeddingClient creates a new Google Gemini embedding client.
+func NewGeminiEmbeddingClient(apiKey, model string) *GeminiEmbeddingClient {
+	return &GeminiEmbeddingClient{
+		apiKey: apiKey,
+		model:  model,
+		client: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+	}
+}
+
+// geminiEmbedRequest is the request payload for the Gemini batchEmbedContents API.
+type geminiEmbedRequest struct {
+	Requests []geminiEmbedContentRequest `json:"requests"`
+}
+
+type geminiEmbedContentRequest struct {
+	Model   string        `json:"model"`
+	Content geminiContent `json:"content"`
+}
+
+// geminiEmbedResponse is the response payload from the Gemini batchEmbedContents API.
+type geminiEmbedResponse struct {
+	Embeddings []struct {
+		Values []float32 `json:"values"`
+	} `json:"embeddings"`
+}
+
+// Model returns the embedding model name.
+func (c *GeminiEmbeddingClient) Model() string {
+	return c.model
+}
+
+// Embed sends the texts to the Gemini API and returns one vector per text.
+func (c *GeminiEmbeddingClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	reqBody := geminiEmbedRequest{}
+	for _, text := range texts {
+		reqBody.Requests = append(reqBody.Requests, geminiEmbedContentRequest{
+			Model:   "models/" + c.model,
+			Content: geminiContent{Parts: []geminiPart{{Text: text}}},
+		})
+	}
+
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:batchEmbedContents",
+		c.model)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Goog-Api-Key", c.apiKey)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("call Gemini embeddings API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &APIError{Provider: "Gemini embeddings", StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var embResp geminiEmbedResponse
+	if err := json.Unmarshal(body, &embResp); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+	if len(embResp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("Gemini returned %d embeddings for %d inputs", len(embResp.Embeddings), len(texts))
+	}
+	vectors := make([][]float32, len(texts))
+	for i, e := range embResp.Embeddings {
+		vectors[i] = e.Values
+	}
+	return vectors, nil
+}
@@ -50,8 +50,8 @@ func (c *GroqClient) Generate(ctx context.Context, messages []Message) (string,
 	reqBody := groqRequest{
 		Model:       c.model,
 		Messages:    messages,
-		Temperature: 0.7,
-		MaxTokens:   1024,
+		Temperature: DefaultTemperature,
+		MaxTokens:   DefaultMaxTokens,
 	}
 
 	data, err := json.Marshal(reqBody)
@@ -79,7 +79,7 @@ func (c *GroqClient) Generate(ctx context.Context, messages []Message) (string,
 		return "", fmt.Errorf("read response: %w", err)
 	}
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("Groq API error %d: %s", resp.StatusCode, string(body))
+		return "", &APIError{Provider: "Groq", StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var groqResp groqResponse
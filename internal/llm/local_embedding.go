@@ -0,0 +1,161 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultLocalDimension is the vector size of LocalEmbeddingClient when the
+// model does not name one.
+const DefaultLocalDimension = 512
+
+// LocalEmbeddingClient implements EmbeddingClient without a network or a
+// model file. Texts are turned into hashed feature vectors: every word,
+// every pair of neighbouring words and the character trigrams of every word
+// are hashed into one of Dimension buckets, weighted by the logarithm of
+// their count, and the vector is normalized. Texts that share words, or
+// parts of words such as "ingest" and "ingestion", end up close together.
+// It captures no synonyms, so it retrieves worse than an embedding model,
+// but it is deterministic and works on air-gapped machines and in CI.
+type LocalEmbeddingClient struct {
+	dim int
+}
+
+// NewLocalEmbeddingClient creates a local embedding client producing
+// vectors of dim dimensions.
+func NewLocalEmbeddingClient(dim int) *LocalEmbeddingClient {
+	return &LocalEmbeddingClient{dim: dim}
+}
+
+// parseLocalModel returns the dimension named by a local model, "hash" or
+// "hash-<dimension>".
+func parseLocalModel(model string) (int, error) {
+	if model == "" || model == "hash" {
+		return DefaultLocalDimension, nil
+	}
+	size, ok := strings.CutPrefix(model, "hash-")
+	dim, err := strconv.Atoi(size)
+	if !ok || err != nil || dim < 16 || dim > 8192 {
+		return 0, fmt.Errorf("unsupported local embedding model %q (expected hash or hash-<dimension>, 16 to 8192)", model)
+	}
+	return dim, nil
+}
+
+// Model returns the embedding model name, which includes the dimension so
+// that indexes built with different sizes are told apart.
+func (c *LocalEmbeddingClient) Model() string {
+	return fmt.Sprintf("hash-%d", c.dim)
+}
+
+// Embed returns one vector per text. A text without words gets the zero vector.
+func (c *LocalEmbeddingClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		vectors[i] = c.embed(text)
+	}
+	return vectors, nil
+}
+
+// Weights of the feature kinds. Whole words carry the meaning; trigrams match
+// inflections and identifiers split differently, and pairs word order.
+const (
+	wordWeight    = 1.0
+	pairWeight    = 0.5
+	trigramWeight = 0.25
+)
+
+func (c *LocalEmbeddingClient) embed(text string) []float32 {
+	counts := make(map[string]float64)
+	words := localWords(text)
+	for i, w := range words {
+		if !stopWords[w] {
+			counts["w:"+w] += wordWeight
+			runes := []rune("<" + w + ">")
+			for j := 0; j+3 <= len(runes); j++ {
+				counts["t:"+string(runes[j:j+3])] += trigramWeight
+			}
+		}
+		if i > 0 && !(stopWords[w] && stopWords[words[i-1]]) {
+			counts["p:"+words[i-1]+" "+w] += pairWeight
+		}
+	}
+
+	vector := make([]float64, c.dim)
+	for feature, count := range counts {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		// The sign bit keeps collisions from only ever adding up
+		weight := 1 + math.Log(count)
+		if count < 1 {
+			weight = count
+		}
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		vector[sum%uint64(c.dim)] += weight
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	out := make([]float32, c.dim)
+	if norm == 0 {
+		return out
+	}
+	norm = math.Sqrt(norm)
+	for i, v := range vector {
+		out[i] = float32(v / norm)
+	}
+	return out
+}
+
+// localWords splits text into lower-case words of letters and digits, also
+// splitting camelCase identifiers, so that "SwitchModel" gives "switch" and
+// "model".
+func localWords(text string) []string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	var prev rune
+	for _, r := range text {
+		switch {
+		case unicode.IsUpper(r) && unicode.IsLower(prev):
+			flush()
+			word = append(word, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+		default:
+			flush()
+		}
+		prev = r
+	}
+	flush()
+	return words
+}
+
+// stopWords are frequent English words that say little about a text. They
+// are left out as words and trigrams, but kept in pairs with other words.
+var stopWords = func() map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(`a an and are as at be but by can do does for from had has have
+		how i if in is it its of on or so that the their then there these this to was were what
+		when where which who why will with you your`) {
+		m[w] = true
+	}
+	return m
+}()
@@ -0,0 +1,104 @@
+package llm
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MetricsKey identifies one series of usage statistics.
+type MetricsKey struct {
+	Provider string
+	Model    string
+	Label    string // call label, see WithCallLabel
+}
+
+// CallStats holds usage statistics for one MetricsKey.
+type CallStats struct {
+	Calls         int
+	Errors        int
+	CacheHits     int
+	TotalLatency  time.Duration
+	PromptChars   int
+	ResponseChars int
+}
+
+// Metrics collects usage statistics across clients. It is safe for
+// concurrent use and survives provider switches.
+type Metrics struct {
+	mu    sync.Mutex
+	stats map[MetricsKey]*CallStats
+}
+
+// NewMetrics creates an empty Metrics collector.
+func NewMetrics() *Metrics {
+	return &Metrics{stats: make(map[MetricsKey]*CallStats)}
+}
+
+// Middleware returns a Middleware that records every request made through
+// a client for provider and model.
+func (m *Metrics) Middleware(provider, model string) Middleware {
+	return func(next LLMClient) LLMClient {
+		return ClientFunc(func(ctx context.Context, messages []Message) (string, error) {
+			// Use a private status so cache hits are counted even when the
+			// caller did not ask for one, then pass it through.
+			outer, _ := ctx.Value(cacheStatusKey).(*CacheStatus)
+			var status CacheStatus
+			start := time.Now()
+			response, err := next.Generate(WithCacheStatus(ctx, &status), messages)
+			if outer != nil {
+				*outer = status
+			}
+
+			key := MetricsKey{Provider: provider, Model: model, Label: CallLabel(ctx)}
+			m.mu.Lock()
+			s, ok := m.stats[key]
+			if !ok {
+				s = &CallStats{}
+				m.stats[key] = s
+			}
+			s.Calls++
+			s.TotalLatency += time.Since(start)
+			s.PromptChars += promptChars(messages)
+			if err != nil {
+				s.Errors++
+			} else {
+				s.ResponseChars += len(response)
+			}
+			if status.Hit {
+				s.CacheHits++
+			}
+			m.mu.Unlock()
+
+			return response, err
+		})
+	}
+}
+
+// MetricsEntry pairs a key with its statistics.
+type MetricsEntry struct {
+	Key   MetricsKey
+	Stats CallStats
+}
+
+// Snapshot returns a copy of all statistics sorted by provider, model and label.
+func (m *Metrics) Snapshot() []MetricsEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := make([]MetricsEntry, 0, len(m.stats))
+	for k, s := range m.stats {
+		entries = append(entries, MetricsEntry{Key: k, Stats: *s})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i].Key, entries[j].Key
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		return a.Label < b.Label
+	})
+	return entries
+}
@@ -0,0 +1,116 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// Middleware wraps an LLMClient with cross-cutting behaviour such as
+// logging, retries or caching.
+type Middleware func(LLMClient) LLMClient
+
+// ClientFunc adapts an ordinary function to the LLMClient interface.
+type ClientFunc func(ctx context.Context, messages []Message) (string, error)
+
+// Generate calls f(ctx, messages).
+func (f ClientFunc) Generate(ctx context.Context, messages []Message) (string, error) {
+	return f(ctx, messages)
+}
+
+// Chain wraps client with the given middleware. The first middleware is the
+// outermost one, so it sees the request first and the response last.
+func Chain(client LLMClient, mws ...Middleware) LLMClient {
+	for i := len(mws) - 1; i >= 0; i-- {
+		client = mws[i](client)
+	}
+	return client
+}
+
+// BeforeFunc inspects or rewrites the messages before they are sent.
+// Returning an error aborts the request.
+type BeforeFunc func(ctx context.Context, messages []Message) ([]Message, error)
+
+// AfterFunc inspects or rewrites the response. messages are the ones that
+// were actually sent.
+type AfterFunc func(ctx context.Context, messages []Message, response string) (string, error)
+
+// Hooks returns a Middleware that runs before on the request and after on the
+// response. Either hook may be nil.
+func Hooks(before BeforeFunc, after AfterFunc) Middleware {
+	return func(next LLMClient) LLMClient {
+		return ClientFunc(func(ctx context.Context, messages []Message) (string, error) {
+			if before != nil {
+				var err error
+				if messages, err = before(ctx, messages); err != nil {
+					return "", err
+				}
+			}
+			response, err := next.Generate(ctx, messages)
+			if err != nil {
+				return "", err
+			}
+			if after != nil {
+				return after(ctx, messages, response)
+			}
+			return response, nil
+		})
+	}
+}
+
+// MiddlewareFactory creates a Middleware for a client of the given provider
+// and model. It may return a nil Middleware to skip the stage, for example
+// when the feature is disabled in configuration.
+type MiddlewareFactory func(provider, model string) (Middleware, error)
+
+// Builder assembles a client from named middleware stages.
+type Builder struct {
+	factories map[string]MiddlewareFactory
+}
+
+// NewBuilder creates an empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{factories: make(map[string]MiddlewareFactory)}
+}
+
+// Register makes a middleware stage available under name.
+func (b *Builder) Register(name string, factory MiddlewareFactory) *Builder {
+	b.factories[name] = factory
+	return b
+}
+
+// Build wraps client with the stages listed in names, outermost first.
+func (b *Builder) Build(client LLMClient, provider, model string, names []string) (LLMClient, error) {
+	var mws []Middleware
+	for _, name := range names {
+		factory, ok := b.factories[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown middleware %q", name)
+		}
+		mw, err := factory(provider, model)
+		if err != nil {
+			return nil, fmt.Errorf("middleware %s: %w", name, err)
+		}
+		if mw != nil {
+			mws = append(mws, mw)
+		}
+	}
+	return Chain(client, mws...), nil
+}
+
+type labelContextKey int
+
+const callLabelKey labelContextKey = 0
+
+// WithCallLabel tags the requests made with ctx, e.g. "chat" or "rewrite",
+// so logging and metrics can tell them apart.
+func WithCallLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, callLabelKey, label)
+}
+
+// CallLabel returns the label set with WithCallLabel, or "chat".
+func CallLabel(ctx context.Context) string {
+	if label, ok := ctx.Value(callLabelKey).(string); ok && label != "" {
+		return label
+	}
+	return "chat"
+}
@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// OpenAIEmbeddingClient implements EmbeddingClient for the OpenAI API.
+type OpenAIEmbeddingClient struct {
+	apiKey string
+	model  string
+	client *http.Client
+}
+
+// NewOpenAIEmbeddingClient creates a new OpenAI embedding client.
+func NewOpenAIEmbeddingClient(apiKey, model string) *OpenAIEmbeddingClient {
+	return &OpenAIEmbeddingClient{
+		apiKey: apiKey,
+		model:  model,
+		client: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+	}
+}
+
+// openaiEmbeddingRequest is the request payload for the OpenAI embeddings API.
+type openaiEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// openaiEmbeddingResponse is the response payload from the OpenAI embeddings API.
+type openaiEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Model returns the embedding model name.
+func (c *OpenAIEmbeddingClient) Model() string {
+	return c.model
+}
+
+// Embed sends the texts to the OpenAI embeddings API and returns one vector per text.
+func (c *OpenAIEmbeddingClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(openaiEmbeddingRequest{Model: c.model, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		"https://api.openai.com/v1/embeddings",
+		bytes.NewBuffer(data))
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("call OpenAI embeddings API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &APIError{Provider: "OpenAI embeddings", StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var embResp openaiEmbeddingResponse
+	if err := json.Unmarshal(body, &embResp); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+	if len(embResp.Data) != len(texts) {
+		return nil, fmt.Errorf("OpenAI returned %d embeddings for %d inputs", len(embResp.Data), len(texts))
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range embResp.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("OpenAI returned embedding with invalid index %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
@@ -54,8 +54,8 @@ func (c *OpenRouterClient) Generate(ctx context.Context, messages []Message) (st
 	reqBody := openrouterRequest{
 		Model:       c.model,
 		Messages:    messages,
-		Temperature: 0.7,
-		MaxTokens:   1024,
+		Temperature: DefaultTemperature,
+		MaxTokens:   DefaultMaxTokens,
 	}
 
 	data, err := json.Marshal(reqBody)
@@ -85,7 +85,7 @@ func (c *OpenRouterClient) Generate(ctx context.Context, messages []Message) (st
 		return "", fmt.Errorf("read response: %w", err)
 	}
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("OpenRouter API error %d: %s", resp.StatusCode, string(body))
+		return "", &APIError{Provider: "OpenRouter", StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var orResp openrouterResponse
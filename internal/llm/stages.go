@@ -0,0 +1,141 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// Logging returns a Middleware that logs one line per request with its label,
+// size, latency and outcome. Message contents are never logged.
+func Logging(logger *log.Logger, provider, model string) Middleware {
+	return func(next LLMClient) LLMClient {
+		return ClientFunc(func(ctx context.Context, messages []Message) (string, error) {
+			start := time.Now()
+			response, err := next.Generate(ctx, messages)
+			if err != nil {
+				logger.Printf("llm %s/%s [%s] messages=%d prompt_chars=%d error=%q duration=%s",
+					provider, model, CallLabel(ctx), len(messages), promptChars(messages), err.Error(), time.Since(start).Round(time.Millisecond))
+				return "", err
+			}
+			logger.Printf("llm %s/%s [%s] messages=%d prompt_chars=%d response_chars=%d duration=%s",
+				provider, model, CallLabel(ctx), len(messages), promptChars(messages), len(response), time.Since(start).Round(time.Millisecond))
+			return response, nil
+		})
+	}
+}
+
+// Retry returns a Middleware that retries failed requests up to attempts
+// times in total, doubling the delay after each failure. Only rate limits,
+// server errors and transport failures are retried.
+func Retry(attempts int, backoff time.Duration) Middleware {
+	return func(next LLMClient) LLMClient {
+		return ClientFunc(func(ctx context.Context, messages []Message) (string, error) {
+			delay := backoff
+			var err error
+			for attempt := 1; ; attempt++ {
+				var response string
+				response, err = next.Generate(ctx, messages)
+				if err == nil {
+					return response, nil
+				}
+				if attempt >= attempts || !retryable(ctx, err) {
+					return "", err
+				}
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return "", ctx.Err()
+				}
+				delay *= 2
+			}
+		})
+	}
+}
+
+// retryable reports whether err is worth another attempt.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	// Transport failures (DNS, connection reset, timeouts, a body cut short)
+	// may pass; malformed responses and request-building errors fail the same
+	// way on every attempt.
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// RateLimit returns a Middleware that allows at most rps requests per second
+// on average, with bursts of up to burst requests.
+func RateLimit(rps float64, burst int) Middleware {
+	if burst < 1 {
+		burst = 1
+	}
+	limiter := &tokenBucket{rate: rps, capacity: float64(burst), tokens: float64(burst), last: time.Now()}
+	return func(next LLMClient) LLMClient {
+		return ClientFunc(func(ctx context.Context, messages []Message) (string, error) {
+			if err := limiter.wait(ctx); err != nil {
+				return "", err
+			}
+			return next.Generate(ctx, messages)
+		})
+	}
+}
+
+// tokenBucket is a minimal token-bucket rate limiter.
+type tokenBucket struct {
+	mu       sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+// wait blocks until a token is available or ctx is done.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Cache returns a Middleware that wraps the client in a CachingClient.
+func Cache(provider, model string, opts CacheOptions, embedder EmbeddingClient) Middleware {
+	return func(next LLMClient) LLMClient {
+		return NewCachingClient(next, provider, model, opts, embedder)
+	}
+}
+
+func promptChars(messages []Message) int {
+	n := 0
+	for _, m := range messages {
+		n += len(m.Content)
+	}
+	return n
+}
@@ -0,0 +1,33 @@
+// Package rag holds the types shared by ingestion, storage and retrieval.
+package rag
+
+// Metadata keys recorded on ingested chunks. Loaders may add others, such as
+// custom front-matter fields.
+const (
+	MetaPath       = "path"       // source file path
+	MetaMTime      = "mtime"      // source modification time, RFC 3339
+	MetaHash       = "hash"       // SHA-256 of the source content
+	MetaCollection = "collection" // collection the document was ingested into
+	MetaType       = "type"       // file type, e.g. "md" or "go"
+	MetaTitle      = "title"      // human-readable document title
+	MetaTags       = "tags"       // comma-separated tags, e.g. from Markdown front matter
+	MetaLanguage   = "language"   // programming language of source files, or as set in front matter
+	MetaRepo       = "repo"       // name of the git repository the file belongs to
+	MetaCommit     = "commit"     // commit checked out when the file was ingested
+	MetaBranch     = "branch"     // branch checked out when the file was ingested
+	MetaRelPath    = "rel_path"   // path relative to the repository root, slash-separated
+	MetaURL        = "url"        // address a web page was fetched from, after canonicalization
+	MetaPage       = "page"       // page number within a PDF, starting at 1
+	MetaChapter    = "chapter"    // chapter title within an EPUB book
+	MetaStartLine  = "start_line" // first source line of the chunk, when known
+	MetaEndLine    = "end_line"   // last source line of the chunk, when known
+)
+
+// Chunk is a piece of a document that is embedded and retrieved as a unit.
+type Chunk struct {
+	ID       string            `json:"id"`     // unique chunk id, DocID + "#" + position
+	DocID    string            `json:"doc_id"` // identifies the source document
+	Text     string            `json:"text"`
+	Vector   []float32         `json:"vector,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
@@ -0,0 +1,170 @@
+// Package redact replaces secrets and personal data with reversible
+// placeholders before text leaves the machine.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Rule detects one kind of sensitive value.
+type Rule struct {
+	Name    string         // placeholder prefix, e.g. "EMAIL"
+	Pattern *regexp.Regexp // matches the sensitive value
+	// Validate optionally rejects false positives (e.g. a Luhn check for card numbers).
+	Validate func(match string) bool
+}
+
+// builtinRules are ordered so that the most specific patterns run first;
+// a value replaced by an earlier rule is never seen by a later one.
+var builtinRules = []Rule{
+	{Name: "PRIVATE_KEY", Pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{Name: "AWS_KEY", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{Name: "AWS_SECRET", Pattern: regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}`)},
+	{Name: "GITHUB_TOKEN", Pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{Name: "OPENAI_KEY", Pattern: regexp.MustCompile(`\bsk-(?:proj-|svcacct-|admin-)?[A-Za-z0-9_-]{20,}\b`)},
+	{Name: "EMAIL", Pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+	{Name: "CARD", Pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), Validate: luhn},
+	// Phone numbers start with + or an area code in parentheses, or else separate
+	// their groups, so that timestamps and other runs of digits are left alone.
+	// The digits around the match are taken in too, for notPartOfNumber to reject
+	// IP addresses, version numbers and the first groups of longer numbers.
+	{Name: "PHONE", Pattern: regexp.MustCompile(`(?:\d\.)?(?:\+\d{1,3}[\s.-]?(?:\(\d{1,4}\)[\s.-]?|\d{1,4}[\s.-]?)?\d{3,4}[\s.-]?\d{3,4}|\(\d{2,4}\)[\s.-]?\d{3,4}[\s.-]?\d{3,4}|\b\d{2,4}[\s.-]\d{3,4}[\s.-]\d{3,4})\b(?:[\s.-]\d)?`), Validate: notPartOfNumber},
+}
+
+// numberEdge matches a match that continues a longer number: a dotted one
+// such as an IPv4 address, or one written in groups such as a card number.
+// A phone number itself never ends in a single digit after a separator.
+var numberEdge = regexp.MustCompile(`^\d\.\d|[\s.-]\d$`)
+
+// notPartOfNumber rejects phone number matches that are part of a longer
+// number, such as 192.168.100 in 192.168.100.1 or the first three groups of
+// 4111 1111 1111 1112.
+func notPartOfNumber(match string) bool {
+	return !numberEdge.MatchString(match)
+}
+
+// BuiltinRules returns the built-in rules whose names are listed, or all of
+// them when names is empty. Names are case-insensitive.
+func BuiltinRules(names []string) ([]Rule, error) {
+	if len(names) == 0 {
+		return append([]Rule(nil), builtinRules...), nil
+	}
+	var rules []Rule
+	for _, name := range names {
+		found := false
+		for _, r := range builtinRules {
+			if strings.EqualFold(r.Name, name) {
+				rules = append(rules, r)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown redaction rule %q", name)
+		}
+	}
+	return rules, nil
+}
+
+// CustomRule compiles a user-supplied pattern into a Rule.
+func CustomRule(name, pattern string) (Rule, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Rule{}, fmt.Errorf("redaction rule %s: %w", name, err)
+	}
+	return Rule{Name: strings.ToUpper(name), Pattern: re}, nil
+}
+
+// Redactor replaces matches with placeholders such as [EMAIL_1] and
+// remembers the originals so they can be restored. The same value always
+// maps to the same placeholder for the lifetime of the Redactor, which keeps
+// the conversation consistent across requests. It is safe for concurrent use.
+type Redactor struct {
+	rules []Rule
+
+	mu            sync.Mutex
+	byValue       map[string]string // original -> placeholder
+	byPlaceholder map[string]string // placeholder -> original
+	counts        map[string]int
+}
+
+// New creates a Redactor that applies rules in order.
+func New(rules []Rule) *Redactor {
+	return &Redactor{
+		rules:         rules,
+		byValue:       make(map[string]string),
+		byPlaceholder: make(map[string]string),
+		counts:        make(map[string]int),
+	}
+}
+
+// Redact returns text with every sensitive value replaced by its placeholder,
+// and the number of replacements made.
+func (r *Redactor) Redact(text string) (string, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	replaced := 0
+	for _, rule := range r.rules {
+		text = rule.Pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if rule.Validate != nil && !rule.Validate(match) {
+				return match
+			}
+			replaced++
+			return r.placeholder(rule.Name, match)
+		})
+	}
+	return text, replaced
+}
+
+// placeholder returns the placeholder for value, allocating one if needed.
+// Callers hold r.mu.
+func (r *Redactor) placeholder(name, value string) string {
+	if p, ok := r.byValue[value]; ok {
+		return p
+	}
+	r.counts[name]++
+	p := fmt.Sprintf("[%s_%d]", name, r.counts[name])
+	r.byValue[value] = p
+	r.byPlaceholder[p] = value
+	return p
+}
+
+// Restore replaces every known placeholder in text with its original value.
+func (r *Redactor) Restore(text string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.byPlaceholder) == 0 || !strings.Contains(text, "[") {
+		return text
+	}
+
+	pairs := make([]string, 0, 2*len(r.byPlaceholder))
+	for p, original := range r.byPlaceholder {
+		pairs = append(pairs, p, original)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// luhn reports whether the digits in s pass the Luhn checksum.
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
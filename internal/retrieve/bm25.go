@@ -0,0 +1,184 @@
+package retrieve
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+
+	"go-groq/internal/rag"
+	"go-groq/internal/vectorstore"
+)
+
+// BM25 parameters: term frequency saturation and length normalization.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// BM25 is an in-memory inverted index that ranks chunks by keyword relevance.
+// It indexes the chunk text and its path. It implements the same Upsert and
+// DeleteDoc methods as a VectorStore, so ingestion can write to both.
+type BM25 struct {
+	mu       sync.RWMutex
+	docs     map[string]bm25Doc        // chunk ID -> chunk
+	byDoc    map[string][]string       // doc ID -> chunk IDs
+	postings map[string]map[string]int // term -> chunk ID -> term frequency
+	totalLen int                       // sum of chunk lengths in terms
+}
+
+type bm25Doc struct {
+	chunk rag.Chunk
+	terms map[string]int
+	len   int
+}
+
+// NewBM25 creates an empty keyword index.
+func NewBM25() *BM25 {
+	return &BM25{
+		docs:     make(map[string]bm25Doc),
+		byDoc:    make(map[string][]string),
+		postings: make(map[string]map[string]int),
+	}
+}
+
+// Upsert adds chunks, replacing any chunk with the same ID.
+func (b *BM25) Upsert(ctx context.Context, chunks []rag.Chunk) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, c := range chunks {
+		if doc, ok := b.docs[c.ID]; ok {
+			if old := doc.chunk.DocID; old != c.DocID {
+				// The chunk moved to another document
+				b.byDoc[old] = slices.DeleteFunc(b.byDoc[old], func(id string) bool { return id == c.ID })
+				if len(b.byDoc[old]) == 0 {
+					delete(b.byDoc, old)
+				}
+				b.byDoc[c.DocID] = append(b.byDoc[c.DocID], c.ID)
+			}
+			b.remove(c.ID)
+		} else {
+			b.byDoc[c.DocID] = append(b.byDoc[c.DocID], c.ID)
+		}
+		terms := Tokenize(c.Text + "\n" + c.Metadata[rag.MetaPath])
+		freq := make(map[string]int, len(terms))
+		for _, t := range terms {
+			freq[t]++
+		}
+		for t, n := range freq {
+			p := b.postings[t]
+			if p == nil {
+				p = make(map[string]int)
+				b.postings[t] = p
+			}
+			p[c.ID] = n
+		}
+		b.docs[c.ID] = bm25Doc{chunk: c, terms: freq, len: len(terms)}
+		b.totalLen += len(terms)
+	}
+	return nil
+}
+
+// DeleteDoc removes every chunk of a document.
+func (b *BM25) DeleteDoc(ctx context.Context, docID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, id := range b.byDoc[docID] {
+		b.remove(id)
+	}
+	delete(b.byDoc, docID)
+	return nil
+}
+
+// remove drops a chunk from the postings. Callers hold b.mu.
+func (b *BM25) remove(id string) {
+	doc, ok := b.docs[id]
+	if !ok {
+		return
+	}
+	for t := range doc.terms {
+		delete(b.postings[t], id)
+		if len(b.postings[t]) == 0 {
+			delete(b.postings, t)
+		}
+	}
+	b.totalLen -= doc.len
+	delete(b.docs, id)
+}
+
+// Count returns the number of chunks indexed.
+func (b *BM25) Count() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.docs)
+}
+
+// HasMetadataKey reports whether any chunk carries the metadata key, such as
+// a front-matter field.
+func (b *BM25) HasMetadataKey(key string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, doc := range b.docs {
+		if _, ok := doc.chunk.Metadata[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Search returns the K chunks that best match the terms of query and pass
+// the filter, best first.
+func (b *BM25) Search(ctx context.Context, query string, opts vectorstore.SearchOptions) ([]vectorstore.Result, error) {
+	if opts.K <= 0 {
+		return nil, nil
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.docs) == 0 {
+		return nil, nil
+	}
+
+	n := float64(len(b.docs))
+	avgLen := float64(b.totalLen) / n
+	scores := make(map[string]float64)
+	seen := make(map[string]bool)
+	for _, t := range Tokenize(query) {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		p := b.postings[t]
+		if len(p) == 0 {
+			continue
+		}
+		df := float64(len(p))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, tf := range p {
+			norm := 1 - bm25B + bm25B*float64(b.docs[id].len)/avgLen
+			scores[id] += idf * float64(tf) * (bm25K1 + 1) / (float64(tf) + bm25K1*norm)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]vectorstore.Result, 0, len(scores))
+	for id, s := range scores {
+		c := b.docs[id].chunk
+		if opts.Filter != nil && !opts.Filter(c.Metadata) {
+			continue
+		}
+		results = append(results, vectorstore.Result{Chunk: c, Score: s})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Chunk.ID < results[j].Chunk.ID
+	})
+	if len(results) > opts.K {
+		results = results[:opts.K]
+	}
+	return results, nil
+}
@@ -0,0 +1,198 @@
+package retrieve
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go-groq/internal/llm"
+)
+
+// listMarker matches the bullet or number a model may put before each paraphrase.
+var listMarker = regexp.MustCompile(`^\s*(?:[-*•]|\d+[.)])\s+`)
+
+// Expansion selects how a question is turned into searches.
+type Expansion int
+
+const (
+	ExpandNone       Expansion = iota // search with the question as it is
+	ExpandMultiQuery                  // also search with LLM-written paraphrases and fuse the results
+	ExpandHyDE                        // embed an LLM-written hypothetical answer instead of the question
+)
+
+// ParseExpansion parses "none", "multi-query" or "hyde".
+func ParseExpansion(s string) (Expansion, error) {
+	switch strings.ToLower(s) {
+	case "", "none", "off":
+		return ExpandNone, nil
+	case "multi-query", "multiquery", "multi":
+		return ExpandMultiQuery, nil
+	case "hyde":
+		return ExpandHyDE, nil
+	default:
+		return ExpandNone, fmt.Errorf("unknown retrieval expansion %q (supported: none, multi-query, hyde)", s)
+	}
+}
+
+func (e Expansion) String() string {
+	switch e {
+	case ExpandMultiQuery:
+		return "multi-query"
+	case ExpandHyDE:
+		return "hyde"
+	default:
+		return "none"
+	}
+}
+
+const multiQueryPrompt = `You help a search engine over documents and code find everything relevant to a question.
+Write %d alternative search queries for the question: paraphrases that use different wording, synonyms
+or more specific technical terms. Keep identifiers and error codes exactly as written.
+Reply with one query per line and nothing else.`
+
+const hydePrompt = `Write a short passage, as it might appear in internal documentation or code comments,
+that answers the question below. It is used only to find similar real passages, so be specific and use
+the vocabulary such a document would use. If you do not know the answer, write a plausible one.
+Reply with the passage only.`
+
+// Expanding runs a Hybrid retriever on expanded versions of the query. The
+// extra LLM calls are labelled "multi-query" and "hyde" so usage statistics
+// show their cost. If an LLM call fails, the query is searched unexpanded.
+type Expanding struct {
+	Base     *Hybrid
+	Client   llm.LLMClient
+	Mode     Expansion
+	Queries  int          // paraphrases for multi-query; 3 when zero
+	OnExpand func(string) // optional, called with each generated query or passage
+	OnError  func(error)  // optional, called when expansion fails and the plain query is used
+}
+
+// Retrieve implements Retriever.
+func (e *Expanding) Retrieve(ctx context.Context, query string, opts Options) ([]Result, error) {
+	switch e.Mode {
+	case ExpandMultiQuery:
+		return e.multiQuery(ctx, query, opts)
+	case ExpandHyDE:
+		if e.Base.Embedder == nil || e.Base.Mode == ModeKeyword {
+			break // nothing to embed the passage with
+		}
+		passage, err := e.Client.Generate(llm.WithCallLabel(ctx, "hyde"), []llm.Message{
+			{Role: "system", Content: hydePrompt},
+			{Role: "user", Content: query},
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			e.failed(fmt.Errorf("hyde: %w", err))
+			break
+		}
+		e.expanded(passage)
+		opts.VectorQuery = passage
+	}
+	return e.Base.Retrieve(ctx, query, opts)
+}
+
+// multiQuery searches with the query and its paraphrases and fuses the lists.
+func (e *Expanding) multiQuery(ctx context.Context, query string, opts Options) ([]Result, error) {
+	n := e.Queries
+	if n <= 0 {
+		n = 3
+	}
+	reply, err := e.Client.Generate(llm.WithCallLabel(ctx, "multi-query"), []llm.Message{
+		{Role: "system", Content: fmt.Sprintf(multiQueryPrompt, n)},
+		{Role: "user", Content: query},
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		e.failed(fmt.Errorf("multi-query: %w", err))
+		return e.Base.Retrieve(ctx, query, opts)
+	}
+
+	queries := []string{query}
+	seen := map[string]bool{strings.ToLower(query): true}
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSpace(listMarker.ReplaceAllString(line, ""))
+		line = strings.Trim(line, "\"'`")
+		if line == "" || seen[strings.ToLower(line)] || len(queries) > n {
+			continue
+		}
+		seen[strings.ToLower(line)] = true
+		queries = append(queries, line)
+		e.expanded(line)
+	}
+
+	lists := make([][]Result, 0, len(queries))
+	for _, q := range queries {
+		results, err := e.Base.Retrieve(ctx, q, opts)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, results)
+	}
+	return Fuse(lists, e.Base.RRFK, opts.K), nil
+}
+
+func (e *Expanding) expanded(s string) {
+	if e.OnExpand != nil {
+		e.OnExpand(s)
+	}
+}
+
+func (e *Expanding) failed(err error) {
+	if e.OnError != nil {
+		e.OnError(err)
+	}
+}
+
+// Fuse merges ranked lists with reciprocal rank fusion and returns the best k.
+// A chunk keeps the best vector and keyword ranks it had in any list.
+func Fuse(lists [][]Result, rrfK, k int) []Result {
+	if rrfK <= 0 {
+		rrfK = DefaultRRFK
+	}
+	fused := make(map[string]*Result)
+	for _, list := range lists {
+		for i, r := range list {
+			f, ok := fused[r.Chunk.ID]
+			if !ok {
+				f = &Result{Chunk: r.Chunk}
+				fused[r.Chunk.ID] = f
+			}
+			f.Score += 1 / float64(rrfK+i+1)
+			f.VectorRank = bestRank(f.VectorRank, r.VectorRank)
+			f.KeywordRank = bestRank(f.KeywordRank, r.KeywordRank)
+		}
+	}
+	results := make([]Result, 0, len(fused))
+	for _, r := range fused {
+		results = append(results, *r)
+	}
+	sortResults(results)
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+// bestRank returns the better of two ranks, where 0 means unranked.
+func bestRank(a, b int) int {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// sortResults orders results by score, best first, breaking ties by chunk ID.
+func sortResults(results []Result) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Chunk.ID < results[j].Chunk.ID
+	})
+}
@@ -0,0 +1,389 @@
+package retrieve
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"go-groq/internal/rag"
+	"go-groq/internal/vectorstore"
+)
+
+// Condition is one term of a filter expression, such as path:internal/llm/*
+// or modified>2026-01-01.
+type Condition struct {
+	Key    string   // metadata key, with aliases resolved
+	Op     string   // ":", ">", ">=", "<" or "<="
+	Values []string // alternatives for ":", any of which may match
+	Negate bool     // written with a leading "-"
+}
+
+// FilterExpr is a parsed filter expression. A chunk matches when it matches
+// every condition.
+type FilterExpr []Condition
+
+// filterAliases maps the names accepted in filter expressions to metadata keys.
+var filterAliases = map[string]string{
+	"file":     rag.MetaPath,
+	"dir":      rag.MetaPath,
+	"ext":      rag.MetaType,
+	"modified": rag.MetaMTime,
+	"tag":      rag.MetaTags,
+	"lang":     rag.MetaLanguage,
+}
+
+// ParseFilter parses a space-separated filter expression:
+//
+//	path:internal/llm/* type:go,md -tag:draft modified>2026-01-01
+//
+// key:value matches a metadata value case-insensitively; commas separate
+// alternatives and *, ? and [ ] are glob patterns. Paths also match by
+// trailing directory components, so docs/ matches every file under a docs
+// directory. >, >=, < and <= compare dates (2026-01-01, RFC 3339, or an age
+// such as 30d or 12h), numbers, or otherwise text. A leading "-" negates a
+// term, and values with spaces can be quoted. Terms may carry the "@" of the
+// inline syntax.
+func ParseFilter(s string) (FilterExpr, error) {
+	var expr FilterExpr
+	for i := 0; i < len(s); {
+		if unicode.IsSpace(rune(s[i])) {
+			i++
+			continue
+		}
+		start := i
+		if s[i] == '@' {
+			i++
+		}
+		c, n, err := parseCondition(s[i:])
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			end := strings.IndexFunc(s[start:], unicode.IsSpace)
+			if end < 0 {
+				end = len(s) - start
+			}
+			return nil, fmt.Errorf("invalid filter %q (expected key:value, e.g. path:docs/ or modified>2026-01-01)", s[start:start+end])
+		}
+		expr = append(expr, c)
+		i += n
+	}
+	return expr, nil
+}
+
+// ExtractFilters removes the inline filters from text and returns the rest
+// along with them. An inline filter is a filter term prefixed with "@", such
+// as @path:docs/, on a metadata key the pipeline sets or, when known reports
+// it, a key found in the index such as a front-matter field. Other words
+// starting with "@", such as a mention followed by a colon, are left alone.
+// known may be nil.
+func ExtractFilters(text string, known func(key string) bool) (string, FilterExpr, error) {
+	var (
+		expr FilterExpr
+		rest strings.Builder
+	)
+	for i := 0; i < len(text); {
+		if text[i] == '@' && (i == 0 || unicode.IsSpace(rune(text[i-1]))) && inlineTerm(text[i+1:], known) {
+			c, n, err := parseCondition(text[i+1:])
+			if err != nil {
+				return "", nil, err
+			}
+			if n > 0 {
+				expr = append(expr, c)
+				i += 1 + n
+				continue
+			}
+		}
+		rest.WriteByte(text[i])
+		i++
+	}
+	return strings.Join(strings.Fields(rest.String()), " "), expr, nil
+}
+
+// metadataKeys are the keys the ingestion pipeline sets, which inline
+// filters may always use.
+var metadataKeys = map[string]bool{
+	rag.MetaPath: true, rag.MetaMTime: true, rag.MetaHash: true, rag.MetaCollection: true,
+	rag.MetaType: true, rag.MetaTitle: true, rag.MetaTags: true, rag.MetaLanguage: true,
+	rag.MetaRepo: true, rag.MetaCommit: true, rag.MetaBranch: true, rag.MetaRelPath: true,
+	rag.MetaURL: true, rag.MetaPage: true, rag.MetaChapter: true,
+	rag.MetaStartLine: true, rag.MetaEndLine: true,
+}
+
+// inlineTerm reports whether s, the text after an "@", starts with a term
+// on a known key that has a value.
+func inlineTerm(s string, known func(string) bool) bool {
+	s = strings.TrimPrefix(s, "-")
+	i := 0
+	for i < len(s) && isKeyByte(s[i]) {
+		i++
+	}
+	if i == 0 || i == len(s) || !strings.ContainsRune(":=<>", rune(s[i])) {
+		return false
+	}
+	key := strings.ToLower(s[:i])
+	i++
+	if i < len(s) && s[i] == '=' && s[i-1] != ':' && s[i-1] != '=' {
+		i++ // >= or <=
+	}
+	if i == len(s) || unicode.IsSpace(rune(s[i])) {
+		return false
+	}
+	if alias, ok := filterAliases[key]; ok {
+		key = alias
+	}
+	return metadataKeys[key] || (known != nil && known(key))
+}
+
+// parseCondition parses the term at the start of s and returns it with the
+// number of bytes it used. It returns 0 bytes, and no error, when s does
+// not start with something shaped like a term.
+func parseCondition(s string) (Condition, int, error) {
+	var c Condition
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		c.Negate = true
+		i++
+	}
+	keyStart := i
+	for i < len(s) && isKeyByte(s[i]) {
+		i++
+	}
+	if i == keyStart || i == len(s) {
+		return c, 0, nil
+	}
+	key := strings.ToLower(s[keyStart:i])
+
+	switch {
+	case strings.HasPrefix(s[i:], ">="), strings.HasPrefix(s[i:], "<="):
+		c.Op = s[i : i+2]
+	case s[i] == '>' || s[i] == '<' || s[i] == ':':
+		c.Op = s[i : i+1]
+	case s[i] == '=':
+		c.Op = ":"
+	default:
+		return c, 0, nil
+	}
+	i += len(c.Op)
+
+	var value string
+	if i < len(s) && s[i] == '"' {
+		end := strings.IndexByte(s[i+1:], '"')
+		if end < 0 {
+			return c, 0, fmt.Errorf("unterminated quote in filter %q", s)
+		}
+		value = s[i+1 : i+1+end]
+		i += end + 2
+	} else {
+		start := i
+		for i < len(s) && !unicode.IsSpace(rune(s[i])) {
+			i++
+		}
+		value = s[start:i]
+	}
+	if value == "" {
+		return c, 0, fmt.Errorf("filter %s%s needs a value", key, c.Op)
+	}
+
+	if alias, ok := filterAliases[key]; ok {
+		key = alias
+	}
+	c.Key = key
+	if c.Op == ":" {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				c.Values = append(c.Values, v)
+			}
+		}
+	} else {
+		c.Values = []string{value}
+		if key == rag.MetaMTime {
+			if _, err := parseFilterTime(value, time.Now()); err != nil {
+				return c, 0, fmt.Errorf("filter %s%s%s: %v", key, c.Op, value, err)
+			}
+		}
+	}
+	return c, i, nil
+}
+
+func isKeyByte(b byte) bool {
+	return b == '_' || b == '.' || b == '-' || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') || ('0' <= b && b <= '9')
+}
+
+// Filter returns the expression as a vector store filter, or nil when it is empty.
+func (f FilterExpr) Filter() vectorstore.Filter {
+	if len(f) == 0 {
+		return nil
+	}
+	now := time.Now()
+	return func(meta map[string]string) bool {
+		return f.match(meta, now)
+	}
+}
+
+// Match reports whether a chunk with the given metadata matches every condition.
+func (f FilterExpr) Match(meta map[string]string) bool {
+	return f.match(meta, time.Now())
+}
+
+func (f FilterExpr) match(meta map[string]string, now time.Time) bool {
+	for _, c := range f {
+		if c.match(meta, now) == c.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the expression so that ParseFilter reads it back.
+func (f FilterExpr) String() string {
+	terms := make([]string, len(f))
+	for i, c := range f {
+		value := strings.Join(c.Values, ",")
+		if strings.ContainsFunc(value, unicode.IsSpace) {
+			value = strconv.Quote(value)
+		}
+		neg := ""
+		if c.Negate {
+			neg = "-"
+		}
+		terms[i] = neg + c.Key + c.Op + value
+	}
+	return strings.Join(terms, " ")
+}
+
+func (c Condition) match(meta map[string]string, now time.Time) bool {
+	got, ok := meta[c.Key]
+	if !ok || got == "" {
+		return false
+	}
+	if c.Op != ":" {
+		return compareValues(c.Key, got, c.Values[0], now, c.Op)
+	}
+	for _, want := range c.Values {
+		switch c.Key {
+		case rag.MetaPath:
+			if matchPath(got, want) {
+				return true
+			}
+		case rag.MetaTags:
+			for _, tag := range strings.Split(got, ",") {
+				if matchValue(strings.TrimSpace(tag), want) {
+					return true
+				}
+			}
+		default:
+			if matchValue(got, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchValue compares case-insensitively, treating want as a glob pattern
+// when it contains one.
+func matchValue(got, want string) bool {
+	got, want = strings.ToLower(got), strings.ToLower(want)
+	if strings.ContainsAny(want, "*?[") {
+		ok, _ := path.Match(want, got)
+		return ok
+	}
+	return got == want
+}
+
+// matchPath reports whether pattern matches the file path p or a trailing
+// part of it that starts at a directory boundary. A pattern without glob
+// characters also matches everything below the directory it names.
+func matchPath(p, pattern string) bool {
+	p = strings.TrimPrefix(strings.ReplaceAll(p, "\\", "/"), "./")
+	pattern = strings.TrimPrefix(pattern, "./")
+	glob := strings.ContainsAny(pattern, "*?[")
+	dir := strings.TrimSuffix(pattern, "/")
+	for tail := p; ; {
+		switch {
+		case glob:
+			if ok, _ := path.Match(pattern, tail); ok {
+				return true
+			}
+		case tail == dir || strings.HasPrefix(tail, dir+"/"):
+			return true
+		}
+		i := strings.IndexByte(tail, '/')
+		if i < 0 {
+			return false
+		}
+		tail = tail[i+1:]
+	}
+}
+
+// compareValues applies a comparison operator to a metadata value, as dates
+// for the modification time and as numbers or text otherwise.
+func compareValues(key, got, want string, now time.Time, op string) bool {
+	var cmp int
+	if key == rag.MetaMTime {
+		g, err := time.Parse(time.RFC3339, got)
+		if err != nil {
+			return false
+		}
+		w, err := parseFilterTime(want, now)
+		if err != nil {
+			return false
+		}
+		cmp = g.Compare(w)
+	} else if g, err := strconv.ParseFloat(got, 64); err == nil {
+		w, err := strconv.ParseFloat(want, 64)
+		if err != nil {
+			return false
+		}
+		cmp = compareFloat(g, w)
+	} else {
+		cmp = strings.Compare(strings.ToLower(got), strings.ToLower(want))
+	}
+	switch op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	default:
+		return cmp <= 0
+	}
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// parseFilterTime parses a date, an RFC 3339 time, or an age such as 30d,
+// 2w or 12h counted back from now.
+func parseFilterTime(s string, now time.Time) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", time.RFC3339, "2006-01-02T15:04", "2006-01"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	if n := len(s); n > 1 {
+		if count, err := strconv.Atoi(s[:n-1]); err == nil && count >= 0 {
+			switch s[n-1] {
+			case 'd':
+				return now.AddDate(0, 0, -count), nil
+			case 'w':
+				return now.AddDate(0, 0, -7*count), nil
+			case 'h':
+				return now.Add(-time.Duration(count) * time.Hour), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q (use 2026-01-01 or an age like 30d)", s)
+}
@@ -0,0 +1,129 @@
+package retrieve
+
+import (
+	"testing"
+	"time"
+
+	"go-groq/internal/rag"
+)
+
+func TestExtractFilters(t *testing.T) {
+	known := func(key string) bool { return key == "author" }
+	tests := []struct {
+		in, text, filter string
+	}{
+		{"how does retry work @path:internal/llm/", "how does retry work", "path:internal/llm/"},
+		{"@type:go,md where is the cache", "where is the cache", "type:go,md"},
+		{"@-tag:draft release notes", "release notes", "-tags:draft"},
+		{"@lang:go @ext:md errors", "errors", "language:go type:md"},
+		{"changes @modified>2026-01-01", "changes", "mtime>2026-01-01"},
+		{"changes @modified>=30d", "changes", "mtime>=30d"},
+		{"@page<=3 intro", "intro", "page<=3"},
+		{`@path:"my docs" setup`, "setup", `path:"my docs"`},
+		{"@author:alice design", "design", "author:alice"},
+		{"@type=go tests", "tests", "type:go"},
+
+		// Not filters: mentions, unknown keys, missing values, mid-word @
+		{"@john: why does this fail?", "@john: why does this fail?", ""},
+		{"@john:why does this fail?", "@john:why does this fail?", ""},
+		{"@path: is empty", "@path: is empty", ""},
+		{"@path:", "@path:", ""},
+		{"mail me@path:docs", "mail me@path:docs", ""},
+		{"@ alone", "@ alone", ""},
+		{"ask @alice about it", "ask @alice about it", ""},
+		{"2 @ 3>1", "2 @ 3>1", ""},
+	}
+	for _, tt := range tests {
+		text, expr, err := ExtractFilters(tt.in, known)
+		if err != nil {
+			t.Errorf("ExtractFilters(%q): %v", tt.in, err)
+			continue
+		}
+		if text != tt.text || expr.String() != tt.filter {
+			t.Errorf("ExtractFilters(%q) = %q, %q; want %q, %q", tt.in, text, expr.String(), tt.text, tt.filter)
+		}
+	}
+}
+
+func TestExtractFiltersErrors(t *testing.T) {
+	for _, in := range []string{
+		"@modified>yesterday changes",
+		`@path:"docs setup`,
+	} {
+		if _, _, err := ExtractFilters(in, nil); err == nil {
+			t.Errorf("ExtractFilters(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"path:internal/llm/* type:go,md -tag:draft modified>2026-01-01", "path:internal/llm/* type:go,md -tags:draft mtime>2026-01-01"},
+		{"@path:docs/ author:alice", "path:docs/ author:alice"},
+		{`  title:"Getting started"  `, `title:"Getting started"`},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		expr, err := ParseFilter(tt.in)
+		if err != nil {
+			t.Errorf("ParseFilter(%q): %v", tt.in, err)
+			continue
+		}
+		if got := expr.String(); got != tt.want {
+			t.Errorf("ParseFilter(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if again, err := ParseFilter(expr.String()); err != nil || again.String() != tt.want {
+			t.Errorf("ParseFilter(%q) does not read back: %q, %v", expr.String(), again.String(), err)
+		}
+	}
+	for _, in := range []string{"docs", "path:", "modified>soon", "path:docs oops"} {
+		if _, err := ParseFilter(in); err == nil {
+			t.Errorf("ParseFilter(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	now := time.Now()
+	meta := map[string]string{
+		rag.MetaPath:  "repo/internal/llm/cache.go",
+		rag.MetaType:  "go",
+		rag.MetaTags:  "design, Draft",
+		rag.MetaMTime: now.AddDate(0, 0, -10).UTC().Format(time.RFC3339),
+		rag.MetaPage:  "12",
+	}
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{"path:internal/llm/", true},
+		{"path:llm/cache.go", true},
+		{"path:internal/ll", false},
+		{"path:*/cache.go", true},
+		{"path:repo/*.go", false},
+		{"type:md,GO", true},
+		{"-type:go", false},
+		{"tag:draft", true},
+		{"tag:dra", false},
+		{"tag:d*", true},
+		{"modified>30d", true},
+		{"modified>7d", false},
+		{"modified<2000-01-01", false},
+		{"page>9", true}, // numbers, not text: "12" < "9"
+		{"page<=11", false},
+		{"title:anything", false},
+		{"-title:anything", true},
+		{"path:internal/llm/ type:go -tag:archived", true},
+	}
+	for _, tt := range tests {
+		expr, err := ParseFilter(tt.filter)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q): %v", tt.filter, err)
+		}
+		if got := expr.Match(meta); got != tt.want {
+			t.Errorf("%q matches = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
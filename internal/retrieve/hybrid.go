@@ -0,0 +1,99 @@
+package retrieve
+
+import (
+	"context"
+	"fmt"
+
+	"go-groq/internal/llm"
+	"go-groq/internal/vectorstore"
+)
+
+// DefaultRRFK is the rank constant of reciprocal rank fusion. Larger values
+// flatten the difference between top and lower ranks.
+const DefaultRRFK = 60
+
+// Hybrid runs vector and keyword search and merges the two rankings with
+// weighted reciprocal rank fusion: a chunk scores the sum of
+// weight / (RRFK + rank) over the searches that returned it. Without an
+// embedder it falls back to keyword search alone.
+type Hybrid struct {
+	Store         vectorstore.VectorStore
+	Keywords      *BM25
+	Embedder      llm.EmbeddingClient // nil disables vector search
+	Mode          Mode
+	VectorWeight  float64 // zero counts as 1; use Mode to turn a search off
+	KeywordWeight float64 // zero counts as 1
+	RRFK          int     // zero means DefaultRRFK
+}
+
+// Retrieve implements Retriever.
+func (h *Hybrid) Retrieve(ctx context.Context, query string, opts Options) ([]Result, error) {
+	if opts.K <= 0 {
+		return nil, nil
+	}
+	// Each search contributes a deeper list than K so that fusion can
+	// promote chunks ranked moderately by both.
+	search := vectorstore.SearchOptions{K: max(3*opts.K, 20), Filter: opts.Filter}
+
+	var vectorHits, keywordHits []vectorstore.Result
+	if h.Mode != ModeKeyword && h.Embedder != nil && h.Store != nil {
+		text := query
+		if opts.VectorQuery != "" {
+			text = opts.VectorQuery
+		}
+		vectors, err := h.Embedder.Embed(ctx, []string{text})
+		if err != nil {
+			return nil, fmt.Errorf("embed query: %w", err)
+		}
+		if len(vectors) > 0 {
+			if vectorHits, err = h.Store.Search(ctx, vectors[0], search); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if h.Mode != ModeVector || h.Embedder == nil {
+		if h.Keywords != nil {
+			var err error
+			if keywordHits, err = h.Keywords.Search(ctx, query, search); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	rrfK := h.RRFK
+	if rrfK <= 0 {
+		rrfK = DefaultRRFK
+	}
+	fused := make(map[string]*Result)
+	add := func(hits []vectorstore.Result, weight float64, setRank func(*Result, int)) {
+		for i, hit := range hits {
+			r, ok := fused[hit.Chunk.ID]
+			if !ok {
+				r = &Result{Chunk: hit.Chunk}
+				fused[hit.Chunk.ID] = r
+			}
+			setRank(r, i+1)
+			r.Score += weight / float64(rrfK+i+1)
+		}
+	}
+	add(vectorHits, weightOr(h.VectorWeight), func(r *Result, rank int) { r.VectorRank = rank })
+	add(keywordHits, weightOr(h.KeywordWeight), func(r *Result, rank int) { r.KeywordRank = rank })
+
+	results := make([]Result, 0, len(fused))
+	for _, r := range fused {
+		results = append(results, *r)
+	}
+	sortResults(results)
+	if len(results) > opts.K {
+		results = results[:opts.K]
+	}
+	return results, nil
+}
+
+// weightOr returns w, or 1 when it is not set.
+func weightOr(w float64) float64 {
+	if w <= 0 {
+		return 1
+	}
+	return w
+}
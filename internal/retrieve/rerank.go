@@ -0,0 +1,331 @@
+package retrieve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-groq/internal/llm"
+)
+
+// RerankMode selects how first-stage candidates are reordered.
+type RerankMode int
+
+const (
+	RerankNone      RerankMode = iota // keep the first-stage order
+	RerankPointwise                   // an LLM scores each candidate on its own
+	RerankListwise                    // an LLM orders all candidates in one call
+	RerankEndpoint                    // a cross-encoder behind an OpenAI-compatible /rerank endpoint
+)
+
+// ParseRerankMode parses "none", "pointwise", "listwise" or "endpoint".
+func ParseRerankMode(s string) (RerankMode, error) {
+	switch strings.ToLower(s) {
+	case "", "none", "off":
+		return RerankNone, nil
+	case "pointwise":
+		return RerankPointwise, nil
+	case "listwise":
+		return RerankListwise, nil
+	case "endpoint", "cross-encoder":
+		return RerankEndpoint, nil
+	default:
+		return RerankNone, fmt.Errorf("unknown rerank mode %q (supported: none, pointwise, listwise, endpoint)", s)
+	}
+}
+
+func (m RerankMode) String() string {
+	switch m {
+	case RerankPointwise:
+		return "pointwise"
+	case RerankListwise:
+		return "listwise"
+	case RerankEndpoint:
+		return "endpoint"
+	default:
+		return "none"
+	}
+}
+
+// Reranker reorders candidates for a query, best first. Score is replaced by
+// the reranker's own relevance score and FirstRank records the position the
+// candidate had before.
+type Reranker interface {
+	Rerank(ctx context.Context, query string, candidates []Result) ([]Result, error)
+}
+
+// DefaultRerankCandidates is the number of first-stage results reranked when
+// RerankStage.Candidates is zero.
+const DefaultRerankCandidates = 20
+
+// RerankStage reranks the top Candidates first-stage results and keeps the
+// best k. Reranking that fails or runs over Budget is abandoned and the
+// first-stage order is used, so a slow reranker costs at most Budget.
+type RerankStage struct {
+	Reranker   Reranker
+	Candidates int           // first-stage results to rerank; DefaultRerankCandidates when zero
+	Budget     time.Duration // time allowed for reranking; unlimited when zero
+}
+
+// Depth returns how many first-stage results to retrieve so that k are left
+// after reranking.
+func (s *RerankStage) Depth(k int) int {
+	n := s.Candidates
+	if n <= 0 {
+		n = DefaultRerankCandidates
+	}
+	return max(n, k)
+}
+
+// Rerank returns the best k candidates for query. If reranking fails, the
+// first k candidates are returned together with the error, which callers can
+// report as a warning. Only cancellation of ctx itself returns no results.
+func (s *RerankStage) Rerank(ctx context.Context, query string, candidates []Result, k int) ([]Result, error) {
+	first := candidates[:min(k, len(candidates))]
+	if len(candidates) < 2 {
+		return first, nil
+	}
+
+	rctx := ctx
+	if s.Budget > 0 {
+		var cancel context.CancelFunc
+		rctx, cancel = context.WithTimeout(ctx, s.Budget)
+		defer cancel()
+	}
+	reranked, err := s.Reranker.Rerank(rctx, query, candidates)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if errors.Is(rctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("exceeded the %s budget", s.Budget)
+		}
+		return first, err
+	}
+	if len(reranked) > k {
+		reranked = reranked[:k]
+	}
+	return reranked, nil
+}
+
+// rankBy orders candidates by scores, best first, keeping the first-stage
+// order among equal scores.
+func rankBy(candidates []Result, scores []float64) []Result {
+	results := make([]Result, len(candidates))
+	for i, r := range candidates {
+		r.FirstRank = i + 1
+		r.Score = scores[i]
+		results[i] = r
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	return results
+}
+
+const pointwisePrompt = `You judge search results for a document and code search engine.
+Rate how useful the passage is for answering the query, from 0 (unrelated) to 10 (answers it directly).
+Reply with the number only.`
+
+// PointwiseReranker asks an LLM to score each candidate separately. Calls are
+// labelled "rerank" and run Concurrency at a time.
+type PointwiseReranker struct {
+	Client      llm.LLMClient
+	Concurrency int // parallel calls; 4 when zero
+}
+
+// Rerank implements Reranker.
+func (p *PointwiseReranker) Rerank(ctx context.Context, query string, candidates []Result) ([]Result, error) {
+	workers := p.Concurrency
+	if workers <= 0 {
+		workers = 4
+	}
+	ctx, cancel := context.WithCancel(llm.WithCallLabel(ctx, "rerank"))
+	defer cancel()
+
+	scores := make([]float64, len(candidates))
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		sem      = make(chan struct{}, workers)
+	)
+	for i, c := range candidates {
+		wg.Add(1)
+		go func(i int, c Result) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				return
+			}
+			// Each call reports its cache status privately: the caller's status
+			// belongs to the chat answer, and the calls run concurrently
+			callCtx := llm.WithCacheStatus(ctx, new(llm.CacheStatus))
+			reply, err := p.Client.Generate(callCtx, []llm.Message{
+				{Role: "system", Content: pointwisePrompt},
+				{Role: "user", Content: fmt.Sprintf("Query: %s\n\nPassage:\n%s\n\nScore:", query, truncate(c.Chunk.Text, 1500))},
+			})
+			if err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("pointwise rerank: %w", err)
+					cancel()
+				})
+				return
+			}
+			scores[i] = parseScore(reply)
+		}(i, c)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return rankBy(candidates, scores), nil
+}
+
+var numberPattern = regexp.MustCompile(`\d+(?:\.\d+)?`)
+
+// parseScore reads the first number in reply, clamped to 0-10. A reply
+// without one scores 0.
+func parseScore(reply string) float64 {
+	f, err := strconv.ParseFloat(numberPattern.FindString(reply), 64)
+	if err != nil {
+		return 0
+	}
+	return math.Min(f, 10)
+}
+
+const listwisePrompt = `You judge search results for a document and code search engine.
+Order the numbered passages by how useful they are for answering the query, most useful first.
+Reply with the passage numbers only, separated by commas, for example: 3, 1, 2`
+
+// ListwiseReranker asks an LLM to order all candidates in a single call,
+// labelled "rerank". Passages are shortened to keep the prompt small;
+// candidates the reply leaves out keep their order after the ranked ones.
+type ListwiseReranker struct {
+	Client llm.LLMClient
+}
+
+// Rerank implements Reranker.
+func (l *ListwiseReranker) Rerank(ctx context.Context, query string, candidates []Result) ([]Result, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Query: %s\n\nPassages:\n", query)
+	for i, c := range candidates {
+		fmt.Fprintf(&b, "[%d] %s\n", i+1, truncate(c.Chunk.Text, 400))
+	}
+	b.WriteString("\nRanking:")
+
+	reply, err := l.Client.Generate(llm.WithCallLabel(ctx, "rerank"), []llm.Message{
+		{Role: "system", Content: listwisePrompt},
+		{Role: "user", Content: b.String()},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listwise rerank: %w", err)
+	}
+
+	n := len(candidates)
+	scores := make([]float64, n) // 0 for passages the reply leaves out
+	pos := 0
+	for _, s := range numberPattern.FindAllString(reply, -1) {
+		i, err := strconv.Atoi(s)
+		if err != nil || i < 1 || i > n || scores[i-1] != 0 {
+			continue
+		}
+		scores[i-1] = float64(n-pos) / float64(n)
+		pos++
+	}
+	if pos == 0 {
+		return nil, fmt.Errorf("listwise rerank: no passage numbers in reply %q", truncate(reply, 80))
+	}
+	return rankBy(candidates, scores), nil
+}
+
+// EndpointReranker scores candidates with a cross-encoder served behind a
+// /rerank endpoint, as offered by vLLM, Infinity, Jina and Cohere: it posts
+// {model, query, documents} and reads results[].index and relevance_score.
+type EndpointReranker struct {
+	URL    string // full endpoint URL, e.g. http://localhost:8000/v1/rerank
+	APIKey string // sent as a bearer token when set
+	Model  string
+	Client *http.Client // http.DefaultClient when nil
+}
+
+type rerankRequest struct {
+	Model     string   `json:"model,omitempty"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+	TopN      int      `json:"top_n"`
+}
+
+type rerankResponse struct {
+	Results []struct {
+		Index          int     `json:"index"`
+		RelevanceScore float64 `json:"relevance_score"`
+	} `json:"results"`
+}
+
+// Rerank implements Reranker.
+func (e *EndpointReranker) Rerank(ctx context.Context, query string, candidates []Result) ([]Result, error) {
+	docs := make([]string, len(candidates))
+	for i, c := range candidates {
+		docs[i] = c.Chunk.Text
+	}
+	data, err := json.Marshal(rerankRequest{Model: e.Model, Query: query, Documents: docs, TopN: len(docs)})
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("call rerank endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &llm.APIError{Provider: "rerank endpoint", StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var rr rerankResponse
+	if err := json.Unmarshal(body, &rr); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+	scores := make([]float64, len(candidates))
+	for i := range scores {
+		scores[i] = math.Inf(-1) // not returned by the endpoint
+	}
+	for _, r := range rr.Results {
+		if r.Index < 0 || r.Index >= len(scores) {
+			return nil, fmt.Errorf("rerank endpoint returned invalid index %d", r.Index)
+		}
+		scores[r.Index] = r.RelevanceScore
+	}
+	return rankBy(candidates, scores), nil
+}
@@ -0,0 +1,69 @@
+// Package retrieve finds the chunks relevant to a question by combining
+// vector search with keyword search.
+package retrieve
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go-groq/internal/rag"
+	"go-groq/internal/vectorstore"
+)
+
+// Options controls a retrieval.
+type Options struct {
+	K           int                // number of chunks to return
+	Filter      vectorstore.Filter // optional metadata filter
+	VectorQuery string             // text to embed instead of the query, e.g. a HyDE passage
+}
+
+// Result is a retrieved chunk. Score is the fused score, or the reranker's
+// score after reranking; the ranks record where each search placed the
+// chunk, starting at 1, or 0 if it did not return it.
+type Result struct {
+	Chunk       rag.Chunk
+	Score       float64
+	VectorRank  int
+	KeywordRank int
+	FirstRank   int // position before reranking; 0 if not reranked
+}
+
+// Retriever returns the chunks most relevant to a query, best first.
+type Retriever interface {
+	Retrieve(ctx context.Context, query string, opts Options) ([]Result, error)
+}
+
+// Mode selects which searches a Hybrid retriever runs.
+type Mode int
+
+const (
+	ModeHybrid Mode = iota
+	ModeVector
+	ModeKeyword
+)
+
+// ParseMode parses "hybrid", "vector" or "keyword".
+func ParseMode(s string) (Mode, error) {
+	switch strings.ToLower(s) {
+	case "", "hybrid":
+		return ModeHybrid, nil
+	case "vector":
+		return ModeVector, nil
+	case "keyword", "bm25":
+		return ModeKeyword, nil
+	default:
+		return ModeHybrid, fmt.Errorf("unknown retrieval mode %q (supported: hybrid, vector, keyword)", s)
+	}
+}
+
+func (m Mode) String() string {
+	switch m {
+	case ModeVector:
+		return "vector"
+	case ModeKeyword:
+		return "keyword"
+	default:
+		return "hybrid"
+	}
+}
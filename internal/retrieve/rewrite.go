@@ -0,0 +1,80 @@
+package retrieve
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go-groq/internal/llm"
+)
+
+const rewritePrompt = `You turn follow-up questions into standalone search queries for a document and code search engine.
+Use the conversation to resolve pronouns and references like "it", "that function" or "the second one".
+Keep identifiers, file names and error codes exactly as written. Do not answer the question.
+If the question already stands on its own, repeat it unchanged.
+Reply with the search query only, on one line.`
+
+// Rewriter condenses a follow-up question and the recent conversation into a
+// standalone search query, so that "and how does it handle errors?" retrieves
+// chunks about whatever "it" was.
+type Rewriter struct {
+	Client   llm.LLMClient
+	MaxTurns int // conversation messages included; 6 when zero
+}
+
+// Rewrite returns the search query for question. history holds the earlier
+// conversation, oldest first, without question itself. Without history the
+// question is returned unchanged and no call is made.
+func (r *Rewriter) Rewrite(ctx context.Context, history []llm.Message, question string) (string, error) {
+	maxTurns := r.MaxTurns
+	if maxTurns <= 0 {
+		maxTurns = 6
+	}
+	var turns []llm.Message
+	for _, m := range history {
+		if m.Role == "user" || m.Role == "assistant" {
+			turns = append(turns, m)
+		}
+	}
+	if len(turns) == 0 {
+		return question, nil
+	}
+	turns = turns[max(len(turns)-maxTurns, 0):]
+
+	var b strings.Builder
+	b.WriteString("Conversation:\n")
+	for _, m := range turns {
+		role := "User"
+		if m.Role == "assistant" {
+			role = "Assistant"
+		}
+		fmt.Fprintf(&b, "%s: %s\n", role, truncate(m.Content, 600))
+	}
+	fmt.Fprintf(&b, "\nFollow-up question: %s\n\nStandalone search query:", question)
+
+	reply, err := r.Client.Generate(llm.WithCallLabel(ctx, "rewrite"), []llm.Message{
+		{Role: "system", Content: rewritePrompt},
+		{Role: "user", Content: b.String()},
+	})
+	if err != nil {
+		return question, err
+	}
+	query := strings.TrimSpace(reply)
+	if i := strings.IndexByte(query, '\n'); i >= 0 {
+		query = strings.TrimSpace(query[:i])
+	}
+	query = strings.Trim(query, "\"'`")
+	if query == "" {
+		return question, nil
+	}
+	return query, nil
+}
+
+// truncate shortens s to at most n runes, marking the cut.
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,65 @@
+package retrieve
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tokenize splits text into lowercase terms for keyword search. Identifiers
+// are kept whole and also split into their parts, so "SwitchModel" yields
+// "switchmodel", "switch" and "model", and "max_tokens" yields "max_tokens",
+// "max" and "tokens". Searching for an exact identifier therefore ranks
+// chunks containing it above chunks that only share its words.
+func Tokenize(text string) []string {
+	var terms []string
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		word := text[start:end]
+		start = -1
+		terms = append(terms, strings.ToLower(word))
+		if parts := splitIdentifier(word); len(parts) > 1 {
+			for _, p := range parts {
+				terms = append(terms, strings.ToLower(p))
+			}
+		}
+	}
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(text))
+	return terms
+}
+
+// splitIdentifier splits snake_case, camelCase and PascalCase words, and
+// breaks between letters and digits: "HTTPServer2" -> "HTTP", "Server", "2".
+func splitIdentifier(word string) []string {
+	var parts []string
+	for _, piece := range strings.Split(word, "_") {
+		runes := []rune(piece)
+		begin := 0
+		for i := 1; i < len(runes); i++ {
+			prev, cur := runes[i-1], runes[i]
+			boundary := unicode.IsLower(prev) && unicode.IsUpper(cur) ||
+				unicode.IsLetter(prev) != unicode.IsLetter(cur) ||
+				// The last capital of an acronym starts the next word: "HTTPServer".
+				unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if boundary {
+				parts = append(parts, string(runes[begin:i]))
+				begin = i
+			}
+		}
+		if begin < len(runes) {
+			parts = append(parts, string(runes[begin:]))
+		}
+	}
+	return parts
+}
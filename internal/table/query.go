@@ -0,0 +1,406 @@
+package table
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query is an aggregation over the rows of a table that match every
+// condition of Where.
+type Query struct {
+	Table   string      `json:"table"`
+	Op      string      `json:"op"`                 // count, sum, avg, min, max, distinct or rows
+	Column  string      `json:"column,omitempty"`   // the column aggregated; count counts non-empty values when set
+	Where   []Condition `json:"where,omitempty"`    // conditions on the rows, all of which must hold
+	GroupBy string      `json:"group_by,omitempty"` // aggregate per value of this column
+	Limit   int         `json:"limit,omitempty"`    // rows, values or groups returned; defaults to 20 rows or 50 values
+}
+
+// Condition compares a column with a value. Op is one of =, !=, <, <=, >, >=
+// and contains. Numbers and dates are compared as such, and text
+// case-insensitively; <, <=, > and >= never hold between a number or date
+// and a value of another kind.
+type Condition struct {
+	Column string `json:"column"`
+	Op     string `json:"op"`
+	Value  string `json:"value"`
+}
+
+// String describes q, as in "sum(minutes) where status = open by owner".
+func (q Query) String() string {
+	var b strings.Builder
+	b.WriteString(q.Op)
+	if q.Column != "" {
+		b.WriteString("(" + q.Column + ")")
+	}
+	for i, c := range q.Where {
+		if i == 0 {
+			b.WriteString(" where ")
+		} else {
+			b.WriteString(" and ")
+		}
+		fmt.Fprintf(&b, "%s %s %s", c.Column, c.Op, c.Value)
+	}
+	if q.GroupBy != "" {
+		b.WriteString(" by " + q.GroupBy)
+	}
+	return b.String()
+}
+
+// Result is the answer to a Query, as a small table.
+type Result struct {
+	Columns []string
+	Rows    [][]string
+	Matched int // rows that matched the conditions
+	Total   int // rows in the table
+	Ignored int // matched values that are not numbers, left out of sum, avg, min and max
+	More    int // rows left out by the limit
+}
+
+// String renders r as a Markdown table with a line of counts.
+func (r *Result) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d of %d rows matched", r.Matched, r.Total)
+	if r.Ignored > 0 {
+		fmt.Fprintf(&b, "; %d values that are not numbers were ignored", r.Ignored)
+	}
+	b.WriteString(".\n\n")
+	b.WriteString("| " + strings.Join(r.Columns, " | ") + " |\n")
+	b.WriteString(strings.Repeat("| --- ", len(r.Columns)) + "|\n")
+	for _, row := range r.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = strings.ReplaceAll(strings.ReplaceAll(v, "|", `\|`), "\n", " ")
+		}
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	if r.More > 0 {
+		fmt.Fprintf(&b, "\n%d more not shown.\n", r.More)
+	}
+	return b.String()
+}
+
+// Run answers q over t.
+func Run(t *Table, q Query) (*Result, error) {
+	op := strings.ToLower(strings.TrimSpace(q.Op))
+	col, group := -1, -1
+	if q.Column != "" {
+		if col = t.Column(q.Column); col < 0 {
+			return nil, unknownColumn(t, q.Column)
+		}
+	}
+	if q.GroupBy != "" {
+		if group = t.Column(q.GroupBy); group < 0 {
+			return nil, unknownColumn(t, q.GroupBy)
+		}
+	}
+	switch op {
+	case "count":
+	case "sum", "avg", "min", "max", "distinct":
+		if col < 0 {
+			return nil, fmt.Errorf("%s needs a column", op)
+		}
+		if op == "distinct" && group >= 0 {
+			return nil, fmt.Errorf("distinct cannot be grouped; use count with group_by")
+		}
+	case "rows":
+		if group >= 0 {
+			return nil, fmt.Errorf("rows cannot be grouped")
+		}
+	default:
+		return nil, fmt.Errorf("unknown op %q (expected count, sum, avg, min, max, distinct or rows)", q.Op)
+	}
+
+	type cond struct {
+		col int
+		Condition
+	}
+	conds := make([]cond, len(q.Where))
+	for i, c := range q.Where {
+		conds[i] = cond{col: t.Column(c.Column), Condition: c}
+		if conds[i].col < 0 {
+			return nil, unknownColumn(t, c.Column)
+		}
+		switch c.Op {
+		case "=", "==", "!=", "<", "<=", ">", ">=", "contains":
+		default:
+			return nil, fmt.Errorf("unknown condition op %q (expected =, !=, <, <=, >, >= or contains)", c.Op)
+		}
+	}
+	var matched []int
+rows:
+	for i := range t.Rows {
+		for _, c := range conds {
+			if !holds(t.Value(i, c.col), c.Op, c.Value) {
+				continue rows
+			}
+		}
+		matched = append(matched, i)
+	}
+	res := &Result{Matched: len(matched), Total: len(t.Rows)}
+
+	switch op {
+	case "rows":
+		res.Columns = t.Columns
+		if col >= 0 {
+			res.Columns = []string{t.Columns[col]}
+		}
+		limit := q.Limit
+		if limit <= 0 {
+			limit = 20
+		}
+		for n, i := range matched {
+			if n == limit {
+				res.More = len(matched) - limit
+				break
+			}
+			if col >= 0 {
+				res.Rows = append(res.Rows, []string{t.Value(i, col)})
+				continue
+			}
+			row := make([]string, len(t.Columns))
+			for c := range row {
+				row[c] = t.Value(i, c)
+			}
+			res.Rows = append(res.Rows, row)
+		}
+		return res, nil
+	case "distinct":
+		res.Columns = []string{t.Columns[col], "count"}
+		counts := make(map[string]int)
+		var order []string
+		for _, i := range matched {
+			v := t.Value(i, col)
+			if counts[v] == 0 {
+				order = append(order, v)
+			}
+			counts[v]++
+		}
+		sort.SliceStable(order, func(a, b int) bool { return counts[order[a]] > counts[order[b]] })
+		res.Rows, res.More = limitRows(order, q.Limit, func(v string) []string {
+			return []string{v, strconv.Itoa(counts[v])}
+		})
+		return res, nil
+	}
+
+	name := op
+	if col >= 0 {
+		name = fmt.Sprintf("%s(%s)", op, t.Columns[col])
+	}
+	if group < 0 {
+		value, ignored := aggregate(t, matched, op, col)
+		res.Columns, res.Rows, res.Ignored = []string{name}, [][]string{{value}}, ignored
+		return res, nil
+	}
+	groups := make(map[string][]int)
+	var keys []string
+	for _, i := range matched {
+		k := t.Value(i, group)
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], i)
+	}
+	values := make(map[string]string, len(keys))
+	for _, k := range keys {
+		v, ignored := aggregate(t, groups[k], op, col)
+		values[k] = v
+		res.Ignored += ignored
+	}
+	// Largest first, as questions over groups usually ask for the top ones
+	sort.SliceStable(keys, func(a, b int) bool {
+		return compare(values[keys[a]], values[keys[b]]) > 0
+	})
+	res.Columns = []string{t.Columns[group], name}
+	res.Rows, res.More = limitRows(keys, q.Limit, func(k string) []string {
+		return []string{k, values[k]}
+	})
+	return res, nil
+}
+
+// limitRows turns the first limit keys, 50 by default, into rows, and
+// returns how many were left out.
+func limitRows(keys []string, limit int, row func(string) []string) ([][]string, int) {
+	if limit <= 0 {
+		limit = 50
+	}
+	more := 0
+	if len(keys) > limit {
+		keys, more = keys[:limit], len(keys)-limit
+	}
+	rows := make([][]string, len(keys))
+	for i, k := range keys {
+		rows[i] = row(k)
+	}
+	return rows, more
+}
+
+// aggregate computes op over column col of rows. It returns the value and
+// how many values were left out because they are not numbers.
+func aggregate(t *Table, rows []int, op string, col int) (string, int) {
+	if op == "count" {
+		n := 0
+		for _, i := range rows {
+			if col < 0 || t.Value(i, col) != "" {
+				n++
+			}
+		}
+		return strconv.Itoa(n), 0
+	}
+	var nums []float64
+	var texts []string
+	for _, i := range rows {
+		v := t.Value(i, col)
+		if v == "" {
+			continue
+		}
+		if f, ok := number(v); ok {
+			nums = append(nums, f)
+		} else {
+			texts = append(texts, v)
+		}
+	}
+	// min and max also work on dates and text when no value is a number
+	if (op == "min" || op == "max") && len(nums) == 0 && len(texts) > 0 {
+		best := texts[0]
+		for _, v := range texts[1:] {
+			if c := compare(v, best); (op == "min" && c < 0) || (op == "max" && c > 0) {
+				best = v
+			}
+		}
+		return best, 0
+	}
+	if len(nums) == 0 {
+		return "", len(texts)
+	}
+	var result float64
+	switch op {
+	case "sum", "avg":
+		for _, f := range nums {
+			result += f
+		}
+		if op == "avg" {
+			result /= float64(len(nums))
+		}
+	case "min", "max":
+		result = nums[0]
+		for _, f := range nums[1:] {
+			if (op == "min" && f < result) || (op == "max" && f > result) {
+				result = f
+			}
+		}
+	}
+	return formatNumber(result), len(texts)
+}
+
+// holds reports whether value satisfies the condition op want.
+func holds(value, op, want string) bool {
+	want = strings.TrimSpace(want)
+	switch op {
+	case "=", "==":
+		return compare(value, want) == 0
+	case "!=":
+		return compare(value, want) != 0
+	case "contains":
+		return strings.Contains(strings.ToLower(value), strings.ToLower(want))
+	}
+	if value == "" || !sameKind(value, want) {
+		return false
+	}
+	c := compare(value, want)
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// compare orders two values as numbers, as dates, or else as text ignoring case.
+func compare(a, b string) int {
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	if x, ok := date(a); ok {
+		if y, ok := date(b); ok {
+			return x.Compare(y)
+		}
+	}
+	return strings.Compare(strings.ToLower(strings.TrimSpace(a)), strings.ToLower(strings.TrimSpace(b)))
+}
+
+// sameKind reports whether a and b can be ordered: both numbers, both dates,
+// or else both text. A value such as "n/a" is neither above nor below 100.
+func sameKind(a, b string) bool {
+	_, an := number(a)
+	_, bn := number(b)
+	_, ad := date(a)
+	_, bd := date(b)
+	return an == bn && ad == bd
+}
+
+// thousands matches numbers written with thousands separators, as in 1,250.50.
+var thousands = regexp.MustCompile(`^-?\d{1,3}(,\d{3})+(\.\d+)?$`)
+
+// number parses a cell as a number, allowing thousands separators, a
+// currency sign and a percent sign.
+func number(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimLeft(s, "$€£¥")
+	s = strings.TrimSuffix(s, "%")
+	if thousands.MatchString(s) {
+		s = strings.ReplaceAll(s, ",", "")
+	}
+	if s == "" {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
+
+// dateLayouts are the date formats compare understands.
+var dateLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02", "2006/01/02", "02.01.2006"}
+
+func date(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// formatNumber writes whole numbers without decimals and others rounded to
+// four places.
+func formatNumber(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1e15 {
+		return strconv.FormatFloat(f, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(math.Round(f*1e4)/1e4, 'f', -1, 64)
+}
+
+func unknownColumn(t *Table, name string) error {
+	return fmt.Errorf("no column %q (columns: %s)", name, strings.Join(t.Columns, ", "))
+}
@@ -0,0 +1,180 @@
+package table
+
+import (
+	"strings"
+	"testing"
+)
+
+const tickets = `id,owner,status,amount,share,opened
+1,alice,open,"$1,250.50",10%,2026-01-15
+2,bob,closed,300,25%,2026-02-01
+3,alice,open,"1,000",5%,2025-12-31
+4,carol,open,n/a,,2026-01-15T09:00:00Z
+5,bob,closed,€50,60%,2026/03/01
+6,dave,,12.5,,
+`
+
+func ticketTable(t *testing.T) *Table {
+	t.Helper()
+	tbl, err := ReadCSV([]byte(tickets), ',')
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tbl
+}
+
+func TestRun(t *testing.T) {
+	tbl := ticketTable(t)
+	tests := []struct {
+		name    string
+		q       Query
+		rows    string // rows joined by ";", cells by ","
+		matched int
+		ignored int
+		more    int
+	}{
+		{"count", Query{Op: "count"}, "6", 6, 0, 0},
+		{"count non-empty", Query{Op: "count", Column: "status"}, "5", 6, 0, 0},
+		{"sum", Query{Op: "sum", Column: "amount"}, "2613", 6, 1, 0},
+		{"avg", Query{Op: "avg", Column: "amount"}, "522.6", 6, 1, 0},
+		{"min", Query{Op: "MIN", Column: "Amount"}, "12.5", 6, 1, 0},
+		{"max", Query{Op: "max", Column: "amount"}, "1250.5", 6, 1, 0},
+		{"sum of percentages", Query{Op: "sum", Column: "share"}, "100", 6, 0, 0},
+		{"avg of nothing", Query{Op: "avg", Column: "amount", Where: []Condition{{"owner", "=", "carol"}}}, "", 1, 1, 0},
+		{"min of dates", Query{Op: "min", Column: "opened"}, "2025-12-31", 6, 0, 0},
+		{"max of dates", Query{Op: "max", Column: "opened"}, "2026/03/01", 6, 0, 0},
+		{"max of text", Query{Op: "max", Column: "owner"}, "dave", 6, 0, 0},
+
+		{"equal ignores case", Query{Op: "count", Where: []Condition{{"status", "=", "OPEN"}}}, "3", 3, 0, 0},
+		{"not equal", Query{Op: "count", Where: []Condition{{"status", "!=", "open"}}}, "3", 3, 0, 0},
+		{"equal numbers", Query{Op: "count", Where: []Condition{{"amount", "==", "1000"}}}, "1", 1, 0, 0},
+		{"greater number", Query{Op: "count", Where: []Condition{{"amount", ">", "999"}}}, "2", 2, 0, 0},
+		{"text is not a number", Query{Op: "count", Where: []Condition{{"amount", "<", "1e9"}}}, "5", 5, 0, 0},
+		{"date on or after", Query{Op: "count", Where: []Condition{{"opened", ">=", "2026-01-15"}}}, "4", 4, 0, 0},
+		{"date before", Query{Op: "count", Where: []Condition{{"opened", "<", "2026-01-20"}}}, "3", 3, 0, 0},
+		{"contains", Query{Op: "count", Where: []Condition{{"owner", "contains", "AR"}}}, "1", 1, 0, 0},
+		{"all conditions", Query{Op: "sum", Column: "amount", Where: []Condition{{"owner", "=", "alice"}, {"opened", ">", "2026-01-01"}}}, "1250.5", 1, 0, 0},
+
+		{"grouped sum, largest first", Query{Op: "sum", Column: "amount", GroupBy: "owner"}, "alice,2250.5;bob,350;dave,12.5;carol,", 6, 1, 0},
+		{"grouped count", Query{Op: "count", GroupBy: "status"}, "open,3;closed,2;,1", 6, 0, 0},
+		{"grouped limit", Query{Op: "count", GroupBy: "owner", Limit: 2}, "alice,2;bob,2", 6, 0, 2},
+		{"distinct", Query{Op: "distinct", Column: "owner"}, "alice,2;bob,2;carol,1;dave,1", 6, 0, 0},
+		{"rows of a column", Query{Op: "rows", Column: "id", Where: []Condition{{"owner", "=", "alice"}}}, "1;3", 2, 0, 0},
+		{"rows limit", Query{Op: "rows", Column: "id", Limit: 2}, "1;2", 6, 0, 4},
+	}
+	for _, tt := range tests {
+		res, err := Run(tbl, tt.q)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		rows := make([]string, len(res.Rows))
+		for i, row := range res.Rows {
+			rows[i] = strings.Join(row, ",")
+		}
+		if got := strings.Join(rows, ";"); got != tt.rows || res.Matched != tt.matched || res.Ignored != tt.ignored || res.More != tt.more {
+			t.Errorf("%s: rows %q, matched %d, ignored %d, more %d; want %q, %d, %d, %d",
+				tt.name, got, res.Matched, res.Ignored, res.More, tt.rows, tt.matched, tt.ignored, tt.more)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tbl := ticketTable(t)
+	for _, q := range []Query{
+		{Op: "median", Column: "amount"},
+		{Op: "sum"},
+		{Op: "sum", Column: "price"},
+		{Op: "count", GroupBy: "team"},
+		{Op: "count", Where: []Condition{{"team", "=", "x"}}},
+		{Op: "count", Where: []Condition{{"status", "~", "open"}}},
+		{Op: "distinct", Column: "owner", GroupBy: "status"},
+		{Op: "rows", GroupBy: "status"},
+	} {
+		if _, err := Run(tbl, q); err == nil {
+			t.Errorf("Run(%s) succeeded, want an error", q)
+		}
+	}
+}
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"42", 42, true},
+		{" -3.5 ", -3.5, true},
+		{"1,250.50", 1250.5, true},
+		{"12,345,678", 12345678, true},
+		{"-1,234", -1234, true},
+		{"$19.99", 19.99, true},
+		{"€5", 5, true},
+		{"15%", 15, true},
+		{"1e3", 1000, true},
+		{"1,00", 0, false},
+		{"1,2345", 0, false},
+		{"2026-01-15", 0, false},
+		{"n/a", 0, false},
+		{"NaN", 0, false},
+		{"Inf", 0, false},
+		{"$", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := number(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("number(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"9", "10", -1},
+		{"1,000", "999.5", 1},
+		{"$5", "5", 0},
+		{"2026-01-15", "2026/01/14", 1},
+		{"02.01.2026", "2026-01-01", 1},
+		{"2026-01-15T00:00:00Z", "2026-01-15", 0},
+		{"apple", "Banana", -1},
+		{" Open", "open", 0},
+		{"10", "9a", -1}, // text: "10" < "9a"
+	}
+	for _, tt := range tests {
+		if got := compare(tt.a, tt.b); got != tt.want {
+			t.Errorf("compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	for in, want := range map[float64]string{
+		2613:    "2613",
+		-12:     "-12",
+		522.6:   "522.6",
+		1.0 / 3: "0.3333",
+		0.00004: "0",
+		1e16:    "10000000000000000",
+	} {
+		if got := formatNumber(in); got != want {
+			t.Errorf("formatNumber(%v) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestResultString(t *testing.T) {
+	res := &Result{
+		Columns: []string{"name", "count"},
+		Rows:    [][]string{{"a|b", "2"}, {"two\nlines", "1"}},
+		Matched: 3, Total: 4, Ignored: 1, More: 5,
+	}
+	want := "3 of 4 rows matched; 1 values that are not numbers were ignored.\n\n" +
+		"| name | count |\n| --- | --- |\n| a\\|b | 2 |\n| two lines | 1 |\n\n5 more not shown.\n"
+	if got := res.String(); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+}
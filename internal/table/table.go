@@ -0,0 +1,377 @@
+// Package table reads tabular data from CSV and JSON files and answers exact
+// aggregate queries over it, such as counts and sums, which retrieval over
+// embedded rows can only estimate.
+package table
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Table is a file of records with named columns.
+type Table struct {
+	Columns []string   // in the order they first appear
+	Rows    [][]string // values by column index; rows may be shorter than Columns
+	Lines   []int      // source line where each row starts; 0 when unknown
+}
+
+// Value returns the value of column col in row, or "" when the row has none.
+func (t *Table) Value(row, col int) string {
+	if col < len(t.Rows[row]) {
+		return t.Rows[row][col]
+	}
+	return ""
+}
+
+// Column returns the index of the named column, matched case-insensitively,
+// or -1.
+func (t *Table) Column(name string) int {
+	name = strings.TrimSpace(name)
+	for i, c := range t.Columns {
+		if strings.EqualFold(c, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// extensions maps the file types Read understands to their format.
+var extensions = map[string]string{
+	".csv":    "csv",
+	".tsv":    "tsv",
+	".json":   "json",
+	".jsonl":  "jsonl",
+	".ndjson": "jsonl",
+}
+
+// IsTable reports whether Read understands the file type of path.
+func IsTable(path string) bool {
+	_, ok := extensions[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// Read parses data according to the extension of path. It returns problems
+// that did not stop the file from being read, such as invalid JSON lines.
+func Read(path string, data []byte) (*Table, []string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // UTF-8 byte order mark
+	switch extensions[strings.ToLower(filepath.Ext(path))] {
+	case "csv":
+		t, err := ReadCSV(data, sniffDelimiter(data))
+		return t, nil, err
+	case "tsv":
+		t, err := ReadCSV(data, '\t')
+		return t, nil, err
+	case "json":
+		t, err := ReadJSON(data)
+		return t, nil, err
+	case "jsonl":
+		return ReadJSONLines(data)
+	}
+	return nil, nil, fmt.Errorf("%s is not a CSV or JSON file", path)
+}
+
+// sniffDelimiter picks the most frequent of the usual delimiters in the
+// first line, preferring commas.
+func sniffDelimiter(data []byte) rune {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	best, count := ',', bytes.Count(line, []byte(","))
+	for _, d := range []rune{';', '\t', '|'} {
+		if n := bytes.Count(line, []byte(string(d))); n > count {
+			best, count = d, n
+		}
+	}
+	return best
+}
+
+// ReadCSV parses delimited text. The first non-empty record holds the column
+// names; blank rows are skipped.
+func ReadCSV(data []byte, delimiter rune) (*Table, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = delimiter
+	r.LazyQuotes = true
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	t := &Table{}
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if blank(record) {
+			continue
+		}
+		if t.Columns == nil {
+			t.Columns = columnNames(record)
+			continue
+		}
+		for len(record) > len(t.Columns) {
+			t.Columns = append(t.Columns, fmt.Sprintf("column %d", len(t.Columns)+1))
+		}
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+		line, _ := r.FieldPos(0)
+		t.Rows = append(t.Rows, record)
+		t.Lines = append(t.Lines, line)
+	}
+	if t.Columns == nil {
+		return nil, fmt.Errorf("no header row")
+	}
+	return t, nil
+}
+
+func blank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// columnNames cleans up a header row: empty names are numbered and repeated
+// names get a suffix.
+func columnNames(header []string) []string {
+	names := make([]string, len(header))
+	seen := make(map[string]int)
+	for i, h := range header {
+		name := strings.TrimSpace(h)
+		if name == "" {
+			name = fmt.Sprintf("column %d", i+1)
+		}
+		key := strings.ToLower(name)
+		if n := seen[key]; n > 0 {
+			name = fmt.Sprintf("%s %d", name, n+1)
+		}
+		seen[key]++
+		names[i] = name
+	}
+	return names
+}
+
+// ReadJSON parses a JSON document. An array holds one record per element; of
+// an object, the longest array of objects it holds, such as "items", is used,
+// and otherwise the object is the only record. Nested objects become columns
+// named by their path, such as "owner.name".
+func ReadJSON(data []byte) (*Table, error) {
+	v, err := decode(json.NewDecoder(bytes.NewReader(data)))
+	if err != nil {
+		return nil, err
+	}
+	records := []any{v}
+	switch v := v.(type) {
+	case []any:
+		records = v
+	case object:
+		for _, f := range v {
+			if arr, ok := f.value.([]any); ok && len(arr) > 0 && len(arr) >= len(records) && isObject(arr[0]) {
+				records = arr
+			}
+		}
+	}
+	t := newBuilder()
+	for _, r := range records {
+		t.add(r, 0)
+	}
+	return t.Table, nil
+}
+
+// ReadJSONLines parses one JSON value per line. Lines that are not valid
+// JSON are skipped and reported.
+func ReadJSONLines(data []byte) (*Table, []string, error) {
+	t := newBuilder()
+	var warnings []string
+	for n, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		v, err := decode(json.NewDecoder(bytes.NewReader(line)))
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("line %d: %v", n+1, err))
+			continue
+		}
+		t.add(v, n+1)
+	}
+	if len(t.Rows) == 0 && len(warnings) > 0 {
+		return nil, nil, fmt.Errorf("no valid JSON lines: %s", warnings[0])
+	}
+	return t.Table, warnings, nil
+}
+
+// field is one member of a JSON object; objects are decoded as ordered
+// fields so that columns keep the order of the file.
+type field struct {
+	key   string
+	value any
+}
+
+type object []field
+
+func isObject(v any) bool {
+	_, ok := v.(object)
+	return ok
+}
+
+// decode reads one JSON value, keeping the order of object members. Numbers
+// are kept as written.
+func decode(dec *json.Decoder) (any, error) {
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		var obj object
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decode(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, field{key: fmt.Sprint(key), value: v})
+		}
+		_, err := dec.Token() // }
+		return obj, err
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			v, err := decode(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		_, err := dec.Token() // ]
+		return arr, err
+	}
+	return tok, nil
+}
+
+// builder collects JSON records into a Table.
+type builder struct {
+	*Table
+	index map[string]int // column index by name
+}
+
+func newBuilder() *builder {
+	return &builder{Table: &Table{}, index: make(map[string]int)}
+}
+
+// add appends a record that started on line, flattening nested objects.
+func (b *builder) add(v any, line int) {
+	row := []string{}
+	set := func(name, value string) {
+		i, ok := b.index[name]
+		if !ok {
+			i = len(b.Columns)
+			b.index[name] = i
+			b.Columns = append(b.Columns, name)
+		}
+		for len(row) <= i {
+			row = append(row, "")
+		}
+		row[i] = value
+	}
+	if obj, ok := v.(object); ok {
+		flatten("", obj, set)
+	} else {
+		set("value", scalar(v))
+	}
+	b.Rows = append(b.Rows, row)
+	b.Lines = append(b.Lines, line)
+}
+
+// flatten calls set for every member of obj, naming members of nested
+// objects by their path.
+func flatten(prefix string, obj object, set func(name, value string)) {
+	for _, f := range obj {
+		name := prefix + f.key
+		if nested, ok := f.value.(object); ok {
+			flatten(name+".", nested, set)
+			continue
+		}
+		set(name, scalar(f.value))
+	}
+}
+
+// scalar formats a JSON value as a cell. Arrays of scalars are joined with
+// commas; other arrays are kept as JSON.
+func scalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, e := range v {
+			switch e.(type) {
+			case object, []any:
+				return compact(v)
+			}
+			parts = append(parts, scalar(e))
+		}
+		return strings.Join(parts, ", ")
+	case object:
+		return compact(v)
+	}
+	return fmt.Sprint(v)
+}
+
+// compact writes a decoded value back as JSON.
+func compact(v any) string {
+	var b strings.Builder
+	var write func(v any)
+	write = func(v any) {
+		switch v := v.(type) {
+		case object:
+			b.WriteByte('{')
+			for i, f := range v {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				key, _ := json.Marshal(f.key)
+				b.Write(key)
+				b.WriteByte(':')
+				write(f.value)
+			}
+			b.WriteByte('}')
+		case []any:
+			b.WriteByte('[')
+			for i, e := range v {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				write(e)
+			}
+			b.WriteByte(']')
+		default:
+			data, _ := json.Marshal(v)
+			b.Write(data)
+		}
+	}
+	write(v)
+	return b.String()
+}
@@ -0,0 +1,633 @@
+package vectorstore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go-groq/internal/rag"
+)
+
+// FormatVersion is the on-disk format written by Disk. Indexes with another
+// version must be rebuilt.
+const FormatVersion = 1
+
+const (
+	headerFile = "header.json"
+	logFile    = "chunks.log"
+	logMagic   = "GRAGLOG1"
+	snapFile   = "index.snap"
+	snapMagic  = "GRAGSNP1"
+
+	// maxRecordSize bounds a log record, a chunk with its vector, so that a
+	// damaged length cannot make a load allocate gigabytes.
+	maxRecordSize = 64 << 20
+
+	opUpsert byte = 1
+	opDelete byte = 2
+)
+
+// Header describes an index on disk. It pins the embedding model and vector
+// dimension so that vectors from different models are never mixed.
+type Header struct {
+	Version        int       `json:"version"`
+	EmbeddingModel string    `json:"embedding_model,omitempty"`
+	Dimension      int       `json:"dimension,omitempty"`
+	Metric         string    `json:"metric"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// ModelMismatchError is returned when an index is opened with a different
+// embedding model than the one it was built with.
+type ModelMismatchError struct {
+	Dir       string
+	IndexedAs string
+	Requested string
+}
+
+func (e *ModelMismatchError) Error() string {
+	return fmt.Sprintf("index %s was built with embedding model %q but %q is configured; "+
+		"use a different index directory or switch the embedding model back", e.Dir, e.IndexedAs, e.Requested)
+}
+
+// Disk is a file-backed VectorStore. Every change is appended to a log of
+// checksummed records and synced before it is applied, so a crash loses at
+// most the write in progress; a torn record at the end of the log is dropped
+// on the next load. Searches are served by an in-memory index that is built
+// from the log on first use, so opening an index is cheap. Indexes that
+// implement Snapshotter are saved on Close, and later loads only replay the
+// part of the log written after the snapshot.
+type Disk struct {
+	dir    string
+	header Header
+	index  Index // serves searches; rebuilt from the log on load
+
+	loadOnce sync.Once
+	loadErr  error
+
+	mu      sync.Mutex // serializes writes to the log
+	log     *os.File
+	records int  // records in the log, live or not
+	dirty   bool // the log changed since the snapshot was written
+}
+
+// OpenDisk opens or creates the index in dir. model is the embedding model
+// that will produce vectors; an empty model accepts any existing index.
+// index is the in-memory structure that serves searches, e.g. NewMemory.
+func OpenDisk(dir, model string, metric Metric, index Index) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	d := &Disk{dir: dir, index: index}
+
+	data, err := os.ReadFile(filepath.Join(dir, headerFile))
+	switch {
+	case os.IsNotExist(err):
+		d.header = Header{Version: FormatVersion, EmbeddingModel: model, Metric: metric.String(), CreatedAt: time.Now().UTC()}
+		if err := d.writeHeader(); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, &d.header); err != nil {
+			return nil, fmt.Errorf("read index header: %w", err)
+		}
+		if d.header.Version != FormatVersion {
+			return nil, fmt.Errorf("index %s has format version %d, expected %d; rebuild it", dir, d.header.Version, FormatVersion)
+		}
+		if model != "" && d.header.EmbeddingModel != "" && model != d.header.EmbeddingModel {
+			return nil, &ModelMismatchError{Dir: dir, IndexedAs: d.header.EmbeddingModel, Requested: model}
+		}
+		if d.header.Metric != metric.String() {
+			return nil, fmt.Errorf("index %s uses the %s metric but %s is configured", dir, d.header.Metric, metric)
+		}
+		if model != "" && d.header.EmbeddingModel == "" {
+			d.header.EmbeddingModel = model
+			if err := d.writeHeader(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return d, nil
+}
+
+// Header returns the index header.
+func (d *Disk) Header() Header {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.header
+}
+
+// writeHeader atomically replaces the header file.
+func (d *Disk) writeHeader() error {
+	data, err := json.MarshalIndent(d.header, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(d.dir, headerFile), data)
+}
+
+// load replays the log into the in-memory index once.
+func (d *Disk) load() error {
+	d.loadOnce.Do(func() {
+		d.loadErr = d.replay()
+	})
+	return d.loadErr
+}
+
+func (d *Disk) replay() error {
+	path := filepath.Join(d.dir, logFile)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if info.Size() == 0 {
+		if _, err := f.WriteString(logMagic); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	} else {
+		good, err := d.readLog(f, d.loadSnapshot(info.Size()), info.Size())
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if good < info.Size() {
+			// Drop a torn tail left by a crash
+			log.Printf("index %s: dropping %d bytes of an incomplete record at offset %d of %s", d.dir, info.Size()-good, good, logFile)
+			if err := f.Truncate(good); err != nil {
+				f.Close()
+				return err
+			}
+		}
+	}
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		f.Close()
+		return err
+	}
+	d.log = f
+	return nil
+}
+
+// readLog applies every valid record from offset on and returns the offset
+// after the last one. An offset of 0 reads the whole log. A bad record is
+// only dropped when it is the tail of the log, which is what a crash during a
+// write leaves behind: the last record, one running past the end of the file
+// or one followed by nothing but zero bytes. A bad record before valid ones
+// means the log is damaged, and readLog returns an error rather than lose
+// the records after it.
+func (d *Disk) readLog(f *os.File, offset, size int64) (int64, error) {
+	r := bufio.NewReaderSize(f, 1<<20)
+	magic := make([]byte, len(logMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != logMagic {
+		return 0, fmt.Errorf("%s is not a vector index log", f.Name())
+	}
+	offset = max(offset, int64(len(logMagic)))
+	if _, err := r.Discard(int(offset) - len(logMagic)); err != nil {
+		return 0, err
+	}
+	ctx := context.Background()
+	for offset < size {
+		if size-offset < 8 {
+			return offset, nil // torn prefix
+		}
+		var prefix [8]byte
+		if _, err := io.ReadFull(r, prefix[:]); err != nil {
+			return 0, fmt.Errorf("read %s: %w", f.Name(), err)
+		}
+		n := int64(binary.LittleEndian.Uint32(prefix[0:4]))
+		sum := binary.LittleEndian.Uint32(prefix[4:8])
+		end := offset + 8 + n
+		if n > maxRecordSize {
+			return d.badRecord(f, offset, end, size, fmt.Errorf("record length %d is too large", n))
+		}
+		if end > size {
+			return offset, nil // torn payload
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return 0, fmt.Errorf("read %s: %w", f.Name(), err)
+		}
+		if crc32.ChecksumIEEE(payload) != sum {
+			return d.badRecord(f, offset, end, size, errors.New("checksum mismatch"))
+		}
+		if err := d.apply(ctx, payload); err != nil {
+			return d.badRecord(f, offset, end, size, err)
+		}
+		offset = end
+		d.records++
+		d.dirty = true
+	}
+	return offset, nil
+}
+
+// badRecord decides about an invalid record from offset to end in a log of
+// size bytes: it returns offset, so that the tail is truncated, when the
+// record is the last one or only zero bytes follow it, and an error
+// otherwise.
+func (d *Disk) badRecord(f *os.File, offset, end, size int64, cause error) (int64, error) {
+	if end == size || zeros(io.NewSectionReader(f, offset, size-offset)) {
+		return offset, nil
+	}
+	return 0, fmt.Errorf("index log %s is damaged at offset %d (%v) and more records follow; "+
+		"restore it from a backup or rebuild the index", f.Name(), offset, cause)
+}
+
+// zeros reports whether r holds nothing but zero bytes.
+func zeros(r *io.SectionReader) bool {
+	buf := make([]byte, 64<<10)
+	for off := int64(0); ; off += int64(len(buf)) {
+		n, err := r.ReadAt(buf, off)
+		for _, b := range buf[:n] {
+			if b != 0 {
+				return false
+			}
+		}
+		if err != nil {
+			return true
+		}
+	}
+}
+
+// loadSnapshot restores the index from its snapshot, if there is a valid one
+// for a log of logSize bytes, and returns the log offset it covers. It
+// returns 0 when the whole log must be replayed.
+func (d *Disk) loadSnapshot(logSize int64) int64 {
+	snap, ok := d.index.(Snapshotter)
+	if !ok {
+		return 0
+	}
+	data, err := os.ReadFile(filepath.Join(d.dir, snapFile))
+	header := len(snapMagic) + 16
+	if err != nil || len(data) < header+4 || string(data[:len(snapMagic)]) != snapMagic {
+		return 0
+	}
+	body, sum := data[:len(data)-4], binary.LittleEndian.Uint32(data[len(data)-4:])
+	offset := int64(binary.LittleEndian.Uint64(data[len(snapMagic):]))
+	records := int(binary.LittleEndian.Uint64(data[len(snapMagic)+8:]))
+	if crc32.ChecksumIEEE(body) != sum || offset > logSize {
+		return 0
+	}
+	if err := snap.ReadSnapshot(bytes.NewReader(body[header:])); err != nil {
+		return 0
+	}
+	d.records = records
+	return offset
+}
+
+// writeSnapshot saves the index state together with the log offset it
+// covers. Callers hold d.mu.
+func (d *Disk) writeSnapshot() error {
+	snap, ok := d.index.(Snapshotter)
+	if !ok || !d.dirty || d.log == nil {
+		return nil
+	}
+	info, err := d.log.Stat()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(snapMagic)
+	binary.Write(&buf, binary.LittleEndian, uint64(info.Size()))
+	binary.Write(&buf, binary.LittleEndian, uint64(d.records))
+	if err := snap.WriteSnapshot(&buf); err != nil {
+		return fmt.Errorf("write index snapshot: %w", err)
+	}
+	binary.Write(&buf, binary.LittleEndian, crc32.ChecksumIEEE(buf.Bytes()))
+	if err := writeFileAtomic(filepath.Join(d.dir, snapFile), buf.Bytes()); err != nil {
+		return fmt.Errorf("write index snapshot: %w", err)
+	}
+	d.dirty = false
+	return nil
+}
+
+// apply decodes one record and applies it to the in-memory index.
+func (d *Disk) apply(ctx context.Context, payload []byte) error {
+	if len(payload) == 0 {
+		return errors.New("empty record")
+	}
+	switch payload[0] {
+	case opUpsert:
+		c, err := decodeChunk(payload[1:])
+		if err != nil {
+			return err
+		}
+		return d.index.Upsert(ctx, []rag.Chunk{c})
+	case opDelete:
+		return d.index.DeleteDoc(ctx, string(payload[1:]))
+	default:
+		return fmt.Errorf("unknown record type %d", payload[0])
+	}
+}
+
+// Upsert implements VectorStore.
+func (d *Disk) Upsert(ctx context.Context, chunks []rag.Chunk) error {
+	if err := d.load(); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	dim := d.header.Dimension
+	var buf []byte
+	for _, c := range chunks {
+		if len(c.Vector) > 0 {
+			if dim == 0 {
+				dim = len(c.Vector)
+			} else if len(c.Vector) != dim {
+				return fmt.Errorf("chunk %s has %d dimensions, index has %d", c.ID, len(c.Vector), dim)
+			}
+		}
+		payload, err := encodeChunk(c)
+		if err != nil {
+			return err
+		}
+		if len(payload) > maxRecordSize {
+			return fmt.Errorf("chunk %s is too large to store (%d bytes)", c.ID, len(payload))
+		}
+		buf = appendRecord(buf, payload)
+	}
+	if dim != d.header.Dimension {
+		d.header.Dimension = dim
+		if err := d.writeHeader(); err != nil {
+			return err
+		}
+	}
+	if err := d.appendLog(buf, len(chunks)); err != nil {
+		return err
+	}
+	return d.index.Upsert(ctx, chunks)
+}
+
+// DeleteDoc implements VectorStore.
+func (d *Disk) DeleteDoc(ctx context.Context, docID string) error {
+	if err := d.load(); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	payload := append([]byte{opDelete}, docID...)
+	if err := d.appendLog(appendRecord(nil, payload), 1); err != nil {
+		return err
+	}
+	if err := d.index.DeleteDoc(ctx, docID); err != nil {
+		return err
+	}
+	return d.maybeCompact()
+}
+
+// appendLog writes records and syncs them to disk. Callers hold d.mu.
+func (d *Disk) appendLog(buf []byte, n int) error {
+	if _, err := d.log.Write(buf); err != nil {
+		return fmt.Errorf("write index log: %w", err)
+	}
+	if err := d.log.Sync(); err != nil {
+		return fmt.Errorf("sync index log: %w", err)
+	}
+	d.records += n
+	d.dirty = true
+	return nil
+}
+
+// Search implements VectorStore.
+func (d *Disk) Search(ctx context.Context, vector []float32, opts SearchOptions) ([]Result, error) {
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	return d.index.Search(ctx, vector, opts)
+}
+
+// Count implements VectorStore. It returns 0 if the index cannot be loaded.
+func (d *Disk) Count() int {
+	if err := d.load(); err != nil {
+		return 0
+	}
+	return d.index.Count()
+}
+
+// Scan implements Scanner.
+func (d *Disk) Scan(fn func(rag.Chunk) bool) error {
+	if err := d.load(); err != nil {
+		return err
+	}
+	return d.index.Scan(fn)
+}
+
+// maybeCompact rewrites the log once most of its records are dead.
+// Callers hold d.mu.
+func (d *Disk) maybeCompact() error {
+	live := d.index.Count()
+	if d.records < 1024 || d.records < 2*live {
+		return nil
+	}
+	return d.compact()
+}
+
+// Compact rewrites the log with only the live chunks.
+func (d *Disk) Compact() error {
+	if err := d.load(); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.compact()
+}
+
+func (d *Disk) compact() error {
+	path := filepath.Join(d.dir, logFile)
+	tmp, err := os.CreateTemp(d.dir, "chunks-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriterSize(tmp, 1<<20)
+	w.WriteString(logMagic)
+	records := 0
+	var writeErr error
+	err = d.index.Scan(func(c rag.Chunk) bool {
+		payload, err := encodeChunk(c)
+		if err != nil {
+			writeErr = err
+			return false
+		}
+		if _, err := w.Write(appendRecord(nil, payload)); err != nil {
+			writeErr = err
+			return false
+		}
+		records++
+		return true
+	})
+	if err == nil {
+		err = writeErr
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("compact index: %w", err)
+	}
+	// The snapshot refers to offsets in the old log.
+	if err := os.Remove(filepath.Join(d.dir, snapFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("compact index: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("compact index: %w", err)
+	}
+	syncDir(d.dir)
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	d.log.Close()
+	d.log = f
+	d.records = records
+	d.dirty = true
+	return nil
+}
+
+// Close saves a snapshot if the index supports it and releases the log file.
+func (d *Disk) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.log == nil {
+		return nil
+	}
+	err := d.writeSnapshot()
+	if cerr := d.log.Close(); err == nil {
+		err = cerr
+	}
+	d.log = nil
+	return err
+}
+
+// appendRecord frames payload as length, CRC-32 and bytes.
+func appendRecord(buf, payload []byte) []byte {
+	var prefix [8]byte
+	binary.LittleEndian.PutUint32(prefix[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(prefix[4:8], crc32.ChecksumIEEE(payload))
+	buf = append(buf, prefix[:]...)
+	return append(buf, payload...)
+}
+
+// storedChunk is the JSON part of an upsert record; the vector follows in binary.
+type storedChunk struct {
+	ID       string            `json:"id"`
+	DocID    string            `json:"doc_id"`
+	Text     string            `json:"text"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// encodeChunk returns an upsert payload: op, JSON length, JSON, dimension, float32 vector.
+func encodeChunk(c rag.Chunk) ([]byte, error) {
+	meta, err := json.Marshal(storedChunk{ID: c.ID, DocID: c.DocID, Text: c.Text, Metadata: c.Metadata})
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, 1+4+len(meta)+4+4*len(c.Vector))
+	buf = append(buf, opUpsert)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(meta)))
+	buf = append(buf, meta...)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(c.Vector)))
+	for _, x := range c.Vector {
+		buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(x))
+	}
+	return buf, nil
+}
+
+// decodeChunk reverses encodeChunk, without the leading op byte.
+func decodeChunk(b []byte) (rag.Chunk, error) {
+	if len(b) < 4 {
+		return rag.Chunk{}, errors.New("short record")
+	}
+	n := int(binary.LittleEndian.Uint32(b))
+	b = b[4:]
+	if len(b) < n+4 {
+		return rag.Chunk{}, errors.New("short record")
+	}
+	var sc storedChunk
+	if err := json.Unmarshal(b[:n], &sc); err != nil {
+		return rag.Chunk{}, err
+	}
+	b = b[n:]
+	dim := int(binary.LittleEndian.Uint32(b))
+	b = b[4:]
+	if len(b) != 4*dim {
+		return rag.Chunk{}, errors.New("vector length mismatch")
+	}
+	var vector []float32
+	if dim > 0 {
+		vector = make([]float32, dim)
+		for i := range vector {
+			vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+		}
+	}
+	return rag.Chunk{ID: sc.ID, DocID: sc.DocID, Text: sc.Text, Vector: vector, Metadata: sc.Metadata}, nil
+}
+
+// writeFileAtomic writes data to a temporary file, syncs it and renames it over path.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// syncDir makes a rename durable where the platform supports it.
+func syncDir(dir string) {
+	if f, err := os.Open(dir); err == nil {
+		f.Sync()
+		f.Close()
+	}
+}
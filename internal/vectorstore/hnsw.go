@@ -0,0 +1,604 @@
+package vectorstore
+
+import (
+	"bufio"
+	"cmp"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"slices"
+	"sync"
+
+	"go-groq/internal/rag"
+)
+
+// HNSWConfig tunes an HNSW index. Larger values give better recall at the
+// cost of memory, insert time and search time.
+type HNSWConfig struct {
+	M              int // links per node on each layer; layer 0 allows 2*M
+	EfConstruction int // candidates considered while inserting
+	EfSearch       int // candidates considered while searching; at least K is used
+}
+
+// DefaultHNSWConfig returns settings that reach high recall on typical
+// embedding collections.
+func DefaultHNSWConfig() HNSWConfig {
+	return HNSWConfig{M: 16, EfConstruction: 100, EfSearch: 64}
+}
+
+// hnswNode is a chunk and its links. Chunks without a usable vector are kept
+// for Scan and Count but have no links and are never reached by a search.
+type hnswNode struct {
+	chunk   rag.Chunk
+	norm    float64
+	links   [][]int32 // neighbours per layer; nil when the node is not in the graph
+	deleted bool
+}
+
+// HNSW is an in-memory VectorStore that searches a hierarchical navigable
+// small world graph instead of scanning every vector. Results are
+// approximate: recall depends on HNSWConfig. Deleted and replaced chunks are
+// tombstoned and skipped; the graph is rebuilt once tombstones outnumber live
+// chunks.
+type HNSW struct {
+	metric    Metric
+	cfg       HNSWConfig
+	levelMult float64
+
+	mu       sync.RWMutex
+	rng      *rand.Rand
+	nodes    []hnswNode
+	byID     map[string]int32    // live chunk ID -> node
+	byDoc    map[string][]string // doc ID -> chunk IDs
+	dim      int                 // vector dimension of the graph, set by the first vector
+	entry    int32               // entry point on the top layer, -1 when the graph is empty
+	maxLevel int
+	deleted  int // tombstoned nodes
+}
+
+// NewHNSW creates an empty HNSW index. Zero fields in cfg take their defaults.
+func NewHNSW(metric Metric, cfg HNSWConfig) *HNSW {
+	def := DefaultHNSWConfig()
+	if cfg.M < 2 {
+		cfg.M = def.M
+	}
+	if cfg.EfConstruction <= 0 {
+		cfg.EfConstruction = def.EfConstruction
+	}
+	if cfg.EfSearch <= 0 {
+		cfg.EfSearch = def.EfSearch
+	}
+	h := &HNSW{metric: metric, cfg: cfg, levelMult: 1 / math.Log(float64(cfg.M))}
+	h.reset()
+	return h
+}
+
+// SetEfSearch changes the number of candidates considered while searching.
+// It takes effect immediately and needs no rebuild.
+func (h *HNSW) SetEfSearch(ef int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ef > 0 {
+		h.cfg.EfSearch = ef
+	}
+}
+
+// reset empties the index. Callers hold h.mu or own h exclusively.
+func (h *HNSW) reset() {
+	h.rng = rand.New(rand.NewSource(1))
+	h.nodes = nil
+	h.byID = make(map[string]int32)
+	h.byDoc = make(map[string][]string)
+	h.dim = 0
+	h.entry = -1
+	h.maxLevel = 0
+	h.deleted = 0
+}
+
+// Upsert implements VectorStore.
+func (h *HNSW) Upsert(ctx context.Context, chunks []rag.Chunk) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, c := range chunks {
+		if i, ok := h.byID[c.ID]; ok {
+			if old := h.nodes[i].chunk.DocID; old != c.DocID {
+				moveChunk(h.byDoc, c.ID, old, c.DocID)
+			}
+			h.tombstone(i)
+		} else {
+			h.byDoc[c.DocID] = append(h.byDoc[c.DocID], c.ID)
+		}
+		h.insert(c)
+	}
+	return nil
+}
+
+// DeleteDoc implements VectorStore.
+func (h *HNSW) DeleteDoc(ctx context.Context, docID string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, id := range h.byDoc[docID] {
+		if i, ok := h.byID[id]; ok {
+			h.tombstone(i)
+			delete(h.byID, id)
+		}
+	}
+	delete(h.byDoc, docID)
+	if h.deleted > 1024 && h.deleted > len(h.byID) {
+		h.rebuild()
+	}
+	return nil
+}
+
+// Count implements VectorStore.
+func (h *HNSW) Count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.byID)
+}
+
+// Scan implements Scanner.
+func (h *HNSW) Scan(fn func(rag.Chunk) bool) error {
+	h.mu.RLock()
+	chunks := make([]rag.Chunk, 0, len(h.byID))
+	for _, n := range h.nodes {
+		if !n.deleted {
+			chunks = append(chunks, n.chunk)
+		}
+	}
+	h.mu.RUnlock()
+	for _, c := range chunks {
+		if !fn(c) {
+			break
+		}
+	}
+	return nil
+}
+
+// Search implements VectorStore. With a filter, the graph search may find
+// fewer than K matching chunks; the index then falls back to an exact scan.
+func (h *HNSW) Search(ctx context.Context, vector []float32, opts SearchOptions) ([]Result, error) {
+	if opts.K <= 0 || len(vector) == 0 {
+		return nil, nil
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.entry < 0 || len(vector) != h.dim {
+		return nil, nil
+	}
+	qnorm := norm(vector)
+
+	ep := h.greedy(vector, qnorm, h.entry, h.maxLevel, 1)
+	ef := max(h.cfg.EfSearch, opts.K)
+	best := newTopK(opts.K)
+	for _, c := range h.searchLayer(vector, qnorm, []candidate{ep}, ef, 0) {
+		n := &h.nodes[c.id]
+		if n.deleted || (opts.Filter != nil && !opts.Filter(n.chunk.Metadata)) {
+			continue
+		}
+		best.offer(Result{Chunk: n.chunk, Score: c.score})
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if opts.Filter != nil && best.Len() < opts.K {
+		return h.exact(ctx, vector, qnorm, opts)
+	}
+	return best.sorted(), nil
+}
+
+// exact scans every live node. Callers hold h.mu.
+func (h *HNSW) exact(ctx context.Context, vector []float32, qnorm float64, opts SearchOptions) ([]Result, error) {
+	best := newTopK(opts.K)
+	for i := range h.nodes {
+		if i%1024 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		n := &h.nodes[i]
+		if n.deleted || n.links == nil || (opts.Filter != nil && !opts.Filter(n.chunk.Metadata)) {
+			continue
+		}
+		best.offer(Result{Chunk: n.chunk, Score: score(h.metric, vector, n.chunk.Vector, qnorm, n.norm)})
+	}
+	return best.sorted(), nil
+}
+
+// tombstone marks node i deleted. Callers hold h.mu.
+func (h *HNSW) tombstone(i int32) {
+	if !h.nodes[i].deleted {
+		h.nodes[i].deleted = true
+		h.deleted++
+	}
+}
+
+// rebuild recreates the graph from the live chunks. Callers hold h.mu.
+func (h *HNSW) rebuild() {
+	nodes := h.nodes
+	h.reset()
+	for _, n := range nodes {
+		if n.deleted {
+			continue
+		}
+		h.byDoc[n.chunk.DocID] = append(h.byDoc[n.chunk.DocID], n.chunk.ID)
+		h.insert(n.chunk)
+	}
+}
+
+// insert adds c as a new node and links it into the graph. Callers hold h.mu.
+func (h *HNSW) insert(c rag.Chunk) {
+	id := int32(len(h.nodes))
+	h.nodes = append(h.nodes, hnswNode{chunk: c, norm: norm(c.Vector)})
+	h.byID[c.ID] = id
+	if len(c.Vector) == 0 || (h.dim != 0 && len(c.Vector) != h.dim) {
+		return
+	}
+
+	level := int(-math.Log(1-h.rng.Float64()) * h.levelMult)
+	node := &h.nodes[id]
+	node.links = make([][]int32, level+1)
+	if h.entry < 0 {
+		h.dim = len(c.Vector)
+		h.entry, h.maxLevel = id, level
+		return
+	}
+
+	ep := h.greedy(c.Vector, node.norm, h.entry, h.maxLevel, level+1)
+	eps := []candidate{ep}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		found := h.searchLayer(c.Vector, node.norm, eps, h.cfg.EfConstruction, l)
+		neighbours := h.selectNeighbours(found, h.maxLinks(l))
+		h.nodes[id].links[l] = neighbours
+		for _, n := range neighbours {
+			h.link(n, id, l)
+		}
+		eps = found
+	}
+	if level > h.maxLevel {
+		h.entry, h.maxLevel = id, level
+	}
+}
+
+// link adds a link from node to neighbour on layer l, pruning node's links
+// with the same selection as inserts when it has too many. Dropping just the
+// farthest link is cheaper but cuts clusters off from each other.
+// Callers hold h.mu.
+func (h *HNSW) link(node, neighbour int32, l int) {
+	n := &h.nodes[node]
+	links := append(n.links[l], neighbour)
+	if len(links) > h.maxLinks(l) {
+		cands := make([]candidate, len(links))
+		for i, x := range links {
+			cands[i] = candidate{id: x, score: h.similarity(n.chunk.Vector, n.norm, x)}
+		}
+		slices.SortFunc(cands, func(a, b candidate) int { return cmp.Compare(b.score, a.score) })
+		links = h.selectNeighbours(cands, h.maxLinks(l))
+	}
+	n.links[l] = links
+}
+
+// maxLinks is the maximum number of links per node on layer l.
+func (h *HNSW) maxLinks(l int) int {
+	if l == 0 {
+		return 2 * h.cfg.M
+	}
+	return h.cfg.M
+}
+
+// similarity scores node x against vector q with norm qnorm.
+func (h *HNSW) similarity(q []float32, qnorm float64, x int32) float64 {
+	n := &h.nodes[x]
+	return score(h.metric, q, n.chunk.Vector, qnorm, n.norm)
+}
+
+// greedy walks from ep down to layer stop, moving to the closest neighbour
+// on each layer, and returns the closest node found.
+func (h *HNSW) greedy(q []float32, qnorm float64, ep int32, from, stop int) candidate {
+	cur := candidate{id: ep, score: h.similarity(q, qnorm, ep)}
+	for l := from; l >= stop; l-- {
+		for changed := true; changed; {
+			changed = false
+			for _, n := range h.nodes[cur.id].links[l] {
+				if s := h.similarity(q, qnorm, n); s > cur.score {
+					cur, changed = candidate{id: n, score: s}, true
+				}
+			}
+		}
+	}
+	return cur
+}
+
+// searchLayer returns up to ef nodes closest to q on layer l, best first.
+func (h *HNSW) searchLayer(q []float32, qnorm float64, eps []candidate, ef, l int) []candidate {
+	visited := newVisitedSet(len(h.nodes))
+	defer visitedPool.Put(visited)
+	frontier := &bestFirst{}
+	found := &worstFirst{}
+	for _, ep := range eps {
+		visited.add(ep.id)
+		heap.Push(frontier, ep)
+		heap.Push(found, ep)
+	}
+	for found.Len() > ef {
+		heap.Pop(found)
+	}
+
+	for frontier.Len() > 0 {
+		c := heap.Pop(frontier).(candidate)
+		if found.Len() >= ef && c.score < (*found)[0].score {
+			break
+		}
+		for _, n := range h.nodes[c.id].links[l] {
+			if !visited.add(n) {
+				continue
+			}
+			s := h.similarity(q, qnorm, n)
+			if found.Len() < ef || s > (*found)[0].score {
+				heap.Push(frontier, candidate{id: n, score: s})
+				heap.Push(found, candidate{id: n, score: s})
+				if found.Len() > ef {
+					heap.Pop(found)
+				}
+			}
+		}
+	}
+
+	out := make([]candidate, found.Len())
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(found).(candidate)
+	}
+	return out
+}
+
+// selectNeighbours picks up to m links from cands (sorted best first),
+// preferring candidates that are closer to the new node than to any link
+// already chosen, so that links point in different directions. The rest is
+// filled with the closest remaining candidates.
+func (h *HNSW) selectNeighbours(cands []candidate, m int) []int32 {
+	selected := make([]int32, 0, m)
+	var pruned []int32
+	for _, c := range cands {
+		if len(selected) >= m {
+			break
+		}
+		diverse := true
+		v, vnorm := h.nodes[c.id].chunk.Vector, h.nodes[c.id].norm
+		for _, s := range selected {
+			if h.similarity(v, vnorm, s) > c.score {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, c.id)
+		} else {
+			pruned = append(pruned, c.id)
+		}
+	}
+	for _, p := range pruned {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, p)
+	}
+	return selected
+}
+
+// visitedSet marks the nodes seen by one search. Sets are pooled and cleared
+// by bumping the epoch, so a search does not allocate per node.
+type visitedSet struct {
+	epoch uint32
+	marks []uint32
+}
+
+var visitedPool = sync.Pool{New: func() any { return &visitedSet{} }}
+
+// newVisitedSet returns an empty set for n nodes. Return it to visitedPool.
+func newVisitedSet(n int) *visitedSet {
+	v := visitedPool.Get().(*visitedSet)
+	if len(v.marks) < n {
+		v.marks = make([]uint32, n+n/4)
+		v.epoch = 0
+	}
+	v.epoch++
+	if v.epoch == 0 {
+		clear(v.marks)
+		v.epoch = 1
+	}
+	return v
+}
+
+// add marks id and reports whether it was not marked yet.
+func (v *visitedSet) add(id int32) bool {
+	if v.marks[id] == v.epoch {
+		return false
+	}
+	v.marks[id] = v.epoch
+	return true
+}
+
+// candidate is a node and its similarity to the query.
+type candidate struct {
+	id    int32
+	score float64
+}
+
+// bestFirst is a max-heap of candidates.
+type bestFirst []candidate
+
+func (b bestFirst) Len() int           { return len(b) }
+func (b bestFirst) Less(i, j int) bool { return b[i].score > b[j].score }
+func (b bestFirst) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b *bestFirst) Push(x any)        { *b = append(*b, x.(candidate)) }
+func (b *bestFirst) Pop() any {
+	old := *b
+	last := old[len(old)-1]
+	*b = old[:len(old)-1]
+	return last
+}
+
+// worstFirst is a min-heap of candidates.
+type worstFirst []candidate
+
+func (w worstFirst) Len() int           { return len(w) }
+func (w worstFirst) Less(i, j int) bool { return w[i].score < w[j].score }
+func (w worstFirst) Swap(i, j int)      { w[i], w[j] = w[j], w[i] }
+func (w *worstFirst) Push(x any)        { *w = append(*w, x.(candidate)) }
+func (w *worstFirst) Pop() any {
+	old := *w
+	last := old[len(old)-1]
+	*w = old[:len(old)-1]
+	return last
+}
+
+// hnswMagic starts an HNSW snapshot.
+const hnswMagic = "GRAGHNSW"
+
+// WriteSnapshot implements Snapshotter. The snapshot holds the chunks and
+// the graph, so loading it does not need to insert every chunk again.
+func (h *HNSW) WriteSnapshot(w io.Writer) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	bw := bufio.NewWriterSize(w, 1<<20)
+	var buf []byte
+	buf = append(buf, hnswMagic...)
+	for _, v := range []int{h.cfg.M, int(h.metric), h.dim, int(h.entry), h.maxLevel, len(h.nodes)} {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(v))
+	}
+	if _, err := bw.Write(buf); err != nil {
+		return err
+	}
+	for _, n := range h.nodes {
+		payload, err := encodeChunk(n.chunk)
+		if err != nil {
+			return err
+		}
+		buf = buf[:0]
+		if n.deleted {
+			buf = append(buf, 1)
+		} else {
+			buf = append(buf, 0)
+		}
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(payload)))
+		buf = append(buf, payload...)
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(n.links)))
+		for _, layer := range n.links {
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(len(layer)))
+			for _, x := range layer {
+				buf = binary.LittleEndian.AppendUint32(buf, uint32(x))
+			}
+		}
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadSnapshot implements Snapshotter. It replaces the contents of the
+// index only if the whole snapshot is valid and was built with the same M
+// and metric.
+func (h *HNSW) ReadSnapshot(r io.Reader) error {
+	sr := snapshotReader{r: bufio.NewReaderSize(r, 1<<20)}
+	if string(sr.bytes(len(hnswMagic))) != hnswMagic {
+		return errors.New("not an HNSW snapshot")
+	}
+	m, metric, dim, entry, maxLevel, count := sr.int(), sr.int(), sr.int(), int32(sr.u32()), sr.int(), sr.int()
+	if sr.err != nil {
+		return sr.err
+	}
+	if m != h.cfg.M || Metric(metric) != h.metric {
+		return fmt.Errorf("snapshot was built with M=%d and %s, index uses M=%d and %s", m, Metric(metric), h.cfg.M, h.metric)
+	}
+
+	nodes := make([]hnswNode, 0, count)
+	byID := make(map[string]int32, count)
+	byDoc := make(map[string][]string)
+	deleted := 0
+	for i := 0; i < count && sr.err == nil; i++ {
+		tomb := sr.bytes(1)
+		payload := sr.bytes(sr.int())
+		layers := sr.int()
+		if sr.err != nil {
+			break
+		}
+		if len(payload) == 0 || payload[0] != opUpsert {
+			return errors.New("corrupt HNSW snapshot")
+		}
+		c, err := decodeChunk(payload[1:])
+		if err != nil {
+			return err
+		}
+		n := hnswNode{chunk: c, norm: norm(c.Vector), deleted: tomb[0] == 1}
+		if layers > 0 {
+			n.links = make([][]int32, layers)
+			for l := range n.links {
+				size := sr.int()
+				n.links[l] = make([]int32, 0, size)
+				for j := 0; j < size && sr.err == nil; j++ {
+					x := int32(sr.u32())
+					if x < 0 || int(x) >= count {
+						return errors.New("corrupt HNSW snapshot")
+					}
+					n.links[l] = append(n.links[l], x)
+				}
+			}
+		}
+		if n.deleted {
+			deleted++
+		} else {
+			byID[c.ID] = int32(i)
+			byDoc[c.DocID] = append(byDoc[c.DocID], c.ID)
+		}
+		nodes = append(nodes, n)
+	}
+	if sr.err != nil {
+		return fmt.Errorf("read HNSW snapshot: %w", sr.err)
+	}
+	if entry >= int32(count) || (entry >= 0 && len(nodes[entry].links) <= maxLevel) {
+		return errors.New("corrupt HNSW snapshot")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.nodes, h.byID, h.byDoc, h.deleted = nodes, byID, byDoc, deleted
+	h.dim, h.entry, h.maxLevel = dim, entry, maxLevel
+	return nil
+}
+
+// snapshotReader reads little-endian values and remembers the first error.
+type snapshotReader struct {
+	r   io.Reader
+	err error
+}
+
+func (s *snapshotReader) bytes(n int) []byte {
+	if s.err != nil {
+		return nil
+	}
+	if n < 0 || n > 1<<30 {
+		s.err = errors.New("invalid length")
+		return nil
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(s.r, b); err != nil {
+		s.err = err
+		return nil
+	}
+	return b
+}
+
+func (s *snapshotReader) u32() uint32 {
+	b := s.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (s *snapshotReader) int() int { return int(s.u32()) }
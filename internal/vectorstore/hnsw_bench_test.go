@@ -0,0 +1,136 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"go-groq/internal/rag"
+)
+
+// clustered returns n chunks and the given number of queries with random
+// vectors around shared centers, as embeddings cluster by topic.
+func clustered(n, dim, queries int) ([]rag.Chunk, [][]float32) {
+	rng := rand.New(rand.NewSource(7))
+	centers := make([][]float32, max(n/200, 1))
+	for i := range centers {
+		centers[i] = randomVector(rng, nil, dim, 1)
+	}
+	chunks := make([]rag.Chunk, n)
+	for i := range chunks {
+		chunks[i] = rag.Chunk{
+			ID:     fmt.Sprintf("bench#%d", i),
+			DocID:  fmt.Sprintf("bench-%d", i/10),
+			Vector: randomVector(rng, centers[rng.Intn(len(centers))], dim, 0.4),
+		}
+	}
+	qs := make([][]float32, queries)
+	for i := range qs {
+		qs[i] = randomVector(rng, centers[rng.Intn(len(centers))], dim, 0.4)
+	}
+	return chunks, qs
+}
+
+// randomVector returns center plus Gaussian noise with the given spread.
+func randomVector(rng *rand.Rand, center []float32, dim int, spread float64) []float32 {
+	v := make([]float32, dim)
+	for i := range v {
+		v[i] = float32(rng.NormFloat64() * spread)
+		if center != nil {
+			v[i] += center[i]
+		}
+	}
+	return v
+}
+
+// loaded returns an exact and an HNSW index holding the same chunks.
+func loaded(tb testing.TB, chunks []rag.Chunk) (*Memory, *HNSW) {
+	tb.Helper()
+	ctx := context.Background()
+	exact := NewMemory(Cosine)
+	hnsw := NewHNSW(Cosine, DefaultHNSWConfig())
+	if err := exact.Upsert(ctx, chunks); err != nil {
+		tb.Fatal(err)
+	}
+	if err := hnsw.Upsert(ctx, chunks); err != nil {
+		tb.Fatal(err)
+	}
+	return exact, hnsw
+}
+
+// recall returns the share of the exact top k of every query that the
+// approximate store also returns. Both stores must hold the same chunks.
+func recall(ctx context.Context, approx, exact VectorStore, queries [][]float32, k int) (float64, error) {
+	var found, wanted int
+	for _, q := range queries {
+		want, err := exact.Search(ctx, q, SearchOptions{K: k})
+		if err != nil {
+			return 0, err
+		}
+		got, err := approx.Search(ctx, q, SearchOptions{K: k})
+		if err != nil {
+			return 0, err
+		}
+		ids := make(map[string]bool, len(got))
+		for _, r := range got {
+			ids[r.Chunk.ID] = true
+		}
+		for _, r := range want {
+			if ids[r.Chunk.ID] {
+				found++
+			}
+		}
+		wanted += len(want)
+	}
+	if wanted == 0 {
+		return 0, nil
+	}
+	return float64(found) / float64(wanted), nil
+}
+
+func TestHNSWRecall(t *testing.T) {
+	chunks, qs := clustered(5000, 64, 100)
+	exact, hnsw := loaded(t, chunks)
+	r, err := recall(context.Background(), hnsw, exact, qs, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r < 0.9 {
+		t.Errorf("recall@10 = %.3f, want at least 0.9", r)
+	}
+}
+
+// BenchmarkSearch compares the query latency of exact search with HNSW at
+// several efSearch values, and reports the recall of HNSW against exact
+// search. Run it with: go test -bench Search ./internal/vectorstore
+func BenchmarkSearch(b *testing.B) {
+	const k = 10
+	chunks, qs := clustered(20000, 384, 200)
+	exact, hnsw := loaded(b, chunks)
+	ctx := context.Background()
+
+	b.Run("exact", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := exact.Search(ctx, qs[i%len(qs)], SearchOptions{K: k}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	for _, ef := range []int{32, 64, 128, 256} {
+		b.Run(fmt.Sprintf("hnsw/ef=%d", ef), func(b *testing.B) {
+			hnsw.SetEfSearch(ef)
+			r, err := recall(ctx, hnsw, exact, qs, k)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := hnsw.Search(ctx, qs[i%len(qs)], SearchOptions{K: k}); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(r, "recall")
+		})
+	}
+}
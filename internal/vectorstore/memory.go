@@ -0,0 +1,164 @@
+package vectorstore
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"sync"
+
+	"go-groq/internal/rag"
+)
+
+// parallelThreshold is the number of chunks above which Memory splits a
+// search across goroutines.
+const parallelThreshold = 4096
+
+// Memory is an in-memory VectorStore with exact search. Searches hold a read
+// lock, so they run concurrently with each other and only wait for writes.
+type Memory struct {
+	metric Metric
+
+	mu     sync.RWMutex
+	chunks []rag.Chunk
+	norms  []float64
+	byID   map[string]int      // chunk ID -> index in chunks
+	byDoc  map[string][]string // doc ID -> chunk IDs
+}
+
+// NewMemory creates an empty in-memory store.
+func NewMemory(metric Metric) *Memory {
+	return &Memory{
+		metric: metric,
+		byID:   make(map[string]int),
+		byDoc:  make(map[string][]string),
+	}
+}
+
+// Upsert implements VectorStore.
+func (m *Memory) Upsert(ctx context.Context, chunks []rag.Chunk) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range chunks {
+		if i, ok := m.byID[c.ID]; ok {
+			if old := m.chunks[i].DocID; old != c.DocID {
+				moveChunk(m.byDoc, c.ID, old, c.DocID)
+			}
+			m.chunks[i] = c
+			m.norms[i] = norm(c.Vector)
+			continue
+		}
+		m.byID[c.ID] = len(m.chunks)
+		m.byDoc[c.DocID] = append(m.byDoc[c.DocID], c.ID)
+		m.chunks = append(m.chunks, c)
+		m.norms = append(m.norms, norm(c.Vector))
+	}
+	return nil
+}
+
+// moveChunk moves a chunk ID from the chunk list of one document to that of
+// another, for a chunk upserted again under a different document.
+func moveChunk(byDoc map[string][]string, id, from, to string) {
+	ids := byDoc[from]
+	if i := slices.Index(ids, id); i >= 0 {
+		ids = slices.Delete(ids, i, i+1)
+	}
+	if len(ids) == 0 {
+		delete(byDoc, from)
+	} else {
+		byDoc[from] = ids
+	}
+	byDoc[to] = append(byDoc[to], id)
+}
+
+// DeleteDoc implements VectorStore.
+func (m *Memory) DeleteDoc(ctx context.Context, docID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range m.byDoc[docID] {
+		i, ok := m.byID[id]
+		if !ok {
+			continue
+		}
+		// Move the last chunk into the hole to keep the slice dense.
+		last := len(m.chunks) - 1
+		m.chunks[i], m.norms[i] = m.chunks[last], m.norms[last]
+		m.byID[m.chunks[i].ID] = i
+		m.chunks, m.norms = m.chunks[:last], m.norms[:last]
+		delete(m.byID, id)
+	}
+	delete(m.byDoc, docID)
+	return nil
+}
+
+// Search implements VectorStore.
+func (m *Memory) Search(ctx context.Context, vector []float32, opts SearchOptions) ([]Result, error) {
+	if opts.K <= 0 || len(vector) == 0 {
+		return nil, nil
+	}
+	queryNorm := norm(vector)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	workers := 1
+	if len(m.chunks) > parallelThreshold {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	size := (len(m.chunks) + workers - 1) / workers
+	partials := make([]*topK, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		start, end := w*size, min((w+1)*size, len(m.chunks))
+		partials[w] = newTopK(opts.K)
+		if start >= end {
+			continue
+		}
+		wg.Add(1)
+		go func(best *topK, start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				if i%1024 == 0 && ctx.Err() != nil {
+					return
+				}
+				c := m.chunks[i]
+				if len(c.Vector) != len(vector) || (opts.Filter != nil && !opts.Filter(c.Metadata)) {
+					continue
+				}
+				best.offer(Result{Chunk: c, Score: score(m.metric, vector, c.Vector, queryNorm, m.norms[i])})
+			}
+		}(partials[w], start, end)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	merged := newTopK(opts.K)
+	for _, p := range partials {
+		for _, r := range p.results {
+			merged.offer(r)
+		}
+	}
+	return merged.sorted(), nil
+}
+
+// Count implements VectorStore.
+func (m *Memory) Count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.chunks)
+}
+
+// Scan implements Scanner. It iterates over a snapshot, so fn may use the store.
+func (m *Memory) Scan(fn func(rag.Chunk) bool) error {
+	m.mu.RLock()
+	chunks := make([]rag.Chunk, len(m.chunks))
+	copy(chunks, m.chunks)
+	m.mu.RUnlock()
+	for _, c := range chunks {
+		if !fn(c) {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+// Package vectorstore stores embedded chunks and finds the ones nearest to a
+// query vector.
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"go-groq/internal/rag"
+)
+
+// Metric is the similarity function used for search. Higher scores are closer.
+type Metric int
+
+const (
+	Cosine Metric = iota
+	Dot
+)
+
+// ParseMetric parses "cosine" or "dot".
+func ParseMetric(s string) (Metric, error) {
+	switch strings.ToLower(s) {
+	case "", "cosine":
+		return Cosine, nil
+	case "dot":
+		return Dot, nil
+	default:
+		return Cosine, fmt.Errorf("unknown similarity metric %q (supported: cosine, dot)", s)
+	}
+}
+
+func (m Metric) String() string {
+	if m == Dot {
+		return "dot"
+	}
+	return "cosine"
+}
+
+// Filter reports whether a chunk with the given metadata may be returned.
+type Filter func(meta map[string]string) bool
+
+// MatchAll returns a Filter that requires every key in kv to have exactly
+// the given value.
+func MatchAll(kv map[string]string) Filter {
+	return func(meta map[string]string) bool {
+		for k, v := range kv {
+			if meta[k] != v {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// And returns a Filter that passes chunks every non-nil filter passes. It
+// returns nil when there are none.
+func And(filters ...Filter) Filter {
+	var fs []Filter
+	for _, f := range filters {
+		if f != nil {
+			fs = append(fs, f)
+		}
+	}
+	switch len(fs) {
+	case 0:
+		return nil
+	case 1:
+		return fs[0]
+	}
+	return func(meta map[string]string) bool {
+		for _, f := range fs {
+			if !f(meta) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// SearchOptions controls a search.
+type SearchOptions struct {
+	K      int    // number of results to return
+	Filter Filter // optional metadata filter
+}
+
+// Result is a chunk returned by a search with its similarity score.
+type Result struct {
+	Chunk rag.Chunk
+	Score float64
+}
+
+// VectorStore stores chunks and their vectors. Implementations are safe for
+// concurrent use: searches may run while documents are being ingested.
+type VectorStore interface {
+	// Upsert adds chunks, replacing any chunk with the same ID.
+	Upsert(ctx context.Context, chunks []rag.Chunk) error
+	// DeleteDoc removes every chunk of a document.
+	DeleteDoc(ctx context.Context, docID string) error
+	// Search returns the K chunks most similar to vector that pass the filter,
+	// best first. Chunks without a vector of the same dimension are ignored.
+	Search(ctx context.Context, vector []float32, opts SearchOptions) ([]Result, error)
+	// Count returns the number of chunks stored.
+	Count() int
+}
+
+// Scanner is implemented by stores that can list the chunks they hold.
+type Scanner interface {
+	// Scan calls fn for every chunk until fn returns false.
+	Scan(fn func(rag.Chunk) bool) error
+}
+
+// Snapshotter is implemented by indexes that can save their state, so that
+// a Disk store can load it instead of replaying the whole log.
+type Snapshotter interface {
+	WriteSnapshot(w io.Writer) error
+	// ReadSnapshot replaces the index contents, leaving them unchanged on error.
+	ReadSnapshot(r io.Reader) error
+}
+
+// Index is an in-memory VectorStore that a Disk store can rebuild and compact.
+type Index interface {
+	VectorStore
+	Scanner
+}
+
+// score computes the similarity of a and b under metric. normA and normB are
+// the vectors' Euclidean norms and are only used for cosine.
+func score(metric Metric, a, b []float32, normA, normB float64) float64 {
+	dot := dotProduct(a, b)
+	if metric == Dot {
+		return dot
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (normA * normB)
+}
+
+// dotProduct returns the dot product of two vectors of the same length. It
+// keeps four partial sums, which lets the compiler pipeline the loop.
+func dotProduct(a, b []float32) float64 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+	return float64(s0 + s1 + s2 + s3)
+}
+
+// norm returns the Euclidean norm of v.
+func norm(v []float32) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	return math.Sqrt(sum)
+}
@@ -0,0 +1,48 @@
+package vectorstore
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// topK keeps the k best results seen so far in a min-heap.
+type topK struct {
+	k       int
+	results []Result
+}
+
+func newTopK(k int) *topK {
+	return &topK{k: k, results: make([]Result, 0, k)}
+}
+
+func (t *topK) Len() int           { return len(t.results) }
+func (t *topK) Less(i, j int) bool { return t.results[i].Score < t.results[j].Score }
+func (t *topK) Swap(i, j int)      { t.results[i], t.results[j] = t.results[j], t.results[i] }
+func (t *topK) Push(x any)         { t.results = append(t.results, x.(Result)) }
+func (t *topK) Pop() any {
+	last := t.results[len(t.results)-1]
+	t.results = t.results[:len(t.results)-1]
+	return last
+}
+
+// offer adds r if it is among the k best so far.
+func (t *topK) offer(r Result) {
+	if t.k <= 0 {
+		return
+	}
+	if len(t.results) < t.k {
+		heap.Push(t, r)
+		return
+	}
+	if r.Score > t.results[0].Score {
+		t.results[0] = r
+		heap.Fix(t, 0)
+	}
+}
+
+// sorted returns the kept results, best first.
+func (t *topK) sorted() []Result {
+	out := append([]Result(nil), t.results...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return out
+}
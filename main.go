@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"log"
+	"os"
+	"os/signal"
 )
 
 func main() {
@@ -17,8 +19,48 @@ func main() {
 	// Initialize chatbot with conversation memory
 	chatBot := NewChatBot(config)
 
+	// Optional subcommand: "ingest <path>..." (or "ingest-repo <path>" for a git working
+	// tree, "ingest-url <url>" for a web site) loads documents into the index. With a
+	// persistent index it exits afterwards; an in-memory index is only useful to the chat
+	// that follows
+	if len(os.Args) > 1 {
+		var run func(context.Context, *ChatBot, []string) error
+		switch os.Args[1] {
+		case "ingest":
+			run = runIngestCommand
+		case "ingest-repo":
+			run = runRepoCommand
+		case "ingest-url":
+			run = runURLCommand
+		default:
+			log.Fatalf("Unknown command: %s (supported: ingest, ingest-repo, ingest-url)", os.Args[1])
+		}
+		if runIngest(ctx, chatBot, config, run) {
+			return
+		}
+	}
+
 	// Run interactive chat
-	if err := chatBot.RunInteractive(ctx); err != nil {
+	err = chatBot.RunInteractive(ctx)
+	chatBot.Close()
+	if err != nil {
 		log.Fatalf("Chat error: %v", err)
 	}
 }
+
+// runIngest runs an ingest subcommand with the remaining arguments, cancelled by Ctrl+C.
+// It reports whether the program is done, which it is with a persistent index
+func runIngest(ctx context.Context, chatBot *ChatBot, config *Config, run func(context.Context, *ChatBot, []string) error) bool {
+	ingestCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	err := run(ingestCtx, chatBot, os.Args[2:])
+	stop()
+	if err != nil {
+		chatBot.Close()
+		log.Fatalf("Ingest error: %v", err)
+	}
+	if config.IndexPersist {
+		chatBot.Close()
+		return true
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+
+	"go-groq/internal/llm"
+	"go-groq/internal/redact"
+)
+
+// newMiddlewareBuilder registers every middleware stage that can be named in
+// LLM_MIDDLEWARE. Stages that are disabled in config return a nil middleware.
+func (cb *ChatBot) newMiddlewareBuilder() *llm.Builder {
+	cfg := cb.config
+	return llm.NewBuilder().
+		Register("redact", func(provider, model string) (llm.Middleware, error) {
+			if !cfg.RedactEnabled {
+				return nil, nil
+			}
+			redactor, err := cb.redactor()
+			if err != nil {
+				return nil, err
+			}
+			return redactMiddleware(redactor), nil
+		}).
+		Register("logging", func(provider, model string) (llm.Middleware, error) {
+			return llm.Logging(cb.logger(), provider, model), nil
+		}).
+		Register("metrics", func(provider, model string) (llm.Middleware, error) {
+			return cb.metrics.Middleware(provider, model), nil
+		}).
+		Register("retry", func(provider, model string) (llm.Middleware, error) {
+			if cfg.RetryAttempts <= 1 {
+				return nil, nil
+			}
+			return llm.Retry(cfg.RetryAttempts, cfg.RetryBackoff), nil
+		}).
+		Register("cache", func(provider, model string) (llm.Middleware, error) {
+			if !cfg.CacheEnabled {
+				return nil, nil
+			}
+			return llm.Cache(provider, model, llm.CacheOptions{
+				Dir:                 cfg.CacheDir,
+				TTL:                 cfg.CacheTTL,
+				MaxBytes:            cfg.CacheMaxBytes,
+				Semantic:            cfg.CacheSemantic,
+				SimilarityThreshold: cfg.CacheSemanticThreshold,
+			}, cb.embedder), nil
+		}).
+		Register("ratelimit", func(provider, model string) (llm.Middleware, error) {
+			if cfg.RateLimitRPS <= 0 {
+				return nil, nil
+			}
+			return llm.RateLimit(cfg.RateLimitRPS, cfg.RateLimitBurst), nil
+		})
+}
+
+// redactor returns the session-wide Redactor, building it from config on first use.
+// Sharing one Redactor keeps placeholders stable across requests and provider switches.
+func (cb *ChatBot) redactor() (*redact.Redactor, error) {
+	if cb.redact != nil {
+		return cb.redact, nil
+	}
+	rules, err := redact.BuiltinRules(cb.config.RedactRules)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(cb.config.RedactPatterns))
+	for name := range cb.config.RedactPatterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var custom []redact.Rule
+	for _, name := range names {
+		rule, err := redact.CustomRule(name, cb.config.RedactPatterns[name])
+		if err != nil {
+			return nil, err
+		}
+		custom = append(custom, rule)
+	}
+	// Custom rules run first so they win over the broader built-in patterns
+	cb.redact = redact.New(append(custom, rules...))
+	return cb.redact, nil
+}
+
+// redactMiddleware replaces sensitive values in every outgoing message with
+// placeholders and restores them in the response.
+func redactMiddleware(r *redact.Redactor) llm.Middleware {
+	return llm.Hooks(
+		func(ctx context.Context, messages []llm.Message) ([]llm.Message, error) {
+			redacted := make([]llm.Message, len(messages))
+			for i, m := range messages {
+				content, _ := r.Redact(m.Content)
+				redacted[i] = llm.Message{Role: m.Role, Content: content}
+			}
+			return redacted, nil
+		},
+		func(ctx context.Context, messages []llm.Message, response string) (string, error) {
+			return r.Restore(response), nil
+		},
+	)
+}
+
+// redactingEmbedder redacts texts before they are sent to an embedding provider, so that
+// questions and ingested documents leave the machine with the same placeholders as chat
+// messages do
+type redactingEmbedder struct {
+	llm.EmbeddingClient
+	r *redact.Redactor
+}
+
+func (e redactingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	redacted := make([]string, len(texts))
+	for i, text := range texts {
+		redacted[i], _ = e.r.Redact(text)
+	}
+	return e.EmbeddingClient.Embed(ctx, redacted)
+}
+
+// logger returns the file logger shared by logging middleware and guardrails. The log
+// file is only created when the first line is written, so a session that logs nothing
+// leaves no file behind. If it cannot be opened, logging is silently discarded.
+func (cb *ChatBot) logger() *log.Logger {
+	cb.logOnce.Do(func() {
+		cb.log = log.New(&lazyFile{path: cb.config.LogFile}, "", log.LstdFlags)
+	})
+	return cb.log
+}
+
+// lazyFile is a writer that opens the file at path for appending on the first write
+type lazyFile struct {
+	path string
+	once sync.Once
+	w    io.Writer
+}
+
+func (f *lazyFile) Write(p []byte) (int, error) {
+	f.once.Do(func() {
+		f.w = io.Discard
+		if file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644); err == nil {
+			f.w = file
+		}
+	})
+	return f.w.Write(p)
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go-groq/internal/ingest"
+
+	"github.com/fatih/color"
+)
+
+// repoStateFile keeps the last indexed commit of every repository next to a persistent index
+const repoStateFile = "repos.json"
+
+// repoIngest is the outcome of IngestRepo
+type repoIngest struct {
+	repo    *ingest.Repo
+	scan    ingest.RepoScan
+	summary ingest.Summary
+	since   string // commit the changes were taken from; "" for a full scan
+}
+
+// IngestRepo loads the files of the git working tree at path into collection, which
+// defaults to the repository name. It honors .gitignore and REPO_IGNORE_FILE, skips
+// vendored, generated and binary files, and tags every chunk with the repository,
+// commit, branch and path relative to the root. With changedOnly, only the files that
+// changed since the last indexed commit are checked
+func (cb *ChatBot) IngestRepo(ctx context.Context, path, collection string, changedOnly, force bool) (repoIngest, error) {
+	repo, err := ingest.OpenRepo(path)
+	if err != nil {
+		return repoIngest{}, err
+	}
+	result := repoIngest{repo: repo}
+	if collection == "" {
+		collection = repo.Name()
+	}
+
+	var only map[string]bool
+	if changedOnly {
+		since := cb.lastIndexedCommit(repo.Root, collection)
+		if since == "" {
+			color.New(color.FgYellow).Printf("⚠️  %s was not indexed into %s before; scanning every file\n", repo.Name(), collection)
+		} else if changed, err := repo.ChangedSince(ctx, since); err != nil {
+			color.New(color.FgYellow).Printf("⚠️  Cannot list changes since %s (%v); scanning every file\n", shortSHA(since), err)
+		} else {
+			only = make(map[string]bool, len(changed))
+			for _, f := range changed {
+				only[f] = true
+			}
+			result.since = since
+		}
+	}
+
+	result.scan, err = repo.Scan(path, cb.config.RepoIgnoreFile, only)
+	if err != nil {
+		return result, err
+	}
+	pipeline, err := cb.repoPipeline(repo, collection, force, only == nil)
+	if err != nil {
+		return result, err
+	}
+	result.summary, err = pipeline.RunFiles(ctx, path, result.scan.Files, printIngestProgress)
+	fmt.Print("\r\033[K") // Clear the progress line
+	if err != nil {
+		return result, err
+	}
+	if result.summary.Failed == 0 && repo.Commit != "" {
+		if err := cb.setLastIndexedCommit(repo.Root, collection, repo.Commit); err != nil {
+			cb.logger().Printf("ingest-repo: %v", err)
+		}
+	}
+	return result, nil
+}
+
+// repoPipeline is pipeline with the metadata of repo attached to every chunk. With
+// prune, documents under the scanned path that the scan did not return are removed,
+// so files that became ignored leave the index
+func (cb *ChatBot) repoPipeline(repo *ingest.Repo, collection string, force, prune bool) (*ingest.Pipeline, error) {
+	opts, err := cb.ingestOptions(collection)
+	if err != nil {
+		return nil, err
+	}
+	opts.Manifest = cb.manifest()
+	opts.Force = force
+	opts.Prune = prune
+	opts.Metadata = repo.Metadata()
+	opts.RelativeTo = repo.Root
+	sink := ingest.MultiSink(cb.store, cb.keywordIndex())
+	return ingest.New(cb.embedder, sink, opts), nil
+}
+
+// repoStateKey identifies a repository indexed into a collection
+func repoStateKey(root, collection string) string {
+	return collection + "\x00" + root
+}
+
+// repoStatePath is where the last indexed commits are kept; "" when the index is in memory
+func (cb *ChatBot) repoStatePath() string {
+	if !cb.config.IndexPersist {
+		return ""
+	}
+	return filepath.Join(cb.config.IndexDir, repoStateFile)
+}
+
+// loadRepoState reads the last indexed commits on first use. Callers hold cb.mu
+func (cb *ChatBot) loadRepoState() {
+	if cb.repoCommits != nil {
+		return
+	}
+	cb.repoCommits = make(map[string]string)
+	path := cb.repoStatePath()
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	var entries []repoStateEntry
+	if err == nil {
+		err = json.Unmarshal(data, &entries)
+	}
+	if err != nil {
+		cb.logger().Printf("ingest-repo: read %s: %v", path, err)
+		return
+	}
+	for _, e := range entries {
+		cb.repoCommits[repoStateKey(e.Root, e.Collection)] = e.Commit
+	}
+}
+
+// repoStateEntry is one record of repos.json
+type repoStateEntry struct {
+	Root       string    `json:"root"`
+	Collection string    `json:"collection"`
+	Commit     string    `json:"commit"`
+	IndexedAt  time.Time `json:"indexed_at"`
+}
+
+// lastIndexedCommit returns the commit root was last indexed at into collection, or ""
+func (cb *ChatBot) lastIndexedCommit(root, collection string) string {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.loadRepoState()
+	return cb.repoCommits[repoStateKey(root, collection)]
+}
+
+// setLastIndexedCommit records that root was indexed at commit into collection, and
+// saves the record when the index is persistent
+func (cb *ChatBot) setLastIndexedCommit(root, collection, commit string) error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.loadRepoState()
+	cb.repoCommits[repoStateKey(root, collection)] = commit
+	path := cb.repoStatePath()
+	if path == "" {
+		return nil
+	}
+
+	entries := make([]repoStateEntry, 0, len(cb.repoCommits))
+	now := time.Now().UTC()
+	for key, c := range cb.repoCommits {
+		coll, r, _ := strings.Cut(key, "\x00")
+		entries = append(entries, repoStateEntry{Root: r, Collection: coll, Commit: c, IndexedAt: now})
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// shortSHA abbreviates a commit for display
+func shortSHA(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+// printRepoIngest prints what repository ingestion picked up and left out
+func (cb *ChatBot) printRepoIngest(r repoIngest) {
+	gray := color.New(color.FgHiBlack)
+	commit := shortSHA(r.repo.Commit)
+	if commit == "" {
+		commit = "no commits"
+	}
+	if r.repo.Branch != "" {
+		commit = r.repo.Branch + " @ " + commit
+	}
+	gray.Printf("   %s: %s\n", r.repo.Name(), commit)
+	if r.since != "" {
+		gray.Printf("   %d changed files since %s\n", len(r.scan.Files), shortSHA(r.since))
+	}
+	s := r.scan
+	if s.Ignored+s.Vendored+s.Generated+s.Binary > 0 {
+		gray.Printf("   Left out: %d ignored, %d vendored dirs, %d generated, %d binary\n", s.Ignored, s.Vendored, s.Generated, s.Binary)
+	}
+	cb.printIngestSummary(r.summary)
+}
+
+// repoArgs are the arguments of /ingest-repo and the ingest-repo subcommand
+type repoArgs struct {
+	collection string // "" uses the repository name
+	path       string
+	changed    bool // only files changed since the last indexed commit
+	force      bool
+}
+
+// parseRepoArgs parses "[--collection <name>] [--changed] [--force] <path>"
+func parseRepoArgs(args []string) (repoArgs, error) {
+	var parsed repoArgs
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--collection" || arg == "-c":
+			if i+1 >= len(args) {
+				return parsed, fmt.Errorf("%s needs a value", arg)
+			}
+			i++
+			parsed.collection = args[i]
+		case strings.HasPrefix(arg, "--collection="):
+			parsed.collection = strings.TrimPrefix(arg, "--collection=")
+		case arg == "--changed":
+			parsed.changed = true
+		case arg == "--force" || arg == "-f":
+			parsed.force = true
+		case strings.HasPrefix(arg, "-"):
+			return parsed, fmt.Errorf("unknown flag %s", arg)
+		case parsed.path != "":
+			return parsed, fmt.Errorf("only one repository path is allowed")
+		default:
+			parsed.path = arg
+		}
+	}
+	if parsed.path == "" {
+		return parsed, fmt.Errorf("no path given")
+	}
+	return parsed, nil
+}
+
+// runRepoCommand implements the "ingest-repo [--collection <name>] [--changed] [--force] <path>"
+// subcommand
+func runRepoCommand(ctx context.Context, cb *ChatBot, args []string) error {
+	parsed, err := parseRepoArgs(args)
+	if err != nil {
+		return fmt.Errorf("%v\nusage: %s ingest-repo [--collection <name>] [--changed] [--force] <path>", err, os.Args[0])
+	}
+	color.New(color.FgCyan).Printf("📥 Ingesting repository %s\n", parsed.path)
+	result, err := cb.IngestRepo(ctx, parsed.path, parsed.collection, parsed.changed, parsed.force)
+	if err != nil {
+		return fmt.Errorf("ingest %s: %w", parsed.path, err)
+	}
+	cb.printRepoIngest(result)
+	return nil
+}
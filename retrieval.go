@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"go-groq/internal/llm"
+	"go-groq/internal/rag"
+	"go-groq/internal/retrieve"
+	"go-groq/internal/vectorstore"
+
+	"github.com/fatih/color"
+)
+
+// keywordIndex returns the BM25 index, building it from the vector store on first use
+func (cb *ChatBot) keywordIndex() *retrieve.BM25 {
+	cb.keywordsOnce.Do(func() {
+		cb.keywords = retrieve.NewBM25()
+		scanner, ok := cb.store.(vectorstore.Scanner)
+		if !ok {
+			return
+		}
+		var batch []rag.Chunk
+		err := scanner.Scan(func(c rag.Chunk) bool {
+			batch = append(batch, c)
+			return true
+		})
+		if err == nil {
+			err = cb.keywords.Upsert(context.Background(), batch)
+		}
+		if err != nil {
+			cb.logger().Printf("keyword index: %v", err)
+		}
+	})
+	return cb.keywords
+}
+
+// retriever returns the first-stage retriever configured by RETRIEVAL_MODE and the fusion weights
+func (cb *ChatBot) retriever() (*retrieve.Hybrid, error) {
+	mode, err := retrieve.ParseMode(cb.config.RetrievalMode)
+	if err != nil {
+		return nil, err
+	}
+	return &retrieve.Hybrid{
+		Store:         cb.store,
+		Keywords:      cb.keywordIndex(),
+		Embedder:      cb.embedder,
+		Mode:          mode,
+		VectorWeight:  cb.config.HybridVectorWeight,
+		KeywordWeight: cb.config.HybridKeywordWeight,
+		RRFK:          cb.config.RRFK,
+	}, nil
+}
+
+// expansionGroup is a set of collections searched with the same query expansion
+type expansionGroup struct {
+	mode   retrieve.Expansion
+	filter vectorstore.Filter // nil means every collection
+}
+
+// expansionGroups splits the collections by query expansion. A /retrieval override applies
+// to everything; otherwise collections whose COLLECTIONS_FILE entry sets another mode than
+// RETRIEVAL_EXPANSION get a group of their own
+func (cb *ChatBot) expansionGroups() ([]expansionGroup, error) {
+	cb.mu.RLock()
+	override := cb.expansion
+	cb.mu.RUnlock()
+	if override != "" {
+		mode, err := retrieve.ParseExpansion(override)
+		return []expansionGroup{{mode: mode}}, err
+	}
+	def, err := retrieve.ParseExpansion(cb.config.RetrievalExpansion)
+	if err != nil {
+		return nil, err
+	}
+
+	byMode := make(map[retrieve.Expansion]map[string]bool)
+	special := make(map[string]bool)
+	for name, coll := range cb.config.Collections {
+		mode, err := retrieve.ParseExpansion(coll.Retrieval)
+		if err != nil || coll.Retrieval == "" || mode == def {
+			continue
+		}
+		if byMode[mode] == nil {
+			byMode[mode] = make(map[string]bool)
+		}
+		byMode[mode][name] = true
+		special[name] = true
+	}
+	if len(byMode) == 0 {
+		return []expansionGroup{{mode: def}}, nil
+	}
+
+	groups := []expansionGroup{{mode: def, filter: func(meta map[string]string) bool {
+		return !special[meta[rag.MetaCollection]]
+	}}}
+	for mode, names := range byMode {
+		names := names
+		groups = append(groups, expansionGroup{mode: mode, filter: func(meta map[string]string) bool {
+			return names[meta[rag.MetaCollection]]
+		}})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].mode < groups[j].mode })
+	return groups, nil
+}
+
+// newReranker creates the rerank stage selected by RERANK, or returns nil when reranking is off.
+// The LLM rerankers use RERANK_PROVIDER and RERANK_MODEL, defaulting to the rewrite client
+func (cb *ChatBot) newReranker() (*retrieve.RerankStage, error) {
+	mode, err := retrieve.ParseRerankMode(cb.config.Rerank)
+	if err != nil {
+		return nil, err
+	}
+	var reranker retrieve.Reranker
+	switch mode {
+	case retrieve.RerankNone:
+		return nil, nil
+	case retrieve.RerankEndpoint:
+		if cb.config.RerankURL == "" {
+			return nil, fmt.Errorf("RERANK=endpoint requires RERANK_URL")
+		}
+		reranker = &retrieve.EndpointReranker{
+			URL:    cb.config.RerankURL,
+			APIKey: cb.config.RerankAPIKey,
+			Model:  cb.config.RerankModel,
+			Client: &http.Client{Timeout: 60 * time.Second},
+		}
+	default:
+		client, err := cb.newOverrideClient(cb.config.RerankProvider, cb.config.RerankModel)
+		if err != nil {
+			return nil, err
+		}
+		if client == nil {
+			// Resolved per call, so that reranking follows /model
+			client = llm.ClientFunc(func(ctx context.Context, messages []llm.Message) (string, error) {
+				return cb.queryClient().Generate(ctx, messages)
+			})
+		}
+		if mode == retrieve.RerankPointwise {
+			reranker = &retrieve.PointwiseReranker{Client: client}
+		} else {
+			reranker = &retrieve.ListwiseReranker{Client: client}
+		}
+	}
+	return &retrieve.RerankStage{
+		Reranker:   reranker,
+		Candidates: cb.config.RerankCandidates,
+		Budget:     cb.config.RerankBudget,
+	}, nil
+}
+
+// Search returns the k knowledge base chunks matching filter that are most relevant to query,
+// expanding the query as configured for each collection and reranking the candidates when
+// RERANK is set. filter may be empty. Generated queries are recorded in the QueryInfo of ctx
+func (cb *ChatBot) Search(ctx context.Context, query string, k int, filter retrieve.FilterExpr) ([]retrieve.Result, error) {
+	base, err := cb.retriever()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := cb.expansionGroups()
+	if err != nil {
+		return nil, err
+	}
+	info := queryInfo(ctx)
+	depth := k
+	if cb.reranker != nil {
+		depth = cb.reranker.Depth(k)
+	}
+	lists := make([][]retrieve.Result, 0, len(groups))
+	for _, g := range groups {
+		mode := g.mode
+		r := &retrieve.Expanding{
+			Base:    base,
+			Client:  cb.queryClient(),
+			Mode:    mode,
+			Queries: cb.config.MultiQueryCount,
+			OnExpand: func(s string) {
+				info.Expansions = append(info.Expansions, mode.String()+": "+snippet(s, 160))
+			},
+			OnError: func(err error) {
+				cb.logger().Printf("query expansion: %v", err)
+				info.Warnings = append(info.Warnings, fmt.Sprintf("query expansion failed, searching without it: %v", err))
+			},
+		}
+		results, err := r.Retrieve(ctx, query, retrieve.Options{K: depth, Filter: vectorstore.And(g.filter, filter.Filter())})
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, results)
+	}
+	results := lists[0]
+	if len(lists) > 1 {
+		results = retrieve.Fuse(lists, cb.config.RRFK, depth)
+	}
+	if cb.reranker == nil {
+		return results, nil
+	}
+
+	start := time.Now()
+	reranked, err := cb.reranker.Rerank(ctx, query, results, k)
+	if reranked == nil && err != nil {
+		return nil, err
+	}
+	if err != nil {
+		cb.logger().Printf("rerank: %v", err)
+		info.Warnings = append(info.Warnings, fmt.Sprintf("reranking failed, using the first-stage order: %v", err))
+		return reranked, nil
+	}
+	info.Rerank = fmt.Sprintf("%s: %d candidates in %s", cb.config.Rerank, len(results), time.Since(start).Round(time.Millisecond))
+	return reranked, nil
+}
+
+// SetExpansion sets the query expansion for the rest of the session; "auto" returns to the
+// configured per-collection modes
+func (cb *ChatBot) SetExpansion(mode string) error {
+	if strings.ToLower(mode) == "auto" {
+		mode = ""
+	} else if _, err := retrieve.ParseExpansion(mode); err != nil {
+		return err
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.expansion = strings.ToLower(mode)
+	return nil
+}
+
+// printSearchResults lists retrieved chunks with their location and how each search ranked them
+func printSearchResults(results []retrieve.Result) {
+	cyan := color.New(color.FgCyan)
+	gray := color.New(color.FgHiBlack)
+	fmt.Println()
+	if len(results) == 0 {
+		gray.Println("  No matching chunks. Add documents with /ingest <path>.")
+		fmt.Println()
+		return
+	}
+	for i, r := range results {
+		cyan.Printf("  %d. %s", i+1, chunkLocation(r.Chunk))
+		var ranks []string
+		if r.VectorRank > 0 {
+			ranks = append(ranks, fmt.Sprintf("vector #%d", r.VectorRank))
+		}
+		if r.KeywordRank > 0 {
+			ranks = append(ranks, fmt.Sprintf("keyword #%d", r.KeywordRank))
+		}
+		if r.FirstRank > 0 {
+			ranks = append(ranks, fmt.Sprintf("first stage #%d", r.FirstRank))
+		}
+		gray.Printf("  %.4f · %s\n", r.Score, strings.Join(ranks, " · "))
+		gray.Printf("     %s\n", snippet(r.Chunk.Text, 100))
+	}
+	fmt.Println()
+}
+
+// chunkLocation formats a chunk's source as path, path p.page or path:start-end, falling
+// back to the document title
+func chunkLocation(c rag.Chunk) string {
+	loc := c.Metadata[rag.MetaPath]
+	if loc == "" {
+		loc = c.Metadata[rag.MetaTitle]
+	}
+	if loc == "" {
+		loc = c.DocID
+	}
+	if page := c.Metadata[rag.MetaPage]; page != "" {
+		loc += " p." + page
+	}
+	if start := c.Metadata[rag.MetaStartLine]; start != "" {
+		loc += ":" + start
+		if end := c.Metadata[rag.MetaEndLine]; end != "" && end != start {
+			loc += "-" + end
+		}
+	}
+	return loc
+}
+
+// snippet returns the first n characters of text on a single line
+func snippet(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if r := []rune(text); len(r) > n {
+		return string(r[:n]) + "…"
+	}
+	return text
+}
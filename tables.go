@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go-groq/internal/guard"
+	"go-groq/internal/ingest"
+	"go-groq/internal/llm"
+	"go-groq/internal/rag"
+	"go-groq/internal/retrieve"
+	"go-groq/internal/table"
+)
+
+// tableInstructions explain the table query protocol to the model; the tables follow
+const tableInstructions = `Some of the context comes from tables, listed below. The rows in the context are only a sample, so never compute counts, sums, averages, minimums or maximums from them. Ask for the exact figure instead by replying with a single line and nothing else:
+TABLE_QUERY {"table": "<name>", "op": "count|sum|avg|min|max|distinct|rows", "column": "<column>", "where": [{"column": "<column>", "op": "=|!=|<|<=|>|>=|contains", "value": "<value>"}], "group_by": "<column>"}
+Only "table" and "op" are required. The result is sent back to you; then answer the question, or ask another query.
+
+Tables:`
+
+// tableQueryPrefix starts the line the model asks for a table query with
+const tableQueryPrefix = "TABLE_QUERY"
+
+// maxTableQueries limits the table queries answered for one question
+const maxTableQueries = 4
+
+// cachedTable is a table file parsed for table mode, valid while the file is unchanged
+type cachedTable struct {
+	modTime time.Time
+	size    int64
+	table   *table.Table
+}
+
+// registerTableLoaders makes CSV and JSON files load rows records per chunk
+func registerTableLoaders(rows int) {
+	for _, ext := range []string{".csv", ".tsv"} {
+		ingest.RegisterLoader(ext, ingest.CSVLoader{RowsPerChunk: rows})
+	}
+	for _, ext := range []string{".json", ".jsonl", ".ndjson"} {
+		ingest.RegisterLoader(ext, ingest.JSONLoader{RowsPerChunk: rows})
+	}
+}
+
+// SetTableMode enables or disables exact table queries
+func (cb *ChatBot) SetTableMode(enabled bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.config.TableMode = enabled
+}
+
+// loadTable returns the parsed table file at path, reading it again only when it has changed
+func (cb *ChatBot) loadTable(path string) (*table.Table, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	cb.tablesMu.Lock()
+	defer cb.tablesMu.Unlock()
+	if c, ok := cb.tables[path]; ok && c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+		return c.table, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t, _, err := table.Read(path, data)
+	if err != nil {
+		return nil, err
+	}
+	if cb.tables == nil {
+		cb.tables = make(map[string]cachedTable)
+	}
+	cb.tables[path] = cachedTable{modTime: info.ModTime(), size: info.Size(), table: t}
+	return t, nil
+}
+
+// sourceTables returns the tables that sources were retrieved from, by name: the file
+// name, or the full path when two tables share it. Files that cannot be read are left out
+func (cb *ChatBot) sourceTables(sources []retrieve.Result) map[string]*table.Table {
+	byPath := make(map[string]*table.Table)
+	bases := make(map[string]int)
+	for _, s := range sources {
+		path := s.Chunk.Metadata[rag.MetaPath]
+		if _, done := byPath[path]; done || s.Chunk.Metadata[rag.MetaURL] != "" || !table.IsTable(path) {
+			continue
+		}
+		t, err := cb.loadTable(path)
+		if err != nil {
+			cb.logger().Printf("table mode: %s: %v", path, err)
+			continue
+		}
+		byPath[path] = t
+		bases[filepath.Base(path)]++
+	}
+	tables := make(map[string]*table.Table, len(byPath))
+	for path, t := range byPath {
+		name := filepath.Base(path)
+		if bases[name] > 1 {
+			name = path
+		}
+		tables[name] = t
+	}
+	return tables
+}
+
+// tableSection lists tables for the prompt, after the query instructions
+func tableSection(tables map[string]*table.Table) string {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString(tableInstructions)
+	for _, name := range names {
+		t := tables[name]
+		fmt.Fprintf(&b, "\n- %s: %d rows; columns: %s", name, len(t.Rows), strings.Join(t.Columns, ", "))
+	}
+	return b.String()
+}
+
+// generateWithTables gets an answer from client, running the table queries the model asks
+// for in between. Each result is added to the conversation and the model asked again,
+// up to maxTableQueries times
+func (cb *ChatBot) generateWithTables(ctx context.Context, client llm.LLMClient, messages []llm.Message, tables map[string]*table.Table) (string, error) {
+	for n := 0; ; n++ {
+		answer, err := client.Generate(ctx, messages)
+		if err != nil {
+			return "", err
+		}
+		request, ok := findTableQuery(answer)
+		if !ok {
+			return answer, nil
+		}
+		if n == maxTableQueries {
+			return "", fmt.Errorf("no answer after %d table queries", maxTableQueries)
+		}
+		result, err := cb.runTableQuery(ctx, tables, request)
+		if err != nil {
+			return "", err
+		}
+		if n == maxTableQueries-1 {
+			result += "\nThat was the last table query; answer the question now."
+		}
+		messages = append(messages,
+			llm.Message{Role: "assistant", Content: answer},
+			llm.Message{Role: "user", Content: "Table query result:\n" + result})
+	}
+}
+
+// findTableQuery returns the JSON of the TABLE_QUERY line in answer, if any
+func findTableQuery(answer string) (string, bool) {
+	for _, line := range strings.Split(answer, "\n") {
+		line = strings.Trim(strings.TrimSpace(line), "`")
+		if request, ok := strings.CutPrefix(line, tableQueryPrefix); ok {
+			return strings.TrimSpace(request), true
+		}
+	}
+	return "", false
+}
+
+// runTableQuery answers one table query for the model. Mistakes in the query are
+// returned as the result so that the model can correct them, and so is a result the
+// context guardrails block; the error is only set when the guardrails fail
+func (cb *ChatBot) runTableQuery(ctx context.Context, tables map[string]*table.Table, request string) (string, error) {
+	info := queryInfo(ctx)
+	var q table.Query
+	if err := json.Unmarshal([]byte(request), &q); err != nil {
+		info.TableQueries = append(info.TableQueries, fmt.Sprintf("invalid query: %s", request))
+		return fmt.Sprintf("Error: the query is not valid JSON: %v", err), nil
+	}
+	t := lookupTable(tables, q.Table)
+	if t == nil {
+		names := make([]string, 0, len(tables))
+		for name := range tables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		info.TableQueries = append(info.TableQueries, fmt.Sprintf("%s: unknown table", q.Table))
+		return fmt.Sprintf("Error: no table %q (tables: %s)", q.Table, strings.Join(names, ", ")), nil
+	}
+	res, err := table.Run(t, q)
+	if err != nil {
+		info.TableQueries = append(info.TableQueries, fmt.Sprintf("%s: %s (%v)", q.Table, q, err))
+		return "Error: " + err.Error(), nil
+	}
+	info.TableQueries = append(info.TableQueries, fmt.Sprintf("%s: %s", q.Table, q))
+
+	// Table values are untrusted like any retrieved content
+	checked, err := cb.guard.Run(ctx, guard.StageContext, res.String())
+	var blocked *guard.BlockedError
+	if errors.As(err, &blocked) {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("table query result left out: %s", blocked.Reason))
+		return "Error: the result was withheld by a content policy.", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	info.Warnings = append(info.Warnings, checked.Warnings...)
+	return checked.Text, nil
+}
+
+// lookupTable finds a table by name, ignoring case and accepting the file name of a
+// table listed by its full path
+func lookupTable(tables map[string]*table.Table, name string) *table.Table {
+	name = strings.TrimSpace(name)
+	for n, t := range tables {
+		if strings.EqualFold(n, name) {
+			return t
+		}
+	}
+	for n, t := range tables {
+		if strings.EqualFold(filepath.Base(n), name) {
+			return t
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"go-groq/internal/ingest"
+
+	"github.com/fatih/color"
+)
+
+// IngestURL crawls the web site at a.url into a collection: the page itself and the
+// pages up to a.depth links away, within the CRAWL_* limits. Pages that have not
+// changed since they were last ingested are skipped unless a.force is set
+func (cb *ChatBot) IngestURL(ctx context.Context, a urlArgs) (ingest.CrawlSummary, error) {
+	pipeline, err := cb.pipeline(a.collection, a.force)
+	if err != nil {
+		return ingest.CrawlSummary{}, err
+	}
+	maxPages := cb.config.CrawlMaxPages
+	if a.maxPages > 0 {
+		maxPages = a.maxPages
+	}
+	summary, err := pipeline.Crawl(ctx, a.url, ingest.CrawlOptions{
+		MaxDepth:  a.depth,
+		SameHost:  a.sameHost,
+		MaxPages:  maxPages,
+		Delay:     cb.config.CrawlDelay,
+		UserAgent: cb.config.CrawlUserAgent,
+	}, printIngestProgress)
+	fmt.Print("\r\033[K") // Clear the progress line
+	return summary, err
+}
+
+// printCrawl prints what a crawl fetched and left out
+func (cb *ChatBot) printCrawl(s ingest.CrawlSummary) {
+	gray := color.New(color.FgHiBlack)
+	gray.Printf("   %d pages fetched", s.Pages)
+	if s.Duplicates > 0 || s.Disallowed > 0 {
+		gray.Printf(", %d duplicates, %d disallowed by robots.txt", s.Duplicates, s.Disallowed)
+	}
+	fmt.Println()
+	if s.Limited {
+		color.New(color.FgYellow).Printf("   Stopped at the limit of %d pages; raise it with --max-pages or CRAWL_MAX_PAGES\n", s.Pages)
+	}
+	cb.printIngestSummary(s.Summary)
+}
+
+// urlArgs are the arguments of /ingest-url and the ingest-url subcommand
+type urlArgs struct {
+	collection string
+	url        string
+	depth      int  // links followed from the start page
+	sameHost   bool // only follow links to the host of the start page
+	maxPages   int  // 0 uses CRAWL_MAX_PAGES
+	force      bool
+}
+
+// parseURLArgs parses "[--collection <name>] [--depth N] [--same-host] [--max-pages N]
+// [--force] <url>"
+func parseURLArgs(args []string) (urlArgs, error) {
+	parsed := urlArgs{collection: "default"}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--collection" || arg == "-c" || arg == "--depth" || arg == "--max-pages":
+			if i+1 >= len(args) {
+				return parsed, fmt.Errorf("%s needs a value", arg)
+			}
+			i++
+			if err := parsed.set(arg, args[i]); err != nil {
+				return parsed, err
+			}
+		case strings.HasPrefix(arg, "--collection="), strings.HasPrefix(arg, "--depth="), strings.HasPrefix(arg, "--max-pages="):
+			name, value, _ := strings.Cut(arg, "=")
+			if err := parsed.set(name, value); err != nil {
+				return parsed, err
+			}
+		case arg == "--same-host":
+			parsed.sameHost = true
+		case arg == "--force" || arg == "-f":
+			parsed.force = true
+		case strings.HasPrefix(arg, "-"):
+			return parsed, fmt.Errorf("unknown flag %s", arg)
+		case parsed.url != "":
+			return parsed, fmt.Errorf("only one URL is allowed")
+		default:
+			parsed.url = arg
+		}
+	}
+	if parsed.url == "" {
+		return parsed, fmt.Errorf("no URL given")
+	}
+	return parsed, nil
+}
+
+// set assigns the value of a flag that takes one
+func (a *urlArgs) set(flag, value string) error {
+	if flag == "--collection" || flag == "-c" {
+		a.collection = value
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fmt.Errorf("%s needs a number, got %q", flag, value)
+	}
+	if flag == "--depth" {
+		a.depth = n
+	} else {
+		a.maxPages = n
+	}
+	return nil
+}
+
+// urlUsage lists the arguments of /ingest-url
+const urlUsage = "[--collection <name>] [--depth N] [--same-host] [--max-pages N] [--force] <url>"
+
+// runURLCommand implements the "ingest-url" subcommand
+func runURLCommand(ctx context.Context, cb *ChatBot, args []string) error {
+	parsed, err := parseURLArgs(args)
+	if err != nil {
+		return fmt.Errorf("%v\nusage: %s ingest-url %s", err, os.Args[0], urlUsage)
+	}
+	color.New(color.FgCyan).Printf("🌐 Crawling %s into %s\n", parsed.url, parsed.collection)
+	summary, err := cb.IngestURL(ctx, parsed)
+	if err != nil {
+		return fmt.Errorf("ingest %s: %w", parsed.url, err)
+	}
+	cb.printCrawl(summary)
+	return nil
+}
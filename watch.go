@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go-groq/internal/ingest"
+
+	"github.com/fatih/color"
+)
+
+// watch is a directory or file kept up to date in the background
+type watch struct {
+	collection string
+	cancel     context.CancelFunc
+}
+
+// Watch keeps path up to date in the knowledge base while the chat runs: changed files
+// are re-ingested into collection and deleted ones removed. Events are queued for
+// RunInteractive to print between prompts. Call Ingest first to bring path up to date
+func (cb *ChatBot) Watch(path, collection string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	pipeline, err := cb.pipeline(collection, false)
+	if err != nil {
+		return err
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if _, ok := cb.watches[abs]; ok {
+		return fmt.Errorf("already watching %s", path)
+	}
+	if cb.watches == nil {
+		cb.watches = make(map[string]watch)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cb.watches[abs] = watch{collection: collection, cancel: cancel}
+	cb.watchers.Add(1)
+	go func() {
+		defer cb.watchers.Done()
+		if err := pipeline.Watch(ctx, abs, cb.queueWatchEvent); err != nil {
+			cb.queueWatchEvent(ingest.Progress{File: path, Err: fmt.Errorf("watch stopped: %w", err)})
+			cb.mu.Lock()
+			delete(cb.watches, abs)
+			cb.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+// Unwatch stops watching path, or every path when path is empty. It returns the
+// paths it stopped
+func (cb *ChatBot) Unwatch(path string) ([]string, error) {
+	abs := ""
+	if path != "" {
+		var err error
+		if abs, err = filepath.Abs(path); err != nil {
+			return nil, err
+		}
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	var stopped []string
+	for p, w := range cb.watches {
+		if abs == "" || p == abs {
+			w.cancel()
+			delete(cb.watches, p)
+			stopped = append(stopped, p)
+		}
+	}
+	if abs != "" && len(stopped) == 0 {
+		return nil, fmt.Errorf("not watching %s", path)
+	}
+	sort.Strings(stopped)
+	return stopped, nil
+}
+
+// stopWatches stops every watch and waits for them to finish writing to the index
+func (cb *ChatBot) stopWatches() {
+	cb.Unwatch("")
+	cb.watchers.Wait()
+}
+
+// printWatches lists the watched paths and their collections
+func (cb *ChatBot) printWatches() {
+	gray := color.New(color.FgHiBlack)
+	cb.mu.RLock()
+	paths := make([]string, 0, len(cb.watches))
+	for p := range cb.watches {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	fmt.Println()
+	if len(paths) == 0 {
+		gray.Println("  Not watching anything. Start with /ingest --watch <path>.")
+	}
+	for _, p := range paths {
+		gray.Printf("  👀 %s → %s\n", p, cb.watches[p].collection)
+	}
+	cb.mu.RUnlock()
+	fmt.Println()
+}
+
+// queueWatchEvent records a background update and wakes RunInteractive to print it
+func (cb *ChatBot) queueWatchEvent(p ingest.Progress) {
+	if p.Skipped || p.Unchanged {
+		return
+	}
+	cb.watchMu.Lock()
+	cb.watchEvents = append(cb.watchEvents, p)
+	cb.watchMu.Unlock()
+	select {
+	case cb.watchNotify <- struct{}{}:
+	default: // already signalled
+	}
+}
+
+// takeWatchEvents returns and clears the queued background updates
+func (cb *ChatBot) takeWatchEvents() []ingest.Progress {
+	cb.watchMu.Lock()
+	defer cb.watchMu.Unlock()
+	events := cb.watchEvents
+	cb.watchEvents = nil
+	return events
+}
+
+// printWatchEvent prints one background update on its own line
+func printWatchEvent(p ingest.Progress) {
+	if p.Skipped || p.Unchanged {
+		return
+	}
+	gray := color.New(color.FgHiBlack)
+	stamp := time.Now().Format("15:04:05")
+	switch {
+	case p.Err != nil:
+		color.New(color.FgRed).Printf("  ↻ %s ✗ %s: %v\n", stamp, p.File, p.Err)
+	case p.Removed:
+		gray.Printf("  ↻ %s removed %s\n", stamp, p.File)
+	default:
+		gray.Printf("  ↻ %s updated %s (%d chunks)\n", stamp, p.File, p.Chunks)
+		for _, w := range p.Warnings {
+			color.New(color.FgYellow).Printf("    ⚠️  %s\n", w)
+		}
+	}
+}